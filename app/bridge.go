@@ -4,11 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -17,67 +22,194 @@ import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
-var (
-	buildVersion = "dev"
-)
+var buildVersion = "dev"
 
+// connectLostHandler only logs; paho reconnects on its own and OnConnect
+// restores subscriptions, discovery and availability.
 var connectLostHandler mqtt.ConnectionLostHandler = func(client mqtt.Client, err error) {
-	panic("Connection to MQTT lost")
+	log.Printf("Connection to MQTT lost: %v; reconnecting", err)
 }
 
 func RunBridge(configPath string) {
 	c := LoadConfig(configPath)
-	if c.Settings.OCPPMismatchSeconds == 0 {
-		c.Settings.OCPPMismatchSeconds = 60
+	c.applyDefaults()
+
+	wallbox.ApplyControlPilotOverrides(controlPilotOverrides(c))
+	chargers := chargerConfigs(c, configPath)
+	if len(chargers) == 1 {
+		if err := runCharger(chargers[0], configPath); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	if c.Settings.OCPPRestartCooldown == 0 {
-		c.Settings.OCPPRestartCooldown = 600
+
+	// Every charger gets its own MQTT client, poll loop and heal state;
+	// each one sees the interrupt and shuts itself down. A misconfigured
+	// charger is skipped so it can't take the others down with it.
+	var wg sync.WaitGroup
+	for _, cc := range chargers {
+		wg.Add(1)
+		go func(cc *WallboxConfig) {
+			defer wg.Done()
+			if err := runCharger(cc, configPath); err != nil {
+				log.Printf("Not bridging charger %q: %v", cc.Settings.DeviceName, err)
+			}
+		}(cc)
 	}
-	if c.Settings.OCPPMaxRestarts <= 0 {
-		// Default to a small number of restart attempts before either giving up
-		// or escalating to a full reboot (if enabled).
-		c.Settings.OCPPMaxRestarts = 3
+	wg.Wait()
+}
+
+// runCharger bridges one charger until the process is interrupted. It only
+// returns an error for problems the charger can't recover from by retrying,
+// such as a bad configuration.
+func runCharger(c *WallboxConfig, configPath string) error {
+	store, err := openStore(c, configPath)
+	if err != nil {
+		return err
 	}
-	if c.Settings.PilotErrorSeconds == 0 {
-		c.Settings.PilotErrorSeconds = 300
+	w := connectWallbox(wallbox.Config{
+		MySQL: wallbox.MySQLConfig{
+			Host:     c.MySQL.Host,
+			Port:     c.MySQL.Port,
+			Username: c.MySQL.Username,
+			Password: c.MySQL.Password,
+			Database: c.MySQL.Database,
+		},
+		Redis: wallbox.RedisConfig{
+			Addr:     c.Redis.Addr,
+			Password: c.Redis.Password,
+			DB:       c.Redis.DB,
+
+			FirmwareVersionKey:   c.Redis.FirmwareVersionKey,
+			FirmwareVersionField: c.Redis.FirmwareVersionField,
+		},
+		Store:          store,
+		StoreKeyPrefix: c.Persistence.KeyPrefix,
+	})
+	if c.Settings.TelemetryTriggers != "" {
+		w.SetTelemetryTriggers(strings.Split(strings.ReplaceAll(c.Settings.TelemetryTriggers, " ", ""), ","))
 	}
+	w.ApplyQueryOverrides(wallbox.Queries{
+		Refresh:            c.Queries.Refresh,
+		SerialNumber:       c.Queries.SerialNumber,
+		FirmwareVersion:    c.Queries.FirmwareVersion,
+		ChargerType:        c.Queries.ChargerType,
+		AvailableCurrent:   c.Queries.AvailableCurrent,
+		Schedules:          c.Queries.Schedules,
+		ConnectorType:      c.Queries.ConnectorType,
+		LifetimeAddedRange: c.Queries.LifetimeAddedRange,
+		AutoLock:           c.Queries.AutoLock,
+		PhaseCurrentLimits: c.Queries.PhaseCurrentLimits,
+		Timezone:           c.Queries.Timezone,
 
-	w := wallbox.New()
-	w.RefreshData()
+		SetEcosmartMode:     c.Queries.SetEcosmartMode,
+		SetSchedulesEnabled: c.Queries.SetSchedulesEnabled,
+		SetAutoLockEnabled:  c.Queries.SetAutoLockEnabled,
+		SetAutoLockTime:     c.Queries.SetAutoLockTime,
+	})
+	// The first read has to succeed: the entities are built from it.
+	for delay := connectRetryDelay; ; {
+		err := w.RefreshData()
+		if err == nil {
+			break
+		}
+		log.Printf("Cannot read the charger state yet: %v; retrying in %s", err, delay)
+		time.Sleep(delay)
+		if delay *= 2; delay > maxConnectRetryDelay {
+			delay = maxConnectRetryDelay
+		}
+	}
 	w.StartRedisSubscriptions()
-	w.StartOCPPJournalWatcher()
 	defer w.StopRedisSubscriptions()
-	defer w.StopOCPPJournalWatcher()
+
+	journalPatterns := parseJournalPatterns(c.JournalPatterns)
+	journalMatches := make(chan wallbox.JournalMatch, 32)
+	journalStats := newJournalSignals()
+	if w.OffDevice() {
+		// journald and systemctl only exist on the charger itself; healing
+		// from another host would restart or reboot the wrong machine.
+		log.Println("Running off-device: OCPP journal watcher, self-heal and reboot actions are disabled")
+		c.Settings.AutoRestartOCPP = false
+		c.Settings.PilotErrorReboot = false
+		c.Settings.GhostSessionHeal = false
+		c.Settings.StuckPreparingHeal = false
+		if len(journalPatterns) > 0 {
+			log.Println("Running off-device: ignoring [journal_patterns]")
+			journalPatterns = nil
+		}
+	} else {
+		w.SetJournalPatterns(journalPatterns, func(m wallbox.JournalMatch) {
+			journalStats.Record(m)
+			select {
+			case journalMatches <- m:
+			default:
+				log.Printf("Dropping journal %s event, publishing is behind", m.Name)
+			}
+		})
+		w.StartOCPPJournalWatcher()
+		defer w.StopOCPPJournalWatcher()
+	}
+
+	if !w.OffDevice() && !w.RemoteControlAvailable() {
+		log.Println("WARNING: the charger's posix control queues are missing; lock and charging enable/disable will not work on this firmware")
+	}
 
 	serialNumber := w.SerialNumber()
+	deviceID, err := resolveDeviceID(serialNumber, c.Settings.DeviceIDOverride)
+	if err != nil {
+		return err
+	}
+	if deviceID != serialNumber {
+		log.Printf("Using device id %q instead of serial number %q", deviceID, serialNumber)
+	}
 	firmwareVersion := w.FirmwareVersion()
-	entityConfig := getEntities(w)
-	if c.Settings.DebugSensors {
-		for k, v := range getDebugEntities(w) {
-			entityConfig[k] = v
-		}
-		for k, v := range getTelemetryEventEntities(w) {
-			entityConfig[k] = v
-		}
+	w.SetChargingDetection(c.Settings.ChargingMode, float64(c.Settings.ChargingPowerThreshold))
+	w.SetIdlePowerFloor(float64(c.Settings.IdlePowerFloor))
+	w.SetUpdateBusyStates(parseIntList(c.Settings.UpdateBusyStates))
+	w.SetOCPPPrecedence(c.Settings.OCPPPrecedence)
+	w.SetFallbackAvailableCurrent(c.Settings.FallbackAvailableCurrent)
+	w.SetUnmappedSensorLogging(c.Settings.LogUnmappedSensors)
+	if ip := w.NetworkInfo().IP; ip != "unknown" {
+		c.configurationURL = "http://" + ip + "/"
 	}
 
-	if c.Settings.PowerBoostEnabled {
-		for k, v := range getPowerBoostEntities(w, c) {
-			entityConfig[k] = v
+	entityConfig := buildEntityConfig(w, c)
+	smoother := applySmoothing(entityConfig, c)
+
+	var reapplier *configReapplier
+	if c.Settings.ReapplyAfterReboot {
+		path := c.Settings.ReapplyStateFile
+		if path == "" {
+			path = filepath.Join(filepath.Dir(configPath), "intended_settings.json")
 		}
+		reapplier = loadConfigReapplier(path)
+		applyConfigReapply(entityConfig, reapplier)
 	}
 
+	heal := newHealServices(c)
+	// autoHeal overrides auto_restart_ocpp for this process; it is set from
+	// MQTT on paho's goroutine.
+	var autoHeal atomic.Bool
+	autoHeal.Store(c.Settings.AutoRestartOCPP)
+	healUnits := strings.Join(heal.Restart, ", ")
 	ocppMismatchState := "0"
 	ocppLastRestart := "never"
 	ocppLastHealAction := "idle"
 	ocppLastHealAt := "never"
 	ocppLastHealDetail := ""
-	var mismatchStart time.Time
+	mismatch := newMismatchTracker(time.Duration(c.Settings.OCPPMismatchClearSeconds) * time.Second)
 	var lastRestart time.Time
 	var ocppRestartCount int
+	// ocppRestartTotal counts every successful service restart since the
+	// bridge started, unlike ocppRestartCount which starts over with every mismatch.
+	var ocppRestartTotal int
 	var lastFullReboot time.Time
 	var pilotErrorStart time.Time
 	var lastPilotErrorReboot time.Time
+	ghostSessionState := "0"
+	ghostSession := newSustainedCondition(time.Duration(c.Settings.GhostSessionSeconds) * time.Second)
+	stuckPreparingState := "0"
+	stuckPreparing := newSustainedCondition(time.Duration(c.Settings.StuckPreparingSeconds) * time.Second)
 
 	entityConfig["ocpp_mismatch"] = Entity{
 		Component: "binary_sensor",
@@ -91,6 +223,145 @@ func RunBridge(configPath string) {
 		},
 	}
 
+	// ocpp_mismatch_duration counts towards ocpp_mismatch_seconds, so it
+	// starts over after every restart attempt just like the heal timer.
+	entityConfig["ocpp_mismatch_duration"] = Entity{
+		Component: "sensor",
+		Getter: func() string {
+			return fmt.Sprint(int(mismatch.Duration(time.Now()).Seconds()))
+		},
+		Config: map[string]string{
+			"name":                "OCPP mismatch duration",
+			"device_class":        "duration",
+			"unit_of_measurement": "s",
+			"state_class":         "measurement",
+			"entity_category":     "diagnostic",
+		},
+	}
+
+	if !w.OffDevice() {
+		entityConfig["auto_restart_ocpp"] = Entity{
+			Component: "switch",
+			Setter: func(val string) {
+				autoHeal.Store(val == "1")
+				if val == "1" {
+					log.Println("OCPP auto-restart enabled until the bridge restarts")
+				} else {
+					log.Println("OCPP auto-restart disabled until the bridge restarts")
+				}
+			},
+			Getter: func() string {
+				if autoHeal.Load() {
+					return "1"
+				}
+				return "0"
+			},
+			Config: map[string]string{
+				"name":            "OCPP auto-restart",
+				"payload_on":      "1",
+				"payload_off":     "0",
+				"icon":            "mdi:auto-fix",
+				"entity_category": "config",
+			},
+		}
+	}
+
+	if c.Settings.StuckPreparingSeconds > 0 {
+		entityConfig["ocpp_stuck_preparing"] = Entity{
+			Component: "binary_sensor",
+			Getter:    func() string { return stuckPreparingState },
+			Config: map[string]string{
+				"name":            "OCPP stuck preparing",
+				"payload_on":      "1",
+				"payload_off":     "0",
+				"device_class":    "problem",
+				"entity_category": "diagnostic",
+			},
+		}
+	}
+
+	entityConfig["ghost_session"] = Entity{
+		Component: "binary_sensor",
+		Getter:    func() string { return ghostSessionState },
+		Config: map[string]string{
+			"name":            "Ghost session",
+			"payload_on":      "1",
+			"payload_off":     "0",
+			"device_class":    "problem",
+			"entity_category": "diagnostic",
+		},
+	}
+
+	updatingState := "0"
+	healSuppressed := false
+	// A tripped consistency check means the bridge may be misreading the
+	// charger; heals stay off until the data has looked sane for a while.
+	consistencyChecks := parseConsistencyChecks(c.Settings.ConsistencyChecks)
+	inconsistent := newMismatchTracker(time.Duration(c.Settings.ConsistencyClearSeconds) * time.Second)
+	entityConfig["data_inconsistent"] = Entity{
+		Component: "binary_sensor",
+		Getter: func() string {
+			if inconsistent.Active() {
+				return "1"
+			}
+			return "0"
+		},
+		Config: map[string]string{
+			"name":            "Data inconsistent",
+			"payload_on":      "1",
+			"payload_off":     "0",
+			"device_class":    "problem",
+			"entity_category": "diagnostic",
+		},
+	}
+	entityConfig["updating"] = Entity{
+		Component: "binary_sensor",
+		Getter:    func() string { return updatingState },
+		Config: map[string]string{
+			"name":         "Firmware updating",
+			"device_class": "update",
+			"payload_on":   "1",
+			"payload_off":  "0",
+		},
+	}
+
+	entityConfig["ocpp_heal_tier"] = Entity{
+		Component: "sensor",
+		Getter: func() string {
+			now := time.Now()
+			cooldown := time.Duration(c.Settings.OCPPRestartCooldown) * time.Second
+
+			if inconsistent.Active() {
+				return "data-suppressed"
+			}
+			if healSuppressed {
+				return "update-suppressed"
+			}
+			// The pilot error reboot is an independent, more drastic tier.
+			if c.Settings.PilotErrorReboot && !pilotErrorStart.IsZero() {
+				return "reboot-pending"
+			}
+			if !autoHeal.Load() || !mismatch.Active() {
+				return "idle"
+			}
+			if c.Settings.OCPPMaxRestarts == 0 || ocppRestartCount < c.Settings.OCPPMaxRestarts {
+				if !lastRestart.IsZero() && now.Sub(lastRestart) < cooldown {
+					return "awaiting-cooldown"
+				}
+				return "restarting"
+			}
+			if !c.Settings.OCPPFullReboot {
+				return "reboot-suppressed"
+			}
+			return "reboot-pending"
+		},
+		Config: map[string]string{
+			"name":            "OCPP heal tier",
+			"icon":            "mdi:stairs-up",
+			"entity_category": "diagnostic",
+		},
+	}
+
 	entityConfig["ocpp_last_restart"] = Entity{
 		Component: "sensor",
 		Getter:    func() string { return ocppLastRestart },
@@ -100,6 +371,38 @@ func RunBridge(configPath string) {
 		},
 	}
 
+	entityConfig["ocpp_restart_count"] = Entity{
+		Component: "sensor",
+		Getter:    func() string { return fmt.Sprint(ocppRestartCount) },
+		Config: map[string]string{
+			"name":            "OCPP restart attempts",
+			"icon":            "mdi:restart",
+			"state_class":     "measurement",
+			"entity_category": "diagnostic",
+		},
+	}
+
+	entityConfig["ocpp_restart_total"] = Entity{
+		Component: "sensor",
+		Getter:    func() string { return fmt.Sprint(ocppRestartTotal) },
+		Config: map[string]string{
+			"name":            "OCPP restarts",
+			"icon":            "mdi:restart-alert",
+			"state_class":     "total_increasing",
+			"entity_category": "diagnostic",
+		},
+	}
+
+	entityConfig["last_heal_reboot"] = Entity{
+		Component: "sensor",
+		Getter:    w.LastHealReboot,
+		Config: map[string]string{
+			"name":            "Last heal reboot",
+			"device_class":    "timestamp",
+			"entity_category": "diagnostic",
+		},
+	}
+
 	entityConfig["ocpp_enabled"] = Entity{
 		Component: "binary_sensor",
 		Getter:    w.OCPPEnabled,
@@ -154,64 +457,256 @@ func RunBridge(configPath string) {
 		},
 	}
 
-	topicPrefix := "wallbox_" + serialNumber
+	var pollCycles pollCycleStats
+	for k, v := range getPollCycleEntities(&pollCycles) {
+		entityConfig[k] = v
+	}
+	for k, v := range getJournalPatternEntities(journalPatterns, journalStats) {
+		entityConfig[k] = v
+	}
+	var mqttStats mqttConnectionStats
+	for k, v := range getMQTTConnectionEntities(&mqttStats) {
+		entityConfig[k] = v
+	}
+	sqlSensors := parseSQLSensors(c.SQLSensors)
+	sqlValues := newSQLSensorValues()
+	for k, v := range getSQLSensorEntities(sqlSensors, sqlValues) {
+		entityConfig[k] = v
+	}
+	var offlineMonitor *chargerOfflineMonitor
+	var offlineEntities map[string]Entity
+	if c.Settings.ChargerOfflineAlert {
+		offlineMonitor = newChargerOfflineMonitor(time.Duration(c.Settings.ChargerOfflineTimeout)*time.Second, time.Now())
+		offlineEntities = getChargerOfflineEntities(offlineMonitor, w.LastSeen)
+		for k, v := range offlineEntities {
+			entityConfig[k] = v
+		}
+	}
+
+	topicPrefix := "wallbox_" + deviceID
 	availabilityTopic := topicPrefix + "/availability"
 
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(fmt.Sprintf("tcp://%s:%d", c.MQTT.Host, c.MQTT.Port))
-	opts.SetUsername(c.MQTT.Username)
-	opts.SetPassword(c.MQTT.Password)
-	opts.SetWill(availabilityTopic, "offline", 1, true)
+	messageHandler := func(client mqtt.Client, msg mqtt.Message) {
+		field := strings.Split(msg.Topic(), "/")[1]
+		payload := string(msg.Payload())
+		setter := entityConfig[field].Setter
+		fmt.Println("Setting", field, payload)
+		setter(payload)
+	}
+	commandTopic := topicPrefix + "/+/set"
+
+	// OnConnect runs on paho's goroutine; the poll loop republishes
+	// discovery and states after a reconnect so it alone touches them.
+	// Commands are only subscribed once entitiesReady is closed, because
+	// the setters below still add to entityConfig after connecting and a
+	// retained set message would otherwise race with them.
+	reconnected := make(chan struct{}, 1)
+	entitiesReady := make(chan struct{})
+	opts, err := mqttClientOptions(c, availabilityTopic)
+	if err != nil {
+		return err
+	}
 	opts.OnConnectionLost = connectLostHandler
+	opts.OnConnect = func(client mqtt.Client) {
+		// The session is clean, so subscriptions don't survive a reconnect.
+		go func() {
+			<-entitiesReady
+			client.Subscribe(commandTopic, 1, messageHandler)
+		}()
+		if mqttStats.Connected(time.Now()) {
+			log.Println("Reconnected to MQTT")
+			select {
+			case reconnected <- struct{}{}:
+			default:
+			}
+		}
+	}
 
 	client := mqtt.NewClient(opts)
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		panic(token.Error())
+		return fmt.Errorf("connecting to MQTT: %w", token.Error())
 	}
+	warnOnDeviceCollision(client, availabilityTopic, c.MQTT.PayloadAvailable)
 
-	for key, val := range entityConfig {
-		component := val.Component
-		uid := serialNumber + "_" + key
-		config := map[string]interface{}{
-			"~":                  topicPrefix + "/" + key,
-			"availability_topic": availabilityTopic,
-			"state_topic":        "~/state",
-			"unique_id":          uid,
-			"device": map[string]string{
-				"identifiers": serialNumber,
-				"name":        c.Settings.DeviceName,
-				"sw_version":  fmt.Sprintf("%s (FW %s)", bridgeVersion(), firmwareVersion),
-			},
+	if c.Settings.LockEvents {
+		lockEventTopic := topicPrefix + "/events/lock"
+		w.SetLockTransitionHandler(func(locked bool, at time.Time) {
+			event := "unlocked"
+			if locked {
+				event = "locked"
+			}
+			payload, _ := json.Marshal(map[string]string{"event": event, "at": at.Format(time.RFC3339)})
+			client.Publish(lockEventTopic, 1, false, payload)
+		})
+	}
+
+	healEventTopic := topicPrefix + "/events/heal"
+	publishHealEvent := func(event healEvent) {
+		if !c.Settings.HealEvents {
+			return
 		}
-		if val.Setter != nil {
-			config["command_topic"] = "~/set"
+		event.DryRun = heal.DryRun
+		payload, _ := json.Marshal(event)
+		client.Publish(healEventTopic, 1, false, payload)
+	}
+
+	// rebootForHeal reboots the charger in the background; in dry-run mode
+	// it only records what it would have done.
+	rebootForHeal := func(now time.Time, detail, reason string) {
+		if heal.DryRun {
+			log.Printf("heal (dry run): would reboot the charger (%s)", detail)
+			ocppLastHealAction = dryRunAction("reboot")
+			ocppLastHealDetail = detail
+			ocppLastHealAt = now.Format(time.RFC3339)
+			return
 		}
-		for k, v := range val.Config {
-			config[k] = v
+		w.RecordHealReboot(now)
+		go func() {
+			if err := rebootSystem(); err != nil {
+				log.Printf("Failed to reboot for %s: %v", reason, err)
+			}
+		}()
+	}
+
+	// healDue reports whether a heal may restart the services now: heals
+	// aren't suppressed and the restart cooldown has passed.
+	healDue := func(now time.Time) bool {
+		cooldown := time.Duration(c.Settings.OCPPRestartCooldown) * time.Second
+		return !healSuppressed && (lastRestart.IsZero() || now.Sub(lastRestart) >= cooldown)
+	}
+
+	// healRestart restarts the charging services to clear reason, e.g. a
+	// ghost session, and records the heal.
+	healRestart := func(now time.Time, reason string, ocppCode int) {
+		log.Printf("Restarting %s to clear %s", healUnits, reason)
+		action, detail, err := restartCriticalServices(heal)
+		ocppLastHealAction = action
+		ocppLastHealDetail = reason + ": " + detail
+		ocppLastHealAt = now.Format(time.RFC3339)
+		publishHealEvent(newHealEvent(healEventAction(action), ocppLastHealDetail, ocppCode, now))
+		lastRestart = now
+		if err != nil {
+			log.Printf("Failed to restart charging stack for %s: %v", reason, err)
+			return
 		}
-		jsonPayload, _ := json.Marshal(config)
-		token := client.Publish("homeassistant/"+component+"/"+uid+"/config", 1, true, jsonPayload)
-		token.Wait()
+		ocppRestartTotal++
+		ocppLastRestart = now.Format(time.RFC3339)
 	}
 
-	token := client.Publish(availabilityTopic, 1, true, "online")
-	token.Wait()
+	// Confirm a baseline re-sync, successful or not, so the press visibly
+	// did something before the next poll updates added_energy.
+	if resync, ok := entityConfig["resync_session_energy"]; ok {
+		resyncTopic := topicPrefix + "/events/session_energy_resync"
+		resync.Setter = func(_ string) {
+			ok := w.ResyncSessionEnergyBaseline()
+			payload, _ := json.Marshal(map[string]interface{}{
+				"resynced":       ok,
+				"session_energy": w.AddedEnergy(),
+				"at":             time.Now().Format(time.RFC3339),
+			})
+			client.Publish(resyncTopic, 1, false, payload)
+		}
+		entityConfig["resync_session_energy"] = resync
+	}
 
-	messageHandler := func(client mqtt.Client, msg mqtt.Message) {
-		field := strings.Split(msg.Topic(), "/")[1]
-		payload := string(msg.Payload())
-		setter := entityConfig[field].Setter
-		fmt.Println("Setting", field, payload)
-		setter(payload)
+	if c.Settings.DebugSensors && !w.OffDevice() {
+		supportTopic := topicPrefix + "/support/journal"
+		entityConfig["journal_snapshot"] = Entity{
+			Component: "button",
+			Getter:    func() string { return "" }, // stateless button
+			Setter: func(_ string) {
+				go publishJournalSnapshot(client, supportTopic, publishTimeout(c))
+			},
+			Config: map[string]string{
+				"name":            "Publish OCPP journal snapshot",
+				"icon":            "mdi:text-box-search-outline",
+				"payload_press":   "PRESS",
+				"entity_category": "diagnostic",
+			},
+		}
+	}
+
+	// Applied last so they also cover the bridge-internal entities above.
+	applyRateLimits(entityConfig, c.RateLimits)
+	applyAlwaysPublish(entityConfig, c.Settings.AlwaysPublish)
+	close(entitiesReady)
+
+	// activeEntities holds the entities whose discovery has been published;
+	// conditional ones join once their Condition first holds.
+	activeEntities := make(map[string]Entity)
+	publishDiscovery(client, c, discoverConditional(entityConfig, activeEntities), deviceID, firmwareVersion)
+	if c.Settings.HealEvents && c.Settings.HealEventTriggers {
+		publishHealTriggers(client, c, deviceID, firmwareVersion)
+	}
+
+	if !waitPublish(client.Publish(availabilityTopic, 1, true, c.MQTT.PayloadAvailable), publishTimeout(c)) {
+		log.Printf("Timed out publishing availability to %s", availabilityTopic)
+	}
+
+	var status *statusPage
+	if c.Settings.StatusPageAddr != "" {
+		status = newStatusPage(c.Settings.DeviceName, c.Settings.PollingIntervalSeconds)
+		mux := http.NewServeMux()
+		mux.Handle("/", status)
+		if c.Settings.RESTAPI {
+			if c.Settings.RESTAPIToken == "" {
+				log.Println("WARNING: rest_api is enabled without rest_api_token; anyone on the network can control the charger")
+			}
+			api := &entityAPI{page: status, entities: entityConfig, token: c.Settings.RESTAPIToken}
+			mux.Handle("/entities", api)
+			mux.Handle("/entities/", api)
+		}
+		startStatusPage(c.Settings.StatusPageAddr, mux)
+	} else if c.Settings.RESTAPI {
+		log.Println("WARNING: rest_api is enabled without status_page_addr; the REST API is served by the status page server, so it is not started")
 	}
 
-	topic := topicPrefix + "/+/set"
-	client.Subscribe(topic, 1, messageHandler)
+	var metrics *metricsExporter
+	if c.Metrics.Listen != "" {
+		metrics = newMetricsExporter(deviceID)
+		stopMetrics := startMetricsServer(c.Metrics.Listen, metrics)
+		defer stopMetrics()
+	}
 
 	ticker := time.NewTicker(time.Duration(c.Settings.PollingIntervalSeconds) * time.Second)
 	defer ticker.Stop()
 
 	published := make(map[string]interface{})
+	entityAvailability := make(map[string]bool)
+	publishFn := func(key string, payload []byte) mqtt.Token {
+		return client.Publish(topicPrefix+"/"+key+"/state", 1, true, payload)
+	}
+
+	// A broker that stops acknowledging without dropping the TCP connection
+	// would otherwise never trigger the connection-lost path.
+	timeoutCycles := 0
+	checkPublishTimeouts := func(timedOut int) {
+		if timedOut == 0 {
+			timeoutCycles = 0
+			return
+		}
+		timeoutCycles++
+		log.Printf("%d publishes timed out (%d consecutive cycles)", timedOut, timeoutCycles)
+		if timeoutCycles >= maxPublishTimeoutCycles {
+			connectLostHandler(client, fmt.Errorf("publishes timed out in %d consecutive cycles", timeoutCycles))
+			timeoutCycles = 0
+			client.Disconnect(250)
+			client.Connect()
+		}
+	}
+
+	// Optionally publish between polls when Redis events arrive. Bursts are
+	// coalesced so a state transition results in one publish, not dozens.
+	eventPublish := make(chan struct{}, 1)
+	if c.Settings.EventPublishDebounceMs > 0 {
+		debounce := newCoalescer(time.Duration(c.Settings.EventPublishDebounceMs)*time.Millisecond, func() {
+			select {
+			case eventPublish <- struct{}{}:
+			default:
+			}
+		})
+		w.SetEventHandler(func(channel string, message string) { debounce.Trigger() })
+	}
 
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
@@ -219,71 +714,176 @@ func RunBridge(configPath string) {
 	for {
 		select {
 		case <-ticker.C:
-			w.RefreshData()
+			cycleStart := time.Now()
+			refreshErr := w.RefreshData()
+			if offlineMonitor != nil {
+				lastSeen := w.LastSeen()
+				if offlineMonitor.Update(lastSeen, cycleStart) {
+					offline := offlineMonitor.Offline()
+					if offline {
+						log.Printf("Charger offline: no state or telemetry since %s", formatLastSeen(lastSeen))
+					} else {
+						log.Println("Charger back online")
+					}
+					payload, _ := json.Marshal(chargerOfflineEvent{Offline: offline, LastSeen: formatLastSeen(lastSeen), At: cycleStart.Format(time.RFC3339)})
+					client.Publish(topicPrefix+"/events/charger_offline", 1, false, payload)
+				}
+			}
+			if err := refreshErr; err != nil {
+				// Keep the last published states rather than acting on
+				// stale or partial data.
+				log.Printf("Skipping poll cycle: %v", err)
+				w.RecordSkippedCycle()
+				// The offline alert matters most exactly when reads fail.
+				if offlineEntities != nil {
+					_, timedOut := publishChangedStates(publishFn, offlineEntities, published, false, publishTimeout(c))
+					checkPublishTimeouts(timedOut)
+				}
+				continue
+			}
 			now := time.Now()
+			smoother.Sample(now)
+			sqlValues.Refresh(sqlSensors, w.QueryScalar)
+
+			if reapplier != nil {
+				if uptime, ok := w.Uptime(); ok && reapplier.CheckReboot(now, uptime) {
+					applied := reapplier.Reapply(w, chargerConfig{
+						MaxChargingCurrent: w.Data.SQL.MaxChargingCurrent,
+						Lock:               w.Data.SQL.Lock,
+						HaloBrightness:     w.Data.SQL.HaloBrightness,
+					})
+					log.Printf("Charger rebooted; re-applied settings: %v", applied)
+					if len(applied) > 0 {
+						payload, _ := json.Marshal(configReappliedEvent{Settings: applied, At: now.Format(time.RFC3339)})
+						client.Publish(topicPrefix+"/events/config_reapplied", 1, false, payload)
+					}
+				}
+			}
 
 			pilotConnected := w.HasTelemetry && (w.CableConnected() == 1 || w.IsChargingPilot())
 			ocppCode := w.OCPPStatusCode()
 			ocppIndicatesDisconnect := w.OCPPIndicatesDisconnect()
 
-			if pilotConnected && ocppIndicatesDisconnect {
-				if mismatchStart.IsZero() {
-					mismatchStart = now
-					ocppRestartCount = 0
-					log.Printf("OCPP mismatch detected: pilot=%d (%s), OCPP=%d (%s)", w.ControlPilotCode(), w.ControlPilotStatus(), ocppCode, w.OCPPStatusDescription())
-				}
+			started, cleared := mismatch.Update(now, pilotConnected && ocppIndicatesDisconnect)
+			if started {
+				ocppRestartCount = 0
+				log.Printf("OCPP mismatch detected: pilot=%d (%s), OCPP=%d (%s)", w.ControlPilotCode(), w.ControlPilotStatus(), ocppCode, w.OCPPStatusDescription())
+			}
+			if cleared {
+				log.Println("OCPP mismatch cleared")
+				ocppRestartCount = 0
+			}
+			if mismatch.Active() {
 				ocppMismatchState = "1"
 			} else {
-				if ocppMismatchState != "0" {
-					log.Println("OCPP mismatch cleared")
-				}
 				ocppMismatchState = "0"
-				mismatchStart = time.Time{}
-				ocppRestartCount = 0
 			}
 
-			if c.Settings.AutoRestartOCPP && ocppMismatchState == "1" && !mismatchStart.IsZero() {
+			// Mismatches are expected while firmware is installed; never
+			// restart or reboot in the middle of an update.
+			updating := w.Updating()
+			if updating && updatingState != "1" {
+				log.Printf("Firmware update in progress (status %s)", w.EffectiveStatus())
+			} else if !updating && updatingState == "1" {
+				log.Println("Firmware update finished")
+			}
+			updatingState = "0"
+			if updating {
+				updatingState = "1"
+			}
+			failed := failedConsistencyChecks(consistencyChecks, newConsistencySample(w))
+			started, cleared = inconsistent.Update(now, len(failed) > 0)
+			if started {
+				log.Printf("Data looks inconsistent (%s); suppressing heals", strings.Join(failed, ", "))
+			}
+			if cleared {
+				log.Println("Data consistent again; heals re-enabled")
+			}
+
+			healSuppressed = (updating && !c.Settings.HealDuringUpdate) || inconsistent.Active()
+
+			if autoHeal.Load() && mismatch.Active() && !healSuppressed {
 				threshold := time.Duration(c.Settings.OCPPMismatchSeconds) * time.Second
 				cooldown := time.Duration(c.Settings.OCPPRestartCooldown) * time.Second
 
-				if now.Sub(mismatchStart) >= threshold && (lastRestart.IsZero() || now.Sub(lastRestart) >= cooldown) {
+				if mismatch.HealDue(now, threshold) && (lastRestart.IsZero() || now.Sub(lastRestart) >= cooldown) {
 					// First try a bounded number of OCPP service restarts. If
 					// those do not clear the mismatch and full reboot is
 					// enabled, we can optionally escalate to a complete
 					// Wallbox reboot as a last resort.
 					if c.Settings.OCPPMaxRestarts == 0 || ocppRestartCount < c.Settings.OCPPMaxRestarts {
-						log.Printf("Restarting ocppwallbox.service after %s mismatch (OCPP %d: %s) [attempt %d/%d]",
-							now.Sub(mismatchStart).Round(time.Second), ocppCode, w.OCPPStatusDescription(), ocppRestartCount+1, c.Settings.OCPPMaxRestarts)
-						action, detail, err := restartCriticalServices()
+						log.Printf("Restarting %s after %s mismatch (OCPP %d: %s) [attempt %d/%d]",
+							healUnits, mismatch.Duration(now).Round(time.Second), ocppCode, w.OCPPStatusDescription(), ocppRestartCount+1, c.Settings.OCPPMaxRestarts)
+						action, detail, err := restartCriticalServices(heal)
 						ocppLastHealAction = action
 						ocppLastHealDetail = detail
 						ocppLastHealAt = now.Format(time.RFC3339)
+						publishHealEvent(newHealEvent(healEventAction(action), detail, ocppCode, now))
 						if err != nil {
 							log.Printf("Failed to restart charging stack: %v", err)
 							continue
 						}
 						ocppRestartCount++
+						ocppRestartTotal++
 						lastRestart = now
-						mismatchStart = now
+						mismatch.RestartTimer(now)
 						ocppLastRestart = now.Format(time.RFC3339)
 					} else if c.Settings.OCPPFullReboot {
 						// Only perform a full reboot if we have not recently done so.
 						if lastFullReboot.IsZero() || now.Sub(lastFullReboot) >= cooldown {
 							log.Printf("Escalating to full system reboot after %d failed OCPP restart attempts and %s mismatch (OCPP %d: %s)",
-								ocppRestartCount, now.Sub(mismatchStart).Round(time.Second), ocppCode, w.OCPPStatusDescription())
-							go func() {
-								if err := rebootSystem(); err != nil {
-									log.Printf("Failed to reboot system for OCPP heal: %v", err)
-								}
-							}()
+								ocppRestartCount, mismatch.Duration(now).Round(time.Second), ocppCode, w.OCPPStatusDescription())
+							detail := fmt.Sprintf("full reboot after %d OCPP restart attempts", ocppRestartCount)
+							publishHealEvent(newHealEvent(healActionEscalation, detail, ocppCode, now))
+							rebootForHeal(now, detail, "OCPP heal")
 							lastFullReboot = now
 						}
 					}
 				}
 			}
 
+			// Ghost session: OCPP/state machine claim Charging but no power
+			// flows. Optionally reuse the OCPP service restart to clear it.
+			if ghostSession.Update(now, isGhostSession(ocppCode, w.EffectiveStatus(), w.ChargingPower())) {
+				if ghostSessionState != "1" {
+					log.Printf("Ghost session detected: OCPP=%d (%s), status=%s, power=%.0fW for %ds",
+						ocppCode, w.OCPPStatusDescription(), w.EffectiveStatus(), w.ChargingPower(), c.Settings.GhostSessionSeconds)
+				}
+				ghostSessionState = "1"
+
+				if c.Settings.GhostSessionHeal && healDue(now) {
+					healRestart(now, "ghost session", ocppCode)
+					ghostSession.Reset()
+				}
+			} else {
+				if ghostSessionState != "0" {
+					log.Println("Ghost session cleared")
+				}
+				ghostSessionState = "0"
+			}
+
+			if c.Settings.StuckPreparingSeconds > 0 {
+				if stuckPreparing.Update(now, isStuckPreparing(ocppCode, pilotConnected)) {
+					if stuckPreparingState != "1" {
+						log.Printf("OCPP stuck in Preparing for %ds while the car is connected (pilot=%s)",
+							c.Settings.StuckPreparingSeconds, w.ControlPilotStatus())
+					}
+					stuckPreparingState = "1"
+
+					if c.Settings.StuckPreparingHeal && healDue(now) {
+						healRestart(now, "stuck preparing", ocppCode)
+						stuckPreparing.Reset()
+					}
+				} else {
+					if stuckPreparingState != "0" {
+						log.Println("OCPP stuck Preparing cleared")
+					}
+					stuckPreparingState = "0"
+				}
+			}
+
 			// Independent safety net: if control pilot reports error state 14 for a sustained period, reboot.
-			if c.Settings.PilotErrorReboot {
+			if c.Settings.PilotErrorReboot && !healSuppressed {
 				if w.ControlPilotCode() == 14 {
 					if pilotErrorStart.IsZero() {
 						pilotErrorStart = now
@@ -292,11 +892,9 @@ func RunBridge(configPath string) {
 					if now.Sub(pilotErrorStart) >= time.Duration(c.Settings.PilotErrorSeconds)*time.Second {
 						if lastPilotErrorReboot.IsZero() || now.Sub(lastPilotErrorReboot) >= time.Duration(c.Settings.PilotErrorSeconds)*time.Second {
 							log.Printf("Rebooting due to sustained control pilot error state 14 for %s", now.Sub(pilotErrorStart).Round(time.Second))
-							go func() {
-								if err := rebootSystem(); err != nil {
-									log.Printf("Failed to reboot after control pilot error: %v", err)
-								}
-							}()
+							detail := fmt.Sprintf("control pilot error state 14 for %s", now.Sub(pilotErrorStart).Round(time.Second))
+							publishHealEvent(newHealEvent(healActionReboot, detail, ocppCode, now))
+							rebootForHeal(now, detail, "control pilot error")
 							lastPilotErrorReboot = now
 							pilotErrorStart = time.Time{}
 						}
@@ -306,78 +904,514 @@ func RunBridge(configPath string) {
 				}
 			}
 
-			for key, val := range entityConfig {
-				payload := val.Getter()
-				bytePayload := []byte(fmt.Sprint(payload))
-				if published[key] != payload {
-					if val.RateLimit != nil && !val.RateLimit.Allow(strToFloat(payload)) {
-						continue
-					}
-					fmt.Println("Publishing: ", key, payload)
-					token := client.Publish(topicPrefix+"/"+key+"/state", 1, true, bytePayload)
-					token.Wait()
-					published[key] = payload
-				}
+			if newlyActive := discoverConditional(entityConfig, activeEntities); len(newlyActive) > 0 {
+				publishDiscovery(client, c, newlyActive, deviceID, firmwareVersion)
+			}
+
+			publishEntityAvailability(client, c, topicPrefix, activeEntities, entityAvailability)
+
+			forgetAlwaysPublished(activeEntities, published)
+			_, timedOut := publishChangedStates(publishFn, activeEntities, published, c.Settings.BatchPublish, publishTimeout(c))
+			checkPublishTimeouts(timedOut)
+
+			if status != nil {
+				status.Update(activeEntities, now)
+			}
+			if metrics != nil {
+				metrics.Update(activeEntities, w.LastTelemetry())
+			}
+
+			cycle := time.Since(cycleStart)
+			pollCycles.Record(cycle)
+			if interval := time.Duration(c.Settings.PollingIntervalSeconds) * time.Second; cycle > interval {
+				log.Printf("WARNING: poll cycle took %s, longer than the %s polling interval; the bridge can't keep up", cycle.Round(time.Millisecond), interval)
+			}
+		case <-eventPublish:
+			_, timedOut := publishChangedStates(publishFn, activeEntities, published, c.Settings.BatchPublish, publishTimeout(c))
+			checkPublishTimeouts(timedOut)
+		case <-reconnected:
+			// The broker may have lost its retained messages, so publish
+			// everything again, not just what changed.
+			publishDiscovery(client, c, activeEntities, deviceID, firmwareVersion)
+			if c.Settings.HealEvents && c.Settings.HealEventTriggers {
+				publishHealTriggers(client, c, deviceID, firmwareVersion)
 			}
+			waitPublish(client.Publish(availabilityTopic, 1, true, c.MQTT.PayloadAvailable), publishTimeout(c))
+			for key := range entityAvailability {
+				delete(entityAvailability, key)
+			}
+			publishEntityAvailability(client, c, topicPrefix, activeEntities, entityAvailability)
+			for key := range published {
+				delete(published, key)
+			}
+			_, timedOut := publishChangedStates(publishFn, activeEntities, published, c.Settings.BatchPublish, publishTimeout(c))
+			checkPublishTimeouts(timedOut)
+		case m := <-journalMatches:
+			payload, _ := json.Marshal(newJournalMatchEvent(m))
+			client.Publish(topicPrefix+"/events/journal/"+m.Name, 1, false, payload)
 		case <-interrupt:
 			fmt.Println("Interrupted. Exiting...")
-			token := client.Publish(availabilityTopic, 1, true, "offline")
-			token.Wait()
+			waitPublish(client.Publish(availabilityTopic, 1, true, c.MQTT.PayloadNotAvailable), publishTimeout(c))
 			client.Disconnect(250)
-			return
+			return nil
 		}
 	}
-
-	w.StopRedisSubscriptions()
 }
 
-func restartCriticalServices() (action string, detail string, err error) {
-	// Basic dependency sanity checks. If Redis/MySQL are down, restarting OCPP
-	// will likely flap; log but do not block the heal.
-	checkService := func(name string) {
-		if err := exec.Command("systemctl", "is-active", "--quiet", name).Run(); err != nil {
-			log.Printf("warning: dependency %s is not active: %v", name, err)
+// buildEntityConfig collects the charger entities enabled by the config.
+// Bridge-internal entities (OCPP heal state and friends) are added by
+// RunBridge on top of this.
+func buildEntityConfig(w *wallbox.Wallbox, c *WallboxConfig) map[string]Entity {
+	entityConfig := getEntities(w)
+	for k, v := range getTimeSyncEntities(w, c) {
+		entityConfig[k] = v
+	}
+	for k, v := range getTemperatureEntities(w, c) {
+		entityConfig[k] = v
+	}
+	for k, v := range getGSMEntities(w) {
+		entityConfig[k] = v
+	}
+	for k, v := range getEventStatsEntities(w) {
+		entityConfig[k] = v
+	}
+	for k, v := range getEfficiencyEntities(w) {
+		entityConfig[k] = v
+	}
+	for k, v := range getBackendHealthEntities(w) {
+		entityConfig[k] = v
+	}
+	for k, v := range getNetworkEntities(w) {
+		entityConfig[k] = v
+	}
+	for k, v := range getSystemResourceEntities(w) {
+		entityConfig[k] = v
+	}
+	for k, v := range getRemoteControlEntities(w) {
+		entityConfig[k] = v
+	}
+	for k, v := range getAutoLockEntities(w) {
+		entityConfig[k] = v
+	}
+	for k, v := range getPhaseCurrentLimitEntities(w) {
+		entityConfig[k] = v
+	}
+	if !w.OffDevice() {
+		// Heartbeats are only visible in the charger's own journal.
+		for k, v := range getOCPPHeartbeatEntities(w, c) {
+			entityConfig[k] = v
+		}
+	}
+	if c.Settings.ChargingProfiles {
+		for k, v := range getChargingProfileEntities(w, c) {
+			entityConfig[k] = v
+		}
+	}
+	if c.Settings.DebugSensors {
+		for k, v := range getDebugEntities(w) {
+			entityConfig[k] = v
+		}
+		for k, v := range getTelemetryEventEntities(w) {
+			// Telemetry-only sensors have no data on legacy firmware; mark
+			// them unavailable there instead of publishing zeros.
+			v.Available = func() bool { return w.HasTelemetry }
+			entityConfig[k] = v
+		}
+
+		// ocpp_status_sensors picks between the "<code>: <description>"
+		// sensor, the plain numeric one, or both (default).
+		switch c.Settings.OCPPStatusSensors {
+		case "code":
+			delete(entityConfig, "ocpp_status")
+		case "description":
+			delete(entityConfig, "ocpp_status_code")
 		}
 	}
-	checkService("redis.service")
-	checkService("mysqld.service")
 
-	services := []string{
-		"ocppwallbox.service",
+	if c.Settings.PowerBoostEnabled {
+		for k, v := range getPowerBoostEntities(w, c) {
+			entityConfig[k] = v
+		}
+	}
+
+	if c.Settings.PhaseEnergyEnabled {
+		for k, v := range getPhaseEnergyEntities(w) {
+			entityConfig[k] = v
+		}
+	}
+
+	if w.OffDevice() {
+		delete(entityConfig, "restart_wallbox")
+	}
+
+	if c.Settings.SoftStartSeconds > 0 && !w.OffDevice() {
+		applySoftStart(entityConfig, w, c)
 	}
 
-	for _, svc := range services {
-		// Prefer a graceful stop + start to let the service flush state.
-		stopCmd := exec.Command("systemctl", "stop", svc)
-		startCmd := exec.Command("systemctl", "start", svc)
+	applyPrecision(entityConfig, c.Settings.Precision)
 
-		stopErr := stopCmd.Run()
-		if stopErr == nil {
-			log.Printf("heal: stopped %s", svc)
-			if startErr := startCmd.Run(); startErr == nil {
-				log.Printf("heal: started %s", svc)
-				return "stop_start", fmt.Sprintf("%s stopped+started", svc), nil
+	if c.Settings.LazyDiscovery {
+		for key, e := range entityConfig {
+			if e.Component != "sensor" || e.Setter != nil || e.Condition != nil {
+				continue
 			}
-			log.Printf("heal: start %s failed after stop, will retry with restart", svc)
-		} else {
-			log.Printf("heal: stop %s failed (%v), will retry with restart", svc, stopErr)
+			getter := e.Getter
+			e.Condition = func() bool { return meaningfulValue(getter()) }
+			entityConfig[key] = e
+		}
+	}
+
+	return entityConfig
+}
+
+// applySoftStart wraps the charging_enable and max_charging_current setters
+// so enabling charging ramps the current up from soft_start_min_current to the
+// configured maximum over soft_start_seconds.
+func applySoftStart(entityConfig map[string]Entity, w *wallbox.Wallbox, c *WallboxConfig) {
+	// Two polls leave the charger state time to show the enable.
+	grace := 2 * time.Duration(c.Settings.PollingIntervalSeconds) * time.Second
+	ramp := newSoftStart(c.Settings.SoftStartMinCurrent, time.Duration(c.Settings.SoftStartSeconds)*time.Second, grace)
+
+	if e, ok := entityConfig["charging_enable"]; ok {
+		setter := e.Setter
+		e.Setter = func(val string) {
+			if strToInt(val) != 1 {
+				ramp.Disable(w, func() { setter(val) })
+				return
+			}
+			ramp.Enable(w, func() { setter(val) })
+		}
+		entityConfig["charging_enable"] = e
+	}
+
+	if e, ok := entityConfig["max_charging_current"]; ok {
+		setter := e.Setter
+		e.Setter = func(val string) {
+			// A manual change takes over from any running ramp.
+			ramp.SetCurrent(strToInt(val))
+			setter(val)
+		}
+		entityConfig["max_charging_current"] = e
+	}
+}
+
+// meaningfulValue reports whether a sensor value carries real data, as opposed
+// to the 0/"Unknown" placeholders published when the firmware lacks a source.
+func meaningfulValue(value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "Unknown" {
+		return false
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f != 0
+	}
+	return true
+}
+
+func mqttClientOptions(c *WallboxConfig, availabilityTopic string) (*mqtt.ClientOptions, error) {
+	tlsConfig, err := mqttTLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(mqttBrokerURL(c))
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+	opts.SetUsername(c.MQTT.Username)
+	opts.SetPassword(c.MQTT.Password)
+	opts.SetWill(availabilityTopic, c.MQTT.PayloadNotAvailable, 1, true)
+	opts.SetAutoReconnect(true)
+	opts.SetConnectRetry(true)
+	opts.SetConnectRetryInterval(time.Duration(c.MQTT.ConnectRetryIntervalSeconds) * time.Second)
+	opts.SetMaxReconnectInterval(time.Duration(c.MQTT.MaxReconnectIntervalSeconds) * time.Second)
+	return opts, nil
+}
+
+// Retry delays while the charger's MySQL/Redis are not reachable yet.
+const (
+	connectRetryDelay    = 5 * time.Second
+	maxConnectRetryDelay = time.Minute
+)
+
+// connectWallbox connects to the charger, retrying with a growing delay
+// until MySQL and Redis answer, e.g. while they start up during boot.
+func connectWallbox(cfg wallbox.Config) *wallbox.Wallbox {
+	delay := connectRetryDelay
+	for {
+		w, err := wallbox.NewWithConfig(cfg)
+		if err == nil {
+			return w
+		}
+		log.Printf("Cannot reach the charger yet: %v; retrying in %s", err, delay)
+		time.Sleep(delay)
+		if delay *= 2; delay > maxConnectRetryDelay {
+			delay = maxConnectRetryDelay
+		}
+	}
+}
+
+// openStore returns the file store when [persistence] asks for one, or nil
+// to keep the bridge's state in the charger's Redis.
+func openStore(c *WallboxConfig, configPath string) (wallbox.Store, error) {
+	switch c.Persistence.Backend {
+	case "", "redis":
+		return nil, nil
+	case "file":
+		path := c.Persistence.File
+		if path == "" {
+			path = filepath.Join(filepath.Dir(configPath), "bridge_state.json")
+		}
+		store, err := wallbox.NewFileStore(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", path, err)
+		}
+		log.Printf("Persisting bridge state in %s", path)
+		return store, nil
+	default:
+		log.Printf("Unknown persistence backend %q, using redis", c.Persistence.Backend)
+		return nil, nil
+	}
+}
+
+// publishDiscovery publishes the Home Assistant discovery config for every
+// entity under the wallbox_<serial> topic prefix.
+func publishDiscovery(client mqtt.Client, c *WallboxConfig, entityConfig map[string]Entity, serialNumber, firmwareVersion string) {
+	for key, val := range entityConfig {
+		uid := serialNumber + "_" + key
+		config := discoveryConfig(c, key, val, serialNumber, firmwareVersion)
+		if c.Settings.AbbreviatedDiscovery {
+			config = abbreviateDiscovery(config)
+		}
+		jsonPayload, _ := json.Marshal(config)
+		token := client.Publish("homeassistant/"+val.Component+"/"+uid+"/config", 1, true, jsonPayload)
+		if !waitPublish(token, publishTimeout(c)) {
+			log.Printf("Timed out publishing discovery for %s", key)
+		}
+	}
+}
+
+// deviceInfo is the Home Assistant device block shared by all discovery
+// payloads.
+func deviceInfo(c *WallboxConfig, serialNumber, firmwareVersion string) map[string]string {
+	device := map[string]string{
+		"identifiers": serialNumber,
+		"name":        c.Settings.DeviceName,
+		"sw_version":  fmt.Sprintf("%s (FW %s)", bridgeVersion(), firmwareVersion),
+	}
+	if c.configurationURL != "" {
+		device["configuration_url"] = c.configurationURL
+	}
+	return device
+}
+
+// discoveryConfig builds the Home Assistant discovery payload for one entity.
+func discoveryConfig(c *WallboxConfig, key string, val Entity, serialNumber, firmwareVersion string) map[string]interface{} {
+	topicPrefix := "wallbox_" + serialNumber
+	availabilityTopic := topicPrefix + "/availability"
+
+	config := map[string]interface{}{
+		"~":                     topicPrefix + "/" + key,
+		"availability_topic":    availabilityTopic,
+		"payload_available":     c.MQTT.PayloadAvailable,
+		"payload_not_available": c.MQTT.PayloadNotAvailable,
+		"state_topic":           "~/state",
+		"unique_id":             serialNumber + "_" + key,
+		"device":                deviceInfo(c, serialNumber, firmwareVersion),
+	}
+	if val.Available != nil {
+		// Entities with their own data source are only available while both
+		// the bridge and that source are.
+		delete(config, "availability_topic")
+		delete(config, "payload_available")
+		delete(config, "payload_not_available")
+		config["availability_mode"] = "all"
+		config["availability"] = []map[string]string{
+			{
+				"topic":                 availabilityTopic,
+				"payload_available":     c.MQTT.PayloadAvailable,
+				"payload_not_available": c.MQTT.PayloadNotAvailable,
+			},
+			{
+				"topic":                 topicPrefix + "/" + key + "/availability",
+				"payload_available":     c.MQTT.PayloadAvailable,
+				"payload_not_available": c.MQTT.PayloadNotAvailable,
+			},
+		}
+	}
+	if val.Setter != nil {
+		config["command_topic"] = "~/set"
+	}
+	if len(val.Options) > 0 {
+		config["options"] = val.Options
+	}
+	for k, v := range val.Config {
+		config[k] = v
+	}
+	return config
+}
+
+// publishEntityAvailability publishes the per-entity availability of every
+// entity with an Available func whose state changed since the last call.
+func publishEntityAvailability(client mqtt.Client, c *WallboxConfig, topicPrefix string, entities map[string]Entity, published map[string]bool) {
+	for key, e := range entities {
+		if e.Available == nil {
+			continue
+		}
+		available := e.Available()
+		if last, ok := published[key]; ok && last == available {
+			continue
+		}
+		payload := c.MQTT.PayloadNotAvailable
+		if available {
+			payload = c.MQTT.PayloadAvailable
+		}
+		client.Publish(topicPrefix+"/"+key+"/availability", 1, true, payload)
+		published[key] = available
+	}
+}
+
+// discoverConditional adds every entity from entityConfig that is not yet in
+// active and whose Condition (if any) holds to active, and returns just the
+// newly added ones so their discovery can be published. Entities stay active
+// once added.
+func discoverConditional(entityConfig map[string]Entity, active map[string]Entity) map[string]Entity {
+	added := make(map[string]Entity)
+	for key, e := range entityConfig {
+		if _, ok := active[key]; ok {
+			continue
+		}
+		if e.Condition == nil || e.Condition() {
+			active[key] = e
+			added[key] = e
+		}
+	}
+	return added
+}
+
+// publishChangedStates publishes every entity whose value changed since the
+// previous cycle and returns how many were sent. Without batch each publish
+// waits for the broker round-trip; with batch all publishes are fired first
+// and the tokens are awaited once at the end of the cycle.
+func publishChangedStates(publish func(key string, payload []byte) mqtt.Token, entityConfig map[string]Entity, published map[string]interface{}, batch bool, timeout time.Duration) (count, timedOut int) {
+	type pendingPublish struct {
+		key   string
+		token mqtt.Token
+	}
+	var pending []pendingPublish
+
+	// A publish that is not acknowledged in time is forgotten, so it is
+	// retried on the next cycle instead of being lost.
+	wait := func(key string, token mqtt.Token, timeout time.Duration) {
+		if !waitPublish(token, timeout) {
+			log.Printf("Timed out publishing %s after %s", key, timeout)
+			delete(published, key)
+			timedOut++
 		}
+	}
 
-		// If stop/start fails, fall back to a direct restart.
-		restartCmd := exec.Command("systemctl", "restart", svc)
-		if err := restartCmd.Run(); err != nil {
-			// As a final safeguard, invoke the Wallbox reboot flow.
-			log.Printf("restart %s failed (%v); escalating to full reboot", svc, err)
-			if rebootErr := rebootSystem(); rebootErr != nil {
-				return "reboot", fmt.Sprintf("reboot failed after restart error: %v", rebootErr), rebootErr
+	for key, val := range entityConfig {
+		payload := val.Value()
+		bytePayload := []byte(payload)
+		if published[key] != payload {
+			if val.RateLimit != nil && !val.AlwaysPublish && !val.RateLimit.Allow(strToFloat(payload)) {
+				continue
 			}
-			return "reboot", "reboot issued after restart failure", nil
+			fmt.Println("Publishing: ", key, payload)
+			token := publish(key, bytePayload)
+			published[key] = payload
+			count++
+			if batch {
+				pending = append(pending, pendingPublish{key, token})
+			} else {
+				wait(key, token, timeout)
+			}
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for _, p := range pending {
+		wait(p.key, p.token, time.Until(deadline))
+	}
+
+	return count, timedOut
+}
+
+// waitPublish waits up to timeout for token to complete and reports whether
+// it did. A non-positive timeout only checks whether it already has.
+func waitPublish(token mqtt.Token, timeout time.Duration) bool {
+	if timeout <= 0 {
+		select {
+		case <-token.Done():
+			return true
+		default:
+			return false
+		}
+	}
+	return token.WaitTimeout(timeout)
+}
+
+// publishTimeout is the configured time to wait for a publish acknowledgement.
+func publishTimeout(c *WallboxConfig) time.Duration {
+	return time.Duration(c.MQTT.PublishTimeoutSeconds) * time.Second
+}
+
+// maxPublishTimeoutCycles is how many consecutive cycles with timed out
+// publishes are tolerated before the broker connection is treated as lost.
+const maxPublishTimeoutCycles = 3
+
+const (
+	journalSnapshotLines      = 200
+	journalSnapshotLineBytes  = 1024
+	journalSnapshotChunkBytes = 16 * 1024
+)
+
+// publishJournalSnapshot publishes the last ocppwallbox journal lines to
+// topic so users can attach them to bug reports without SSH. Large snapshots
+// are split into "[i/n]"-prefixed messages.
+func publishJournalSnapshot(client mqtt.Client, topic string, timeout time.Duration) {
+	lines, err := wallbox.OCPPJournalSnapshot(journalSnapshotLines)
+	if err != nil {
+		log.Printf("Failed to capture OCPP journal snapshot: %v", err)
+		client.Publish(topic, 1, false, fmt.Sprintf("journal snapshot failed: %v", err))
+		return
+	}
+
+	chunks := chunkLines(lines, journalSnapshotLineBytes, journalSnapshotChunkBytes)
+	log.Printf("Publishing OCPP journal snapshot (%d lines, %d messages) to %s", len(lines), len(chunks), topic)
+	for i, chunk := range chunks {
+		token := client.Publish(topic, 1, false, fmt.Sprintf("[%d/%d]\n%s", i+1, len(chunks), chunk))
+		if !waitPublish(token, timeout) {
+			log.Printf("Timed out publishing OCPP journal snapshot, %d of %d messages sent", i, len(chunks))
+			return
+		}
+	}
+}
+
+// chunkLines truncates each line to maxLine bytes and joins them into
+// newline-separated chunks of at most maxChunk bytes.
+func chunkLines(lines []string, maxLine, maxChunk int) []string {
+	var chunks []string
+	var current strings.Builder
+
+	for _, line := range lines {
+		if len(line) > maxLine {
+			line = line[:maxLine] + "…"
 		}
-		log.Printf("heal: restarted %s via systemctl restart", svc)
-		return "restart", fmt.Sprintf("%s restarted", svc), nil
+		if current.Len() > 0 && current.Len()+1+len(line) > maxChunk {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteByte('\n')
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
 	}
 
-	return "noop", "no services to restart", nil
+	return chunks
 }
 
 // rebootSystem triggers the Wallbox-provided reboot flow. Prefer the vendor
@@ -0,0 +1,272 @@
+package bridge
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// delayedToken completes a fixed time after the publish that created it,
+// approximating a broker round-trip.
+type delayedToken struct {
+	done chan struct{}
+}
+
+func newDelayedToken(latency time.Duration) *delayedToken {
+	t := &delayedToken{done: make(chan struct{})}
+	time.AfterFunc(latency, func() { close(t.done) })
+	return t
+}
+
+func (t *delayedToken) Wait() bool { <-t.done; return true }
+func (t *delayedToken) WaitTimeout(d time.Duration) bool {
+	select {
+	case <-t.done:
+		return true
+	case <-time.After(d):
+		return false
+	}
+}
+func (t *delayedToken) Done() <-chan struct{} { return t.done }
+func (t *delayedToken) Error() error          { return nil }
+
+func testEntities(n int) map[string]Entity {
+	entities := make(map[string]Entity, n)
+	for i := 0; i < n; i++ {
+		value := fmt.Sprint(i)
+		entities[fmt.Sprintf("sensor_%d", i)] = Entity{
+			Component: "sensor",
+			Getter:    func() string { return value },
+		}
+	}
+	return entities
+}
+
+func TestPublishChangedStates_OnlyChanged(t *testing.T) {
+	entities := testEntities(3)
+	published := map[string]interface{}{"sensor_0": "0"}
+
+	for _, batch := range []bool{false, true} {
+		var sent []string
+		count, _ := publishChangedStates(func(key string, payload []byte) mqtt.Token {
+			sent = append(sent, key)
+			return newDelayedToken(0)
+		}, entities, copyPublished(published), batch, time.Second)
+
+		if count != 2 || len(sent) != 2 {
+			t.Fatalf("batch=%v: expected 2 changed states to be published, got count=%d sent=%v", batch, count, sent)
+		}
+	}
+}
+
+// stuckToken never completes, like a publish to a broker that stopped
+// responding without closing the connection.
+type stuckToken struct{ done chan struct{} }
+
+func (t stuckToken) Wait() bool                       { <-t.done; return true }
+func (t stuckToken) WaitTimeout(d time.Duration) bool { time.Sleep(d); return false }
+func (t stuckToken) Done() <-chan struct{}            { return t.done }
+func (t stuckToken) Error() error                     { return nil }
+
+func TestPublishChangedStates_Timeout(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	entities := testEntities(3)
+
+	for _, batch := range []bool{false, true} {
+		published := map[string]interface{}{}
+		start := time.Now()
+		count, timedOut := publishChangedStates(func(key string, payload []byte) mqtt.Token {
+			return stuckToken{done: make(chan struct{})}
+		}, entities, published, batch, timeout)
+
+		if count != 3 || timedOut != 3 {
+			t.Fatalf("batch=%v: expected 3 publishes to time out, got count=%d timedOut=%d", batch, count, timedOut)
+		}
+		if len(published) != 0 {
+			t.Fatalf("batch=%v: expected timed out states to be retried next cycle, got %v", batch, published)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("batch=%v: publishing hung for %s", batch, elapsed)
+		}
+	}
+}
+
+func copyPublished(src map[string]interface{}) map[string]interface{} {
+	dst := make(map[string]interface{}, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+func benchmarkPublishChangedStates(b *testing.B, batch bool) {
+	entities := testEntities(50)
+	for i := 0; i < b.N; i++ {
+		publishChangedStates(func(key string, payload []byte) mqtt.Token {
+			return newDelayedToken(time.Millisecond)
+		}, entities, map[string]interface{}{}, batch, time.Second)
+	}
+}
+
+func BenchmarkPublishChangedStatesSequential(b *testing.B) { benchmarkPublishChangedStates(b, false) }
+func BenchmarkPublishChangedStatesBatched(b *testing.B)    { benchmarkPublishChangedStates(b, true) }
+
+func TestMeaningfulValue(t *testing.T) {
+	cases := map[string]bool{
+		"":            false,
+		"0":           false,
+		"0.0":         false,
+		"Unknown":     false,
+		" 0 ":         false,
+		"-3.5":        true,
+		"230.1":       true,
+		"Charging":    true,
+		"Unknown (7)": true,
+	}
+
+	for value, want := range cases {
+		if got := meaningfulValue(value); got != want {
+			t.Errorf("meaningfulValue(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestDiscoverConditional(t *testing.T) {
+	ready := false
+	entities := map[string]Entity{
+		"always":  {Component: "sensor", Getter: func() string { return "1" }},
+		"pending": {Component: "sensor", Getter: func() string { return "1" }, Condition: func() bool { return ready }},
+	}
+	active := make(map[string]Entity)
+
+	if added := discoverConditional(entities, active); len(added) != 1 || len(active) != 1 {
+		t.Fatalf("expected only the unconditional entity at first, got %v", added)
+	}
+
+	ready = true
+	added := discoverConditional(entities, active)
+	if _, ok := added["pending"]; !ok || len(added) != 1 {
+		t.Fatalf("expected pending entity to be discovered once its condition holds, got %v", added)
+	}
+
+	ready = false
+	if added := discoverConditional(entities, active); len(added) != 0 || len(active) != 2 {
+		t.Fatalf("expected discovered entities to stay active, got added=%v active=%d", added, len(active))
+	}
+}
+
+func TestChunkLines(t *testing.T) {
+	lines := []string{"aaaa", "bbbb", "cccc", strings.Repeat("x", 20)}
+
+	chunks := chunkLines(lines, 8, 10)
+	want := []string{"aaaa\nbbbb", "cccc", "xxxxxxxx…"}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Fatalf("expected %q, got %q", want, chunks)
+	}
+
+	if chunks := chunkLines(nil, 8, 10); len(chunks) != 0 {
+		t.Fatalf("expected no chunks for no lines, got %q", chunks)
+	}
+}
+
+func TestApplyPrecision(t *testing.T) {
+	entities := map[string]Entity{
+		"charging_power": {Component: "sensor", Getter: func() string { return "7234.5678" }},
+		"status":         {Component: "sensor", Getter: func() string { return "Charging" }},
+		"untouched":      {Component: "sensor", Getter: func() string { return "1.23456" }},
+	}
+
+	applyPrecision(entities, "charging_power:1, status:0, missing:2, bogus")
+
+	cases := map[string]string{
+		"charging_power": "7234.6",
+		"status":         "Charging",
+		"untouched":      "1.23456",
+	}
+	for key, want := range cases {
+		if got := entities[key].Value(); got != want {
+			t.Errorf("%s: expected %q, got %q", key, want, got)
+		}
+	}
+}
+
+func TestAlwaysPublish(t *testing.T) {
+	entities := testEntities(3)
+	applyAlwaysPublish(entities, "sensor_1, missing")
+	if !entities["sensor_1"].AlwaysPublish || entities["sensor_0"].AlwaysPublish {
+		t.Fatalf("expected only sensor_1 to always publish")
+	}
+
+	published := make(map[string]interface{})
+	var sent []string
+	publish := func(key string, payload []byte) mqtt.Token {
+		sent = append(sent, key)
+		return newDelayedToken(0)
+	}
+	publishChangedStates(publish, entities, published, false, time.Second)
+
+	// Nothing changed: only the always-published entity is sent again.
+	sent = nil
+	forgetAlwaysPublished(entities, published)
+	publishChangedStates(publish, entities, published, false, time.Second)
+	if len(sent) != 1 || sent[0] != "sensor_1" {
+		t.Fatalf("expected only sensor_1 to be re-sent, got %v", sent)
+	}
+
+	applyAlwaysPublish(entities, "*")
+	for key, e := range entities {
+		if !e.AlwaysPublish {
+			t.Errorf("%s: expected * to select every entity", key)
+		}
+	}
+}
+
+func TestDiscoveryConfig_Availability(t *testing.T) {
+	var c WallboxConfig
+	c.applyDefaults()
+
+	shared := discoveryConfig(&c, "status", Entity{Component: "sensor"}, "123", "6.7.0")
+	if shared["availability_topic"] != "wallbox_123/availability" || shared["availability"] != nil {
+		t.Fatalf("expected only the bridge availability topic, got %v", shared)
+	}
+
+	own := discoveryConfig(&c, "mid_status", Entity{Component: "sensor", Available: func() bool { return false }}, "123", "6.7.0")
+	if _, ok := own["availability_topic"]; ok {
+		t.Fatalf("availability_topic must not be combined with an availability list")
+	}
+	if own["availability_mode"] != "all" {
+		t.Fatalf("expected availability_mode all, got %v", own["availability_mode"])
+	}
+	list, ok := own["availability"].([]map[string]string)
+	if !ok || len(list) != 2 {
+		t.Fatalf("expected two availability entries, got %v", own["availability"])
+	}
+	if list[0]["topic"] != "wallbox_123/availability" || list[1]["topic"] != "wallbox_123/mid_status/availability" {
+		t.Fatalf("unexpected availability topics: %v", list)
+	}
+	if list[1]["payload_available"] != "online" || list[1]["payload_not_available"] != "offline" {
+		t.Fatalf("expected configured payloads on the entity topic, got %v", list[1])
+	}
+}
+
+func TestControlPilotOverrides(t *testing.T) {
+	var c WallboxConfig
+	c.ControlPilot.States = "195:Connected 3, bogus, 196:"
+	c.ControlPilot.Letters = "195:B"
+	c.ControlPilot.Connected = "162:true, 195:maybe"
+
+	overrides := controlPilotOverrides(&c)
+	if !reflect.DeepEqual(overrides.States, map[int]string{195: "Connected 3"}) {
+		t.Fatalf("unexpected states: %v", overrides.States)
+	}
+	if overrides.Letters[195] != "B" {
+		t.Fatalf("unexpected letters: %v", overrides.Letters)
+	}
+	if !reflect.DeepEqual(overrides.Connected, map[int]bool{162: true}) {
+		t.Fatalf("unexpected connected: %v", overrides.Connected)
+	}
+}
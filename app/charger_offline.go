@@ -0,0 +1,88 @@
+package bridge
+
+import (
+	"sync"
+	"time"
+)
+
+// chargerOfflineMonitor flags the charger as offline once neither state
+// reads nor telemetry events have succeeded for longer than timeout. The
+// bridge's start time stands in for "last seen" until the charger has
+// answered once, so a charger that is already dead at startup still alerts.
+type chargerOfflineMonitor struct {
+	mu      sync.RWMutex
+	timeout time.Duration
+	started time.Time
+	offline bool
+}
+
+func newChargerOfflineMonitor(timeout time.Duration, now time.Time) *chargerOfflineMonitor {
+	return &chargerOfflineMonitor{timeout: timeout, started: now}
+}
+
+// Update re-evaluates the state from lastSeen and reports whether it
+// changed.
+func (m *chargerOfflineMonitor) Update(lastSeen, now time.Time) (changed bool) {
+	if lastSeen.IsZero() {
+		lastSeen = m.started
+	}
+	offline := now.Sub(lastSeen) > m.timeout
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed = offline != m.offline
+	m.offline = offline
+	return changed
+}
+
+// Offline reports whether the charger was offline at the last Update.
+func (m *chargerOfflineMonitor) Offline() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.offline
+}
+
+// chargerOfflineEvent is the payload published to
+// wallbox_<serial>/events/charger_offline when the charger goes offline or
+// comes back.
+type chargerOfflineEvent struct {
+	Offline  bool   `json:"offline"`
+	LastSeen string `json:"last_seen"`
+	At       string `json:"at"`
+}
+
+func formatLastSeen(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func getChargerOfflineEntities(monitor *chargerOfflineMonitor, lastSeen func() time.Time) map[string]Entity {
+	return map[string]Entity{
+		"charger_offline": {
+			Component: "binary_sensor",
+			Getter: func() string {
+				if monitor.Offline() {
+					return "1"
+				}
+				return "0"
+			},
+			Config: map[string]string{
+				"name":         "Charger offline",
+				"device_class": "problem",
+				"payload_on":   "1",
+				"payload_off":  "0",
+			},
+		},
+		"charger_last_seen": {
+			Component: "sensor",
+			Getter:    func() string { return formatLastSeen(lastSeen()) },
+			Config: map[string]string{
+				"name":            "Charger last seen",
+				"device_class":    "timestamp",
+				"entity_category": "diagnostic",
+			},
+		},
+	}
+}
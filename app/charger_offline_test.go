@@ -0,0 +1,42 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChargerOfflineMonitor(t *testing.T) {
+	t0 := time.Date(2025, 11, 23, 22, 0, 0, 0, time.UTC)
+	m := newChargerOfflineMonitor(5*time.Minute, t0)
+	var lastSeen time.Time
+	entities := getChargerOfflineEntities(m, func() time.Time { return lastSeen })
+
+	// Never seen: the bridge start time counts as last seen.
+	if m.Update(lastSeen, t0.Add(4*time.Minute)) || m.Offline() {
+		t.Fatalf("expected online within the timeout after start")
+	}
+	if got := entities["charger_last_seen"].Value(); got != "" {
+		t.Fatalf("expected no last-seen timestamp yet, got %q", got)
+	}
+	if !m.Update(lastSeen, t0.Add(6*time.Minute)) || !m.Offline() {
+		t.Fatalf("expected a charger that never answered to go offline")
+	}
+
+	lastSeen = t0.Add(7 * time.Minute)
+	if !m.Update(lastSeen, t0.Add(7*time.Minute)) || m.Offline() {
+		t.Fatalf("expected the charger back online once it answers")
+	}
+	if got := entities["charger_offline"].Value(); got != "0" {
+		t.Fatalf("expected charger_offline 0, got %q", got)
+	}
+	if got := entities["charger_last_seen"].Value(); got != "2025-11-23T22:07:00Z" {
+		t.Fatalf("unexpected last-seen timestamp %q", got)
+	}
+
+	if m.Update(lastSeen, t0.Add(11*time.Minute)) {
+		t.Fatalf("expected no change while within the timeout")
+	}
+	if !m.Update(lastSeen, t0.Add(13*time.Minute)) || entities["charger_offline"].Value() != "1" {
+		t.Fatalf("expected offline once state and telemetry are stale")
+	}
+}
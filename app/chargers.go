@@ -0,0 +1,103 @@
+package bridge
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// chargerSectionPrefix starts the name of a [wallbox.<id>] section.
+const chargerSectionPrefix = "wallbox."
+
+// ChargerConfig is one [wallbox.<id>] section. With several of them, one
+// bridge serves several chargers: each section says how to reach its
+// charger and everything else comes from the shared sections.
+type ChargerConfig struct {
+	ID string `ini:"-"`
+
+	MySQLHost     string `ini:"mysql_host"`
+	MySQLPort     int    `ini:"mysql_port"`
+	MySQLUsername string `ini:"mysql_username"`
+	MySQLPassword string `ini:"mysql_password"`
+	MySQLDatabase string `ini:"mysql_database"`
+
+	RedisAddr     string `ini:"redis_addr"`
+	RedisPassword string `ini:"redis_password"`
+	RedisDB       int    `ini:"redis_db"`
+
+	DeviceIDOverride string `ini:"device_id_override"`
+	DeviceName       string `ini:"device_name"`
+
+	// Listen addresses and state files can't be shared between chargers,
+	// so the [settings]/[metrics]/[persistence] ones are not inherited.
+	StatusPageAddr   string `ini:"status_page_addr"`
+	MetricsListen    string `ini:"metrics_listen"`
+	PersistenceFile  string `ini:"persistence_file"`
+	ReapplyStateFile string `ini:"reapply_state_file"`
+}
+
+// chargerConfigs returns the config of every charger to run: c itself
+// without [wallbox.<id>] sections, otherwise a copy of c per section with
+// the section applied on top.
+func chargerConfigs(c *WallboxConfig, configPath string) []*WallboxConfig {
+	if len(c.Chargers) == 0 {
+		return []*WallboxConfig{c}
+	}
+	if c.Settings.StatusPageAddr != "" || c.Metrics.Listen != "" {
+		log.Println("Running several chargers: ignoring the shared status_page_addr and [metrics] listen, set them per [wallbox.<id>] section")
+	}
+
+	dir := filepath.Dir(configPath)
+	configs := make([]*WallboxConfig, 0, len(c.Chargers))
+	for _, charger := range c.Chargers {
+		cc := *c
+		cc.Chargers = nil
+
+		if charger.MySQLHost != "" {
+			cc.MySQL.Host = charger.MySQLHost
+		}
+		if charger.MySQLPort != 0 {
+			cc.MySQL.Port = charger.MySQLPort
+		}
+		if charger.MySQLUsername != "" {
+			cc.MySQL.Username = charger.MySQLUsername
+		}
+		if charger.MySQLPassword != "" {
+			cc.MySQL.Password = charger.MySQLPassword
+		}
+		if charger.MySQLDatabase != "" {
+			cc.MySQL.Database = charger.MySQLDatabase
+		}
+		if charger.RedisAddr != "" {
+			cc.Redis.Addr = charger.RedisAddr
+		}
+		if charger.RedisPassword != "" {
+			cc.Redis.Password = charger.RedisPassword
+		}
+		if charger.RedisDB != 0 {
+			cc.Redis.DB = charger.RedisDB
+		}
+
+		if charger.DeviceIDOverride != "" {
+			cc.Settings.DeviceIDOverride = charger.DeviceIDOverride
+		}
+		cc.Settings.DeviceName = charger.DeviceName
+		if cc.Settings.DeviceName == "" {
+			cc.Settings.DeviceName = strings.TrimSpace(c.Settings.DeviceName + " " + charger.ID)
+		}
+
+		cc.Settings.StatusPageAddr = charger.StatusPageAddr
+		cc.Metrics.Listen = charger.MetricsListen
+		cc.Persistence.File = charger.PersistenceFile
+		if cc.Persistence.File == "" {
+			cc.Persistence.File = filepath.Join(dir, "bridge_state_"+charger.ID+".json")
+		}
+		cc.Settings.ReapplyStateFile = charger.ReapplyStateFile
+		if cc.Settings.ReapplyStateFile == "" {
+			cc.Settings.ReapplyStateFile = filepath.Join(dir, "intended_settings_"+charger.ID+".json")
+		}
+
+		configs = append(configs, &cc)
+	}
+	return configs
+}
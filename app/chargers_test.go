@@ -0,0 +1,66 @@
+package bridge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChargerConfigs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bridge.ini")
+	ini := `[mysql]
+username = root
+password = secret
+
+[settings]
+device_name = Wallbox
+status_page_addr = :8080
+
+[wallbox.garage]
+mysql_host = 192.168.1.10
+redis_addr = 192.168.1.10:6379
+
+[wallbox.carport]
+mysql_host = 192.168.1.11
+redis_addr = 192.168.1.11:6379
+device_name = Carport
+status_page_addr = :8081
+`
+	if err := os.WriteFile(path, []byte(ini), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := LoadConfig(path)
+	c.applyDefaults()
+
+	configs := chargerConfigs(c, path)
+	if len(configs) != 2 {
+		t.Fatalf("expected 2 chargers, got %d", len(configs))
+	}
+	garage, carport := configs[0], configs[1]
+	if garage.MySQL.Host != "192.168.1.10" || garage.MySQL.Username != "root" || garage.MySQL.Password != "secret" {
+		t.Fatalf("garage MySQL not overlaid on the shared section: %+v", garage.MySQL)
+	}
+	if garage.Redis.Addr != "192.168.1.10:6379" || carport.Redis.Addr != "192.168.1.11:6379" {
+		t.Fatalf("unexpected Redis addresses %q, %q", garage.Redis.Addr, carport.Redis.Addr)
+	}
+	if garage.Settings.DeviceName != "Wallbox garage" || carport.Settings.DeviceName != "Carport" {
+		t.Fatalf("unexpected device names %q, %q", garage.Settings.DeviceName, carport.Settings.DeviceName)
+	}
+	if garage.Settings.StatusPageAddr != "" || carport.Settings.StatusPageAddr != ":8081" {
+		t.Fatalf("the shared status page address must not be inherited: %q, %q", garage.Settings.StatusPageAddr, carport.Settings.StatusPageAddr)
+	}
+	if garage.Persistence.File == carport.Persistence.File || garage.Settings.ReapplyStateFile == carport.Settings.ReapplyStateFile {
+		t.Fatalf("chargers must not share state files")
+	}
+	if c.MySQL.Host != "" {
+		t.Fatalf("the shared config was modified: %+v", c.MySQL)
+	}
+}
+
+func TestChargerConfigsSingle(t *testing.T) {
+	c := &WallboxConfig{}
+	if configs := chargerConfigs(c, "bridge.ini"); len(configs) != 1 || configs[0] != c {
+		t.Fatalf("expected the config itself without [wallbox.<id>] sections")
+	}
+}
@@ -0,0 +1,237 @@
+package bridge
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"wallbox-mqtt-bridge/app/wallbox"
+)
+
+// Charging profile names offered by the charging_profile select.
+const (
+	profileFast      = "Fast"
+	profileSolarOnly = "Solar Only"
+	profileSolarGrid = "Solar + Grid"
+	profileScheduled = "Scheduled"
+	profileCustom    = "Custom" // current settings match no profile
+)
+
+var chargingProfileNames = []string{profileFast, profileSolarOnly, profileSolarGrid, profileScheduled}
+
+// defaultChargingProfiles are used for profiles not set in [profiles].
+var defaultChargingProfiles = map[string]string{
+	profileFast:      "current:max, ecosmart:off, schedules:off",
+	profileSolarOnly: "ecosmart:full_solar, schedules:off",
+	profileSolarGrid: "ecosmart:eco, schedules:off",
+	profileScheduled: "ecosmart:off, schedules:on",
+}
+
+// chargingProfile is one combination of settings applied by the
+// charging_profile select. Empty/zero fields are left untouched.
+type chargingProfile struct {
+	Current    int  // amps; 0 = unchanged
+	MaxCurrent bool // use the charger's available current
+	Ecosmart   string
+	Schedules  string // "on", "off" or ""
+}
+
+// parseChargingProfile parses "current:16, ecosmart:eco, schedules:off".
+// current also accepts "max".
+func parseChargingProfile(spec string) (chargingProfile, error) {
+	var p chargingProfile
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, found := strings.Cut(entry, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !found {
+			return p, fmt.Errorf("invalid entry %q, expected key:value", entry)
+		}
+
+		switch key {
+		case "current":
+			if value == "max" {
+				p.MaxCurrent = true
+				continue
+			}
+			current, err := strconv.Atoi(value)
+			if err != nil || current <= 0 {
+				return p, fmt.Errorf("invalid current %q", value)
+			}
+			p.Current = current
+		case "ecosmart":
+			switch value {
+			case wallbox.EcosmartOff, wallbox.EcosmartEco, wallbox.EcosmartFullSolar:
+				p.Ecosmart = value
+			default:
+				return p, fmt.Errorf("invalid ecosmart mode %q", value)
+			}
+		case "schedules":
+			if value != "on" && value != "off" {
+				return p, fmt.Errorf("invalid schedules value %q, expected on or off", value)
+			}
+			p.Schedules = value
+		default:
+			return p, fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	return p, nil
+}
+
+// loadChargingProfiles returns the profiles from config, falling back to the
+// defaults for unset or invalid ones.
+func loadChargingProfiles(c *WallboxConfig) map[string]chargingProfile {
+	configured := map[string]string{
+		profileFast:      c.Profiles.Fast,
+		profileSolarOnly: c.Profiles.SolarOnly,
+		profileSolarGrid: c.Profiles.SolarGrid,
+		profileScheduled: c.Profiles.Scheduled,
+	}
+
+	profiles := make(map[string]chargingProfile, len(chargingProfileNames))
+	for _, name := range chargingProfileNames {
+		if spec := configured[name]; spec != "" {
+			p, err := parseChargingProfile(spec)
+			if err == nil {
+				profiles[name] = p
+				continue
+			}
+			log.Printf("Ignoring charging profile %q: %v", name, err)
+		}
+		profiles[name], _ = parseChargingProfile(defaultChargingProfiles[name])
+	}
+	return profiles
+}
+
+// profileTarget is what a charging profile acts on; *wallbox.Wallbox in
+// production.
+type profileTarget interface {
+	AvailableCurrent() int
+	SetMaxChargingCurrent(current int)
+	SetEcosmartMode(mode string) error
+	SetSchedulesEnabled(enabled bool) error
+}
+
+// applyChargingProfile applies every setting the profile defines.
+func applyChargingProfile(p chargingProfile, target profileTarget) error {
+	if p.MaxCurrent {
+		target.SetMaxChargingCurrent(target.AvailableCurrent())
+	} else if p.Current > 0 {
+		target.SetMaxChargingCurrent(p.Current)
+	}
+	if p.Ecosmart != "" {
+		if err := target.SetEcosmartMode(p.Ecosmart); err != nil {
+			return fmt.Errorf("setting EcoSmart mode: %w", err)
+		}
+	}
+	if p.Schedules != "" {
+		if err := target.SetSchedulesEnabled(p.Schedules == "on"); err != nil {
+			return fmt.Errorf("setting schedules: %w", err)
+		}
+	}
+	return nil
+}
+
+// chargerSettings is the current state profiles are matched against.
+type chargerSettings struct {
+	Current          int
+	AvailableCurrent int
+	Ecosmart         string
+	SchedulesEnabled bool
+}
+
+func (p chargingProfile) matches(s chargerSettings) bool {
+	if p.MaxCurrent && s.Current != s.AvailableCurrent {
+		return false
+	}
+	if p.Current > 0 && s.Current != p.Current {
+		return false
+	}
+	if p.Ecosmart != "" && p.Ecosmart != s.Ecosmart {
+		return false
+	}
+	if p.Schedules != "" && (p.Schedules == "on") != s.SchedulesEnabled {
+		return false
+	}
+	return true
+}
+
+// detectChargingProfile returns the first profile (in select order) matching
+// the current settings, or profileCustom.
+func detectChargingProfile(profiles map[string]chargingProfile, s chargerSettings) string {
+	for _, name := range chargingProfileNames {
+		if p, ok := profiles[name]; ok && p.matches(s) {
+			return name
+		}
+	}
+	return profileCustom
+}
+
+// profileWrites says which charger settings can be written; *wallbox.Wallbox
+// in production.
+type profileWrites interface {
+	EcosmartWritable() bool
+	SchedulesWritable() bool
+}
+
+// usableChargingProfiles drops the profiles that change a setting this
+// charger can't write, and returns the select options for the rest.
+func usableChargingProfiles(profiles map[string]chargingProfile, writes profileWrites) []string {
+	var options []string
+	for _, name := range chargingProfileNames {
+		p, ok := profiles[name]
+		if !ok {
+			continue
+		}
+		if (p.Ecosmart != "" && !writes.EcosmartWritable()) || (p.Schedules != "" && !writes.SchedulesWritable()) {
+			log.Printf("Leaving out charging profile %q: this charger's EcoSmart or schedule setting can't be written", name)
+			delete(profiles, name)
+			continue
+		}
+		options = append(options, name)
+	}
+	return options
+}
+
+// getChargingProfileEntities adds the charging_profile select with the
+// profiles this charger can apply.
+func getChargingProfileEntities(w *wallbox.Wallbox, c *WallboxConfig) map[string]Entity {
+	profiles := loadChargingProfiles(c)
+	options := usableChargingProfiles(profiles, w)
+	if len(options) == 0 {
+		return nil
+	}
+
+	return map[string]Entity{
+		"charging_profile": {
+			Component: "select",
+			Getter: func() string {
+				return detectChargingProfile(profiles, chargerSettings{
+					Current:          w.Data.SQL.MaxChargingCurrent,
+					AvailableCurrent: w.AvailableCurrent(),
+					Ecosmart:         w.EcosmartModeName(),
+					SchedulesEnabled: w.SchedulesEnabled(),
+				})
+			},
+			Setter: func(val string) {
+				p, ok := profiles[val]
+				if !ok {
+					log.Printf("Ignoring charging profile %q", val)
+					return
+				}
+				if err := applyChargingProfile(p, w); err != nil {
+					log.Printf("Failed to apply charging profile %q: %v", val, err)
+				}
+			},
+			Options: append(options, profileCustom),
+			Config: map[string]string{
+				"name": "Charging profile",
+				"icon": "mdi:car-electric",
+			},
+		},
+	}
+}
@@ -0,0 +1,174 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"wallbox-mqtt-bridge/app/wallbox"
+)
+
+// fakeProfileTarget records the actions a profile applies.
+type fakeProfileTarget struct {
+	available int
+	actions   []string
+	failEco   bool
+}
+
+func (f *fakeProfileTarget) AvailableCurrent() int { return f.available }
+
+func (f *fakeProfileTarget) SetMaxChargingCurrent(current int) {
+	f.actions = append(f.actions, fmt.Sprintf("current=%d", current))
+}
+
+func (f *fakeProfileTarget) SetEcosmartMode(mode string) error {
+	if f.failEco {
+		return errors.New("no such column")
+	}
+	f.actions = append(f.actions, "ecosmart="+mode)
+	return nil
+}
+
+func (f *fakeProfileTarget) SetSchedulesEnabled(enabled bool) error {
+	if enabled {
+		f.actions = append(f.actions, "schedules=on")
+	} else {
+		f.actions = append(f.actions, "schedules=off")
+	}
+	return nil
+}
+
+func TestApplyChargingProfile_Defaults(t *testing.T) {
+	var c WallboxConfig
+	profiles := loadChargingProfiles(&c)
+
+	cases := map[string][]string{
+		profileFast:      {"current=32", "ecosmart=off", "schedules=off"},
+		profileSolarOnly: {"ecosmart=full_solar", "schedules=off"},
+		profileSolarGrid: {"ecosmart=eco", "schedules=off"},
+		profileScheduled: {"ecosmart=off", "schedules=on"},
+	}
+	for name, want := range cases {
+		target := &fakeProfileTarget{available: 32}
+		if err := applyChargingProfile(profiles[name], target); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if !reflect.DeepEqual(target.actions, want) {
+			t.Errorf("%s: expected actions %v, got %v", name, want, target.actions)
+		}
+	}
+}
+
+func TestApplyChargingProfile_Configured(t *testing.T) {
+	var c WallboxConfig
+	c.Profiles.Fast = "current:16"
+	c.Profiles.SolarOnly = "ecosmart:sunshine" // invalid, default is kept
+	profiles := loadChargingProfiles(&c)
+
+	target := &fakeProfileTarget{available: 32}
+	applyChargingProfile(profiles[profileFast], target)
+	if want := []string{"current=16"}; !reflect.DeepEqual(target.actions, want) {
+		t.Fatalf("expected %v, got %v", want, target.actions)
+	}
+
+	target = &fakeProfileTarget{}
+	applyChargingProfile(profiles[profileSolarOnly], target)
+	if want := []string{"ecosmart=full_solar", "schedules=off"}; !reflect.DeepEqual(target.actions, want) {
+		t.Fatalf("expected the default Solar Only profile, got %v", target.actions)
+	}
+
+	target = &fakeProfileTarget{failEco: true}
+	if err := applyChargingProfile(profiles[profileSolarGrid], target); err == nil {
+		t.Fatalf("expected an error when EcoSmart cannot be set")
+	}
+}
+
+func TestParseChargingProfile_Invalid(t *testing.T) {
+	for _, spec := range []string{"current:0", "current:lots", "schedules:maybe", "boost:on", "ecosmart"} {
+		if _, err := parseChargingProfile(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestDetectChargingProfile(t *testing.T) {
+	var c WallboxConfig
+	profiles := loadChargingProfiles(&c)
+
+	cases := []struct {
+		settings chargerSettings
+		want     string
+	}{
+		{chargerSettings{Current: 32, AvailableCurrent: 32, Ecosmart: "off"}, profileFast},
+		{chargerSettings{Current: 16, AvailableCurrent: 32, Ecosmart: "off"}, profileCustom},
+		{chargerSettings{Current: 16, AvailableCurrent: 32, Ecosmart: "full_solar"}, profileSolarOnly},
+		{chargerSettings{Current: 32, AvailableCurrent: 32, Ecosmart: "eco"}, profileSolarGrid},
+		{chargerSettings{Current: 32, AvailableCurrent: 32, Ecosmart: "off", SchedulesEnabled: true}, profileScheduled},
+		{chargerSettings{Current: 32, AvailableCurrent: 32, Ecosmart: "unknown"}, profileCustom},
+	}
+	for _, tc := range cases {
+		if got := detectChargingProfile(profiles, tc.settings); got != tc.want {
+			t.Errorf("detectChargingProfile(%+v) = %q, want %q", tc.settings, got, tc.want)
+		}
+	}
+}
+
+// fakeProfileWrites says which settings a charger can write.
+type fakeProfileWrites struct{ ecosmart, schedules bool }
+
+func (f fakeProfileWrites) EcosmartWritable() bool  { return f.ecosmart }
+func (f fakeProfileWrites) SchedulesWritable() bool { return f.schedules }
+
+func TestUsableChargingProfiles(t *testing.T) {
+	var c WallboxConfig
+	c.Profiles.Fast = "current:max"
+	c.Profiles.Scheduled = "schedules:on"
+
+	profiles := loadChargingProfiles(&c)
+	options := usableChargingProfiles(profiles, fakeProfileWrites{schedules: true})
+	if want := []string{profileFast, profileScheduled}; !reflect.DeepEqual(options, want) {
+		t.Fatalf("expected only the profiles without EcoSmart, %v, got %v", want, options)
+	}
+	if _, ok := profiles[profileSolarOnly]; ok {
+		t.Fatalf("expected unusable profiles to be dropped so they are never detected")
+	}
+
+	profiles = loadChargingProfiles(&c)
+	if options := usableChargingProfiles(profiles, fakeProfileWrites{ecosmart: true, schedules: true}); len(options) != len(chargingProfileNames) {
+		t.Fatalf("expected every profile when all settings can be written, got %v", options)
+	}
+}
+
+func TestEcosmartSelect(t *testing.T) {
+	w := wallbox.NewStub()
+	entity := getEntities(w)["ecosmart"]
+	if entity.Condition() {
+		t.Fatal("expected the EcoSmart select not to be offered without a validated write")
+	}
+	if entity.Available() {
+		t.Fatal("expected the EcoSmart select to be unavailable without telemetry")
+	}
+
+	w.HasTelemetry = true
+	w.Data.RedisTelemetry.EcosmartStatus = 1
+	w.Data.RedisTelemetry.EcosmartMode = 1
+	if !entity.Available() {
+		t.Fatal("expected the EcoSmart select to be available with telemetry")
+	}
+	got := entity.Value()
+	found := false
+	for _, option := range entity.Options {
+		found = found || option == got
+	}
+	if got != wallbox.EcosmartFullSolar || !found {
+		t.Fatalf("expected %q among the options %v, got %q", wallbox.EcosmartFullSolar, entity.Options, got)
+	}
+}
+
+func TestSchedulesSwitch(t *testing.T) {
+	entity := getEntities(wallbox.NewStub())["schedules_enabled"]
+	if entity.Condition == nil || entity.Condition() {
+		t.Fatal("expected the schedules switch not to be offered before the schedules were read and the write validated")
+	}
+}
@@ -0,0 +1,41 @@
+package bridge
+
+import (
+	"sync"
+	"time"
+)
+
+// coalescer collapses a burst of triggers into a single call of fire. The
+// first trigger schedules fire after window; triggers arriving before it runs
+// are absorbed. fire reads current values, so the last state of the burst is
+// always what gets published, and a trigger after fire started schedules
+// another call.
+type coalescer struct {
+	window time.Duration
+	fire   func()
+
+	mu      sync.Mutex
+	pending bool
+}
+
+func newCoalescer(window time.Duration, fire func()) *coalescer {
+	return &coalescer{window: window, fire: fire}
+}
+
+// Trigger schedules fire unless a call is already pending.
+func (c *coalescer) Trigger() {
+	c.mu.Lock()
+	if c.pending {
+		c.mu.Unlock()
+		return
+	}
+	c.pending = true
+	c.mu.Unlock()
+
+	time.AfterFunc(c.window, func() {
+		c.mu.Lock()
+		c.pending = false
+		c.mu.Unlock()
+		c.fire()
+	})
+}
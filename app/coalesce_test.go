@@ -0,0 +1,50 @@
+package bridge
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoalescer_Burst(t *testing.T) {
+	const window = 30 * time.Millisecond
+
+	var mu sync.Mutex
+	value, fires := 0, 0
+	var publishedValues []int
+	c := newCoalescer(window, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		fires++
+		publishedValues = append(publishedValues, value)
+	})
+
+	// A burst of 50 events well inside one window.
+	for i := 1; i <= 50; i++ {
+		mu.Lock()
+		value = i
+		mu.Unlock()
+		c.Trigger()
+	}
+	time.Sleep(3 * window)
+
+	mu.Lock()
+	if fires != 1 {
+		t.Fatalf("expected the burst to be coalesced into 1 publish, got %d", fires)
+	}
+	if publishedValues[0] != 50 {
+		t.Fatalf("expected the final value 50 to be published, got %d", publishedValues[0])
+	}
+	value = 51
+	mu.Unlock()
+
+	// A later event is published on its own.
+	c.Trigger()
+	time.Sleep(3 * window)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fires != 2 || publishedValues[1] != 51 {
+		t.Fatalf("expected a second publish of 51, got fires=%d values=%v", fires, publishedValues)
+	}
+}
@@ -1,6 +1,8 @@
 package bridge
 
 import (
+	"strings"
+
 	"gopkg.in/ini.v1"
 )
 
@@ -10,21 +12,179 @@ type WallboxConfig struct {
 		Port     int    `ini:"port"`
 		Username string `ini:"username"`
 		Password string `ini:"password"`
+
+		PayloadAvailable    string `ini:"payload_available"`
+		PayloadNotAvailable string `ini:"payload_not_available"`
+
+		PublishTimeoutSeconds int `ini:"publish_timeout_seconds"`
+
+		// TLS switches to ssl://; the certificate files are PEM encoded.
+		TLS                bool   `ini:"tls"`
+		CACert             string `ini:"ca_cert"`
+		ClientCert         string `ini:"client_cert"`
+		ClientKey          string `ini:"client_key"`
+		InsecureSkipVerify bool   `ini:"insecure_skip_verify"`
+
+		// Bounds of the delay between attempts to (re)connect to the broker.
+		ConnectRetryIntervalSeconds int `ini:"connect_retry_interval_seconds"`
+		MaxReconnectIntervalSeconds int `ini:"max_reconnect_interval_seconds"`
 	} `ini:"mqtt"`
 
+	// MySQL says how to reach the charger's database; empty fields use the
+	// on-device defaults (see wallbox.DefaultMySQLConfig).
+	MySQL struct {
+		Host     string `ini:"host"`
+		Port     int    `ini:"port"`
+		Username string `ini:"username"`
+		Password string `ini:"password"`
+		Database string `ini:"database"`
+	} `ini:"mysql"`
+
+	// Redis says how to reach the charger's Redis; an empty addr uses the
+	// on-device default localhost:6379.
+	Redis struct {
+		Addr     string `ini:"addr"`
+		Password string `ini:"password"`
+		DB       int    `ini:"db"`
+
+		FirmwareVersionKey   string `ini:"firmware_version_key"`
+		FirmwareVersionField string `ini:"firmware_version_field"`
+	} `ini:"redis"`
+
+	// Persistence picks where the bridge keeps its own counters: the
+	// charger's Redis (default) or a local JSON file.
+	Persistence struct {
+		Backend   string `ini:"backend"`
+		KeyPrefix string `ini:"key_prefix"`
+		File      string `ini:"file"`
+	} `ini:"persistence"`
+
+	// Metrics serves Prometheus metrics on listen; empty disables it.
+	Metrics struct {
+		Listen string `ini:"listen"`
+	} `ini:"metrics"`
+
 	Settings struct {
-		PollingIntervalSeconds int    `ini:"polling_interval_seconds"`
-		DeviceName             string `ini:"device_name"`
-		DebugSensors           bool   `ini:"debug_sensors"`
-		PowerBoostEnabled      bool   `ini:"power_boost_enabled"`
-		AutoRestartOCPP        bool   `ini:"auto_restart_ocpp"`
-		OCPPMismatchSeconds    int    `ini:"ocpp_mismatch_seconds"`
-		OCPPRestartCooldown    int    `ini:"ocpp_restart_cooldown_seconds"`
-		OCPPMaxRestarts        int    `ini:"ocpp_max_restarts"`
-		OCPPFullReboot         bool   `ini:"ocpp_full_reboot"`
-		PilotErrorReboot       bool   `ini:"pilot_error_reboot"`
-		PilotErrorSeconds      int    `ini:"pilot_error_seconds"`
+		PollingIntervalSeconds   int    `ini:"polling_interval_seconds"`
+		DeviceIDOverride         string `ini:"device_id_override"`
+		DeviceName               string `ini:"device_name"`
+		DebugSensors             bool   `ini:"debug_sensors"`
+		PowerBoostEnabled        bool   `ini:"power_boost_enabled"`
+		PhaseEnergyEnabled       bool   `ini:"phase_energy_enabled"`
+		AutoRestartOCPP          bool   `ini:"auto_restart_ocpp"`
+		AutoRestartOCPPDryRun    bool   `ini:"auto_restart_ocpp_dry_run"`
+		OCPPMismatchSeconds      int    `ini:"ocpp_mismatch_seconds"`
+		OCPPMismatchClearSeconds int    `ini:"ocpp_mismatch_clear_seconds"`
+		OCPPRestartCooldown      int    `ini:"ocpp_restart_cooldown_seconds"`
+		OCPPMaxRestarts          int    `ini:"ocpp_max_restarts"`
+		OCPPHeartbeatTimeout     int    `ini:"ocpp_heartbeat_timeout_seconds"`
+		ChargerOfflineAlert      bool   `ini:"charger_offline_alert"`
+		ChargerOfflineTimeout    int    `ini:"charger_offline_timeout_seconds"`
+		OCPPFullReboot           bool   `ini:"ocpp_full_reboot"`
+		PilotErrorReboot         bool   `ini:"pilot_error_reboot"`
+		PilotErrorSeconds        int    `ini:"pilot_error_seconds"`
+		GhostSessionSeconds      int    `ini:"ghost_session_seconds"`
+		GhostSessionHeal         bool   `ini:"ghost_session_heal"`
+		StuckPreparingSeconds    int    `ini:"stuck_preparing_seconds"`
+		StuckPreparingHeal       bool   `ini:"stuck_preparing_heal"`
+		HealEvents               bool   `ini:"heal_events"`
+		OCPPServiceName          string `ini:"ocpp_service_name"`
+		RedisServiceName         string `ini:"redis_service_name"`
+		MySQLServiceName         string `ini:"mysql_service_name"`
+		HealEventTriggers        bool   `ini:"heal_event_triggers"`
+		HealDuringUpdate         bool   `ini:"heal_during_update"`
+		ConsistencyChecks        string `ini:"consistency_checks"`
+		ConsistencyClearSeconds  int    `ini:"consistency_clear_seconds"`
+		UpdateBusyStates         string `ini:"update_busy_states"`
+		OCPPStatusSensors        string `ini:"ocpp_status_sensors"`
+		OCPPPrecedence           string `ini:"ocpp_precedence"`
+		TelemetryTriggers        string `ini:"telemetry_triggers"`
+		ReapplyAfterReboot       bool   `ini:"reapply_after_reboot"`
+		ReapplyStateFile         string `ini:"reapply_state_file"`
+		BatchPublish             bool   `ini:"batch_publish"`
+		EventPublishDebounceMs   int    `ini:"event_publish_debounce_ms"`
+		TimeSyncThreshold        int    `ini:"time_sync_threshold_seconds"`
+		LazyDiscovery            bool   `ini:"lazy_discovery"`
+		AbbreviatedDiscovery     bool   `ini:"abbreviated_discovery"`
+		ChargingMode             string `ini:"charging_mode"`
+		ChargingPowerThreshold   int    `ini:"charging_power_threshold"`
+		IdlePowerFloor           int    `ini:"idle_power_floor"`
+		TemperatureMode          string `ini:"temperature_mode"`
+		Precision                string `ini:"precision"`
+		AlwaysPublish            string `ini:"always_publish"`
+		ChargingProfiles         bool   `ini:"charging_profiles"`
+		LockEvents               bool   `ini:"lock_events"`
+		StatusPageAddr           string `ini:"status_page_addr"`
+		RESTAPI                  bool   `ini:"rest_api"`
+		RESTAPIToken             string `ini:"rest_api_token"`
+		SoftStartSeconds         int    `ini:"soft_start_seconds"`
+		SoftStartMinCurrent      int    `ini:"soft_start_min_current"`
+		FallbackAvailableCurrent int    `ini:"fallback_available_current"`
+		LogUnmappedSensors       string `ini:"log_unmapped_sensors"`
 	} `ini:"settings"`
+
+	// Smoothing averages noisy power/current readings before publishing.
+	Smoothing struct {
+		Samples  int    `ini:"samples"`
+		Seconds  int    `ini:"seconds"`
+		Entities string `ini:"entities"`
+	} `ini:"smoothing"`
+
+	// Profiles tunes the charging_profile select; see parseChargingProfile.
+	Profiles struct {
+		Fast      string `ini:"fast"`
+		SolarOnly string `ini:"solar_only"`
+		SolarGrid string `ini:"solar_grid"`
+		Scheduled string `ini:"scheduled"`
+	} `ini:"profiles"`
+
+	// ControlPilot corrects the telemetry control-pilot code maps for
+	// firmware that uses the codes differently; each is a code:value list.
+	ControlPilot struct {
+		States    string `ini:"states"`
+		Letters   string `ini:"letters"`
+		Connected string `ini:"connected"`
+	} `ini:"control_pilot"`
+
+	// JournalPatterns holds the [journal_patterns] section, whose keys are
+	// user-chosen names; see parseJournalPatterns.
+	JournalPatterns map[string]string `ini:"-"`
+
+	// SQLSensors holds the [sql_sensors] section, whose keys are user-chosen
+	// names; see parseSQLSensors.
+	SQLSensors map[string]string `ini:"-"`
+
+	// RateLimits holds the [ratelimit] section, entity key to
+	// "<threshold>[, <seconds>]"; see applyRateLimits.
+	RateLimits map[string]string `ini:"-"`
+
+	// Queries optionally overrides the SQL statements for charger schemas
+	// that differ from the one the bridge was written against.
+	Queries struct {
+		Refresh            string `ini:"refresh"`
+		SerialNumber       string `ini:"serial_number"`
+		FirmwareVersion    string `ini:"firmware_version"`
+		ChargerType        string `ini:"charger_type"`
+		AvailableCurrent   string `ini:"available_current"`
+		Schedules          string `ini:"schedules"`
+		ConnectorType      string `ini:"connector_type"`
+		LifetimeAddedRange string `ini:"lifetime_added_range"`
+		AutoLock           string `ini:"auto_lock"`
+		PhaseCurrentLimits string `ini:"phase_current_limits"`
+		Timezone           string `ini:"timezone"`
+
+		SetEcosmartMode     string `ini:"set_ecosmart_mode"`
+		SetSchedulesEnabled string `ini:"set_schedules_enabled"`
+		SetAutoLockEnabled  string `ini:"set_auto_lock_enabled"`
+		SetAutoLockTime     string `ini:"set_auto_lock_time"`
+	} `ini:"queries"`
+
+	// Chargers holds the [wallbox.<id>] sections; see chargerConfigs.
+	Chargers []ChargerConfig `ini:"-"`
+
+	// configurationURL is advertised as the device's configuration_url in
+	// discovery when the charger's address is known.
+	configurationURL string `ini:"-"`
 }
 
 func (w *WallboxConfig) SaveTo(path string) {
@@ -33,6 +193,84 @@ func (w *WallboxConfig) SaveTo(path string) {
 	cfg.SaveTo(path)
 }
 
+// applyDefaults fills in the settings that older config files do not set.
+func (w *WallboxConfig) applyDefaults() {
+	if w.Settings.OCPPMismatchSeconds == 0 {
+		w.Settings.OCPPMismatchSeconds = 60
+	}
+	if w.Settings.OCPPMismatchClearSeconds < 0 {
+		w.Settings.OCPPMismatchClearSeconds = 0
+	}
+	if w.Settings.OCPPServiceName == "" {
+		w.Settings.OCPPServiceName = "ocppwallbox.service"
+	}
+	if w.Settings.RedisServiceName == "" {
+		w.Settings.RedisServiceName = "redis.service"
+	}
+	if w.Settings.MySQLServiceName == "" {
+		w.Settings.MySQLServiceName = "mysqld.service"
+	}
+	if w.Settings.OCPPRestartCooldown == 0 {
+		w.Settings.OCPPRestartCooldown = 600
+	}
+	if w.Settings.OCPPMaxRestarts <= 0 {
+		// Default to a small number of restart attempts before either giving up
+		// or escalating to a full reboot (if enabled).
+		w.Settings.OCPPMaxRestarts = 3
+	}
+	if w.Settings.OCPPHeartbeatTimeout == 0 {
+		w.Settings.OCPPHeartbeatTimeout = 900
+	}
+	if w.Settings.ChargerOfflineTimeout == 0 {
+		w.Settings.ChargerOfflineTimeout = 300
+	}
+	if w.Settings.ConsistencyChecks == "" {
+		w.Settings.ConsistencyChecks = "charging_without_meter"
+	}
+	if w.Settings.ConsistencyClearSeconds == 0 {
+		w.Settings.ConsistencyClearSeconds = 120
+	}
+	if w.Settings.PilotErrorSeconds == 0 {
+		w.Settings.PilotErrorSeconds = 300
+	}
+	if w.Settings.GhostSessionSeconds == 0 {
+		w.Settings.GhostSessionSeconds = 600
+	}
+	if w.Settings.TimeSyncThreshold == 0 {
+		w.Settings.TimeSyncThreshold = 60
+	}
+	if w.Settings.ChargingMode == "" {
+		w.Settings.ChargingMode = "pilot"
+	}
+	if w.Settings.ChargingPowerThreshold == 0 {
+		w.Settings.ChargingPowerThreshold = 100
+	}
+	if w.Settings.SoftStartMinCurrent == 0 {
+		w.Settings.SoftStartMinCurrent = 6
+	}
+	if w.Settings.FallbackAvailableCurrent == 0 {
+		w.Settings.FallbackAvailableCurrent = 32
+	}
+	if w.Settings.LogUnmappedSensors == "" {
+		w.Settings.LogUnmappedSensors = "once"
+	}
+	if w.MQTT.PayloadAvailable == "" {
+		w.MQTT.PayloadAvailable = "online"
+	}
+	if w.MQTT.PayloadNotAvailable == "" {
+		w.MQTT.PayloadNotAvailable = "offline"
+	}
+	if w.MQTT.PublishTimeoutSeconds == 0 {
+		w.MQTT.PublishTimeoutSeconds = 10
+	}
+	if w.MQTT.ConnectRetryIntervalSeconds == 0 {
+		w.MQTT.ConnectRetryIntervalSeconds = 5
+	}
+	if w.MQTT.MaxReconnectIntervalSeconds == 0 {
+		w.MQTT.MaxReconnectIntervalSeconds = 60
+	}
+}
+
 func LoadConfig(path string) *WallboxConfig {
 	cfg, _ := ini.Load(path)
 
@@ -40,6 +278,19 @@ func LoadConfig(path string) *WallboxConfig {
 	if err := cfg.MapTo(&config); err != nil {
 		return nil
 	}
+	config.JournalPatterns = cfg.Section("journal_patterns").KeysHash()
+	config.SQLSensors = cfg.Section("sql_sensors").KeysHash()
+	config.RateLimits = cfg.Section("ratelimit").KeysHash()
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name(), chargerSectionPrefix) {
+			continue
+		}
+		charger := ChargerConfig{ID: strings.TrimPrefix(section.Name(), chargerSectionPrefix)}
+		if err := section.MapTo(&charger); err != nil {
+			return nil
+		}
+		config.Chargers = append(config.Chargers, charger)
+	}
 
 	return &config
 }
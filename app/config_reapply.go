@@ -0,0 +1,157 @@
+package bridge
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// rebootTolerance absorbs jitter between uptime and wall clock when the boot
+// time is derived from them; only a larger jump counts as a reboot.
+const rebootTolerance = 2 * time.Minute
+
+// intendedSettings are the values last set through the bridge, persisted so
+// they survive the reboot they are meant to repair (on-device, the bridge
+// restarts with the charger). Unset fields were never changed by the user.
+type intendedSettings struct {
+	MaxChargingCurrent *int      `json:"max_charging_current,omitempty"`
+	Lock               *int      `json:"lock,omitempty"`
+	HaloBrightness     *int      `json:"halo_brightness,omitempty"`
+	BootTime           time.Time `json:"boot_time"`
+}
+
+// chargerConfig is the charger's current view of the settings that can be
+// re-applied.
+type chargerConfig struct {
+	MaxChargingCurrent int
+	Lock               int
+	HaloBrightness     int
+}
+
+type reapplyTarget interface {
+	SetMaxChargingCurrent(current int)
+	SetLocked(lock int)
+	SetHaloBrightness(brightness int)
+}
+
+// configReapplier remembers the user's settings and puts them back after
+// the charger reboots with different ones.
+type configReapplier struct {
+	path string
+
+	mu       sync.Mutex
+	settings intendedSettings
+}
+
+// loadConfigReapplier restores the intended settings from path. A missing
+// or unreadable file starts empty.
+func loadConfigReapplier(path string) *configReapplier {
+	r := &configReapplier{path: path}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &r.settings)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Ignoring intended settings in %s: %v", path, err)
+		r.settings = intendedSettings{}
+	}
+	return r
+}
+
+// Record stores a value the user set for key (max_charging_current, lock or
+// halo_brightness).
+func (r *configReapplier) Record(key string, value int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch key {
+	case "max_charging_current":
+		r.settings.MaxChargingCurrent = &value
+	case "lock":
+		r.settings.Lock = &value
+	case "halo_brightness":
+		r.settings.HaloBrightness = &value
+	default:
+		return
+	}
+	r.save()
+}
+
+// CheckReboot derives the charger's boot time from its uptime and reports
+// whether it differs from the one last seen, i.e. the charger rebooted. The
+// very first observation only records the boot time.
+func (r *configReapplier) CheckReboot(now time.Time, uptime time.Duration) bool {
+	boot := now.Add(-uptime)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev := r.settings.BootTime
+	if !prev.IsZero() {
+		diff := boot.Sub(prev)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= rebootTolerance {
+			return false
+		}
+	}
+	r.settings.BootTime = boot
+	r.save()
+	return !prev.IsZero()
+}
+
+// Reapply sets every intended value that differs from current and returns
+// the ones it changed.
+func (r *configReapplier) Reapply(target reapplyTarget, current chargerConfig) map[string]int {
+	r.mu.Lock()
+	settings := r.settings
+	r.mu.Unlock()
+
+	applied := make(map[string]int)
+	if v := settings.MaxChargingCurrent; v != nil && *v != current.MaxChargingCurrent {
+		target.SetMaxChargingCurrent(*v)
+		applied["max_charging_current"] = *v
+	}
+	if v := settings.Lock; v != nil && *v != current.Lock {
+		target.SetLocked(*v)
+		applied["lock"] = *v
+	}
+	if v := settings.HaloBrightness; v != nil && *v != current.HaloBrightness {
+		target.SetHaloBrightness(*v)
+		applied["halo_brightness"] = *v
+	}
+	return applied
+}
+
+// save writes the settings; callers hold r.mu.
+func (r *configReapplier) save() {
+	data, _ := json.Marshal(r.settings)
+	if err := os.WriteFile(r.path, data, 0o644); err != nil {
+		log.Printf("Failed to save intended settings to %s: %v", r.path, err)
+	}
+}
+
+// applyConfigReapply records every value the user sets through the entities
+// that are re-applied after a reboot.
+func applyConfigReapply(entityConfig map[string]Entity, r *configReapplier) {
+	for _, key := range []string{"max_charging_current", "lock", "halo_brightness"} {
+		e, ok := entityConfig[key]
+		if !ok {
+			continue
+		}
+		key, setter := key, e.Setter
+		e.Setter = func(val string) {
+			r.Record(key, strToInt(val))
+			setter(val)
+		}
+		entityConfig[key] = e
+	}
+}
+
+// configReappliedEvent is the payload published to
+// wallbox_<serial>/events/config_reapplied.
+type configReappliedEvent struct {
+	Settings map[string]int `json:"settings"`
+	At       string         `json:"at"`
+}
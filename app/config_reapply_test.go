@@ -0,0 +1,73 @@
+package bridge
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeReapplyTarget struct {
+	calls []string
+}
+
+func (f *fakeReapplyTarget) SetMaxChargingCurrent(int) {
+	f.calls = append(f.calls, "max_charging_current")
+}
+func (f *fakeReapplyTarget) SetLocked(int)         { f.calls = append(f.calls, "lock") }
+func (f *fakeReapplyTarget) SetHaloBrightness(int) { f.calls = append(f.calls, "halo_brightness") }
+
+func TestConfigReapplier_CheckReboot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intended.json")
+	r := loadConfigReapplier(path)
+	t0 := time.Date(2025, 11, 23, 12, 0, 0, 0, time.UTC)
+
+	if r.CheckReboot(t0, time.Hour) {
+		t.Fatalf("expected the first observation to only record the boot time")
+	}
+	if r.CheckReboot(t0.Add(10*time.Minute), time.Hour+10*time.Minute+30*time.Second) {
+		t.Fatalf("expected small jitter not to count as a reboot")
+	}
+	if !r.CheckReboot(t0.Add(20*time.Minute), 2*time.Minute) {
+		t.Fatalf("expected an uptime reset to be detected as a reboot")
+	}
+	if r.CheckReboot(t0.Add(25*time.Minute), 7*time.Minute) {
+		t.Fatalf("expected the reboot to be reported once")
+	}
+
+	// The bridge restarting with the charger still sees the reboot.
+	restarted := loadConfigReapplier(path)
+	if !restarted.CheckReboot(t0.Add(2*time.Hour), time.Minute) {
+		t.Fatalf("expected a reboot while the bridge was down to be detected from the saved boot time")
+	}
+}
+
+func TestConfigReapplier_Reapply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intended.json")
+	r := loadConfigReapplier(path)
+
+	var target fakeReapplyTarget
+	if applied := r.Reapply(&target, chargerConfig{MaxChargingCurrent: 32}); len(applied) != 0 || len(target.calls) != 0 {
+		t.Fatalf("expected nothing to re-apply before the user set anything, got %v", applied)
+	}
+
+	entities := map[string]Entity{
+		"max_charging_current": {Component: "number", Setter: func(string) {}},
+		"lock":                 {Component: "lock", Setter: func(string) {}},
+		"halo_brightness":      {Component: "number", Setter: func(string) {}},
+	}
+	applyConfigReapply(entities, r)
+	entities["max_charging_current"].Setter("16")
+	entities["lock"].Setter("1")
+	entities["halo_brightness"].Setter("40")
+
+	// Reload from disk as the restarted bridge would.
+	r = loadConfigReapplier(path)
+	applied := r.Reapply(&target, chargerConfig{MaxChargingCurrent: 32, Lock: 1, HaloBrightness: 100})
+	if want := map[string]int{"max_charging_current": 16, "halo_brightness": 40}; !reflect.DeepEqual(applied, want) {
+		t.Fatalf("expected %v to be re-applied, got %v", want, applied)
+	}
+	if want := []string{"max_charging_current", "halo_brightness"}; !reflect.DeepEqual(target.calls, want) {
+		t.Fatalf("expected setters %v, got %v", want, target.calls)
+	}
+}
@@ -0,0 +1,77 @@
+package bridge
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"wallbox-mqtt-bridge/app/wallbox"
+)
+
+// consistencySample is the subset of charger data the consistency checks
+// look at, taken once per poll.
+type consistencySample struct {
+	HasTelemetry  bool
+	PilotCharging bool
+	Currents      [3]float64
+	Voltages      [3]float64
+}
+
+func newConsistencySample(w *wallbox.Wallbox) consistencySample {
+	t := w.Data.RedisTelemetry
+	return consistencySample{
+		HasTelemetry:  w.HasTelemetry,
+		PilotCharging: w.IsChargingPilot(),
+		Currents:      [3]float64{w.ChargingCurrentL1(), w.ChargingCurrentL2(), w.ChargingCurrentL3()},
+		Voltages:      [3]float64{t.InternalMeterVoltageL1, t.InternalMeterVoltageL2, t.InternalMeterVoltageL3},
+	}
+}
+
+func allZero(values [3]float64) bool {
+	return values[0] == 0 && values[1] == 0 && values[2] == 0
+}
+
+// consistencyChecks are the named checks consistency_checks can enable. Each
+// returns true when the sample can't be right, i.e. the bridge is probably
+// misreading the charger.
+var consistencyChecks = map[string]func(consistencySample) bool{
+	// The pilot says a car is drawing current, yet the meter reads nothing
+	// at all, not even mains voltage.
+	"charging_without_meter": func(s consistencySample) bool {
+		return s.HasTelemetry && s.PilotCharging && allZero(s.Currents) && allZero(s.Voltages)
+	},
+	// An energized charger always measures mains voltage on L1.
+	"zero_voltage": func(s consistencySample) bool {
+		return s.HasTelemetry && allZero(s.Voltages)
+	},
+}
+
+// parseConsistencyChecks resolves the comma-separated consistency_checks
+// setting; "none" disables all checks. Unknown names are logged and skipped.
+func parseConsistencyChecks(spec string) []string {
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "none" {
+			continue
+		}
+		if _, ok := consistencyChecks[name]; !ok {
+			log.Printf("Ignoring unknown consistency check %q", name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// failedConsistencyChecks returns the enabled checks that s fails.
+func failedConsistencyChecks(checks []string, s consistencySample) []string {
+	var failed []string
+	for _, name := range checks {
+		if consistencyChecks[name](s) {
+			failed = append(failed, name)
+		}
+	}
+	return failed
+}
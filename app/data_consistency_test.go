@@ -0,0 +1,34 @@
+package bridge
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConsistencyChecks(t *testing.T) {
+	checks := parseConsistencyChecks("zero_voltage, charging_without_meter, bogus")
+	if !reflect.DeepEqual(checks, []string{"charging_without_meter", "zero_voltage"}) {
+		t.Fatalf("unexpected checks %v", checks)
+	}
+	if got := parseConsistencyChecks("none"); len(got) != 0 {
+		t.Fatalf("expected none to disable all checks, got %v", got)
+	}
+
+	cases := []struct {
+		name   string
+		sample consistencySample
+		want   []string
+	}{
+		{"idle", consistencySample{HasTelemetry: true, Voltages: [3]float64{230, 0, 0}}, nil},
+		{"charging", consistencySample{HasTelemetry: true, PilotCharging: true, Currents: [3]float64{16, 16, 16}, Voltages: [3]float64{230, 231, 229}}, nil},
+		{"charging at 0 A", consistencySample{HasTelemetry: true, PilotCharging: true, Voltages: [3]float64{230, 231, 229}}, nil},
+		{"dead meter", consistencySample{HasTelemetry: true, PilotCharging: true}, []string{"charging_without_meter", "zero_voltage"}},
+		{"no voltage while idle", consistencySample{HasTelemetry: true}, []string{"zero_voltage"}},
+		{"legacy firmware", consistencySample{PilotCharging: true}, nil},
+	}
+	for _, tc := range cases {
+		if got := failedConsistencyChecks(checks, tc.sample); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package bridge
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// validDeviceID restricts device ids to characters that are safe in MQTT
+// topics, Home Assistant unique_ids and URLs.
+var validDeviceID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// collisionWait is how long we listen for a retained availability message
+// from another bridge using the same device id.
+const collisionWait = 2 * time.Second
+
+// resolveDeviceID returns the id used in topic prefixes and unique_ids: the
+// configured override if set, otherwise the charger serial number.
+func resolveDeviceID(serialNumber, override string) (string, error) {
+	if override == "" {
+		if strings.TrimSpace(serialNumber) == "" {
+			log.Println("WARNING: the charger reports an empty serial number; set device_id_override in [settings] " +
+				"to avoid unique_id collisions in Home Assistant")
+		}
+		return serialNumber, nil
+	}
+
+	override = strings.TrimSpace(override)
+	if !validDeviceID.MatchString(override) {
+		return "", fmt.Errorf("invalid device_id_override %q: only letters, digits, '_' and '-' are allowed", override)
+	}
+	return override, nil
+}
+
+// warnOnDeviceCollision checks, before we announce ourselves, whether another
+// bridge instance currently reports itself online under the same
+// availability topic.
+func warnOnDeviceCollision(client mqtt.Client, availabilityTopic, payloadAvailable string) {
+	online := make(chan struct{}, 1)
+	token := client.Subscribe(availabilityTopic, 1, func(_ mqtt.Client, msg mqtt.Message) {
+		if msg.Retained() && string(msg.Payload()) == payloadAvailable {
+			select {
+			case online <- struct{}{}:
+			default:
+			}
+		}
+	})
+	if token.Wait() && token.Error() != nil {
+		log.Printf("Cannot check %s for device id collisions: %v", availabilityTopic, token.Error())
+		return
+	}
+	defer client.Unsubscribe(availabilityTopic).Wait()
+
+	select {
+	case <-online:
+		log.Printf("WARNING: %s already reports %q. Another bridge instance may be publishing under the same device id; "+
+			"give each charger a unique device_id_override or its entities will collide in Home Assistant.", availabilityTopic, payloadAvailable)
+	case <-time.After(collisionWait):
+	}
+}
@@ -0,0 +1,30 @@
+package bridge
+
+import "testing"
+
+func TestResolveDeviceID(t *testing.T) {
+	cases := []struct {
+		serial, override string
+		want             string
+		wantErr          bool
+	}{
+		{"225619", "", "225619", false},
+		{"225619", "garage_left", "garage_left", false},
+		{"", "  garage-2 ", "garage-2", false},
+		{"", "", "", false},
+		{"225619", "garage/left", "", true},
+		{"225619", "garage left", "", true},
+		{"225619", "   ", "", true},
+		{"225619", "#", "", true},
+	}
+
+	for _, tc := range cases {
+		got, err := resolveDeviceID(tc.serial, tc.override)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("resolveDeviceID(%q, %q): unexpected error state: %v", tc.serial, tc.override, err)
+		}
+		if got != tc.want {
+			t.Fatalf("resolveDeviceID(%q, %q) = %q, want %q", tc.serial, tc.override, got, tc.want)
+		}
+	}
+}
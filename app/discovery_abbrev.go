@@ -0,0 +1,74 @@
+package bridge
+
+// Home Assistant accepts abbreviated keys in MQTT discovery payloads. Only the
+// keys the bridge emits are listed; anything else is passed through as is.
+var discoveryAbbreviations = map[string]string{
+	"availability":                "avty",
+	"availability_mode":           "avty_mode",
+	"availability_topic":          "avty_t",
+	"command_topic":               "cmd_t",
+	"device":                      "dev",
+	"device_class":                "dev_cla",
+	"entity_category":             "ent_cat",
+	"icon":                        "ic",
+	"options":                     "ops",
+	"payload_available":           "pl_avail",
+	"payload_not_available":       "pl_not_avail",
+	"payload_off":                 "pl_off",
+	"payload_on":                  "pl_on",
+	"payload_press":               "pl_prs",
+	"state_class":                 "stat_cla",
+	"state_topic":                 "stat_t",
+	"suggested_display_precision": "sug_dsp_prc",
+	"unique_id":                   "uniq_id",
+	"unit_of_measurement":         "unit_of_meas",
+	"value_template":              "val_tpl",
+}
+
+var availabilityAbbreviations = map[string]string{
+	"topic":                 "t",
+	"payload_available":     "pl_avail",
+	"payload_not_available": "pl_not_avail",
+}
+
+var deviceAbbreviations = map[string]string{
+	"identifiers":       "ids",
+	"sw_version":        "sw",
+	"configuration_url": "cu",
+}
+
+// abbreviateDiscovery rewrites a discovery payload built by discoveryConfig
+// to Home Assistant's abbreviated keys, including the nested availability
+// list and device block.
+func abbreviateDiscovery(config map[string]interface{}) map[string]interface{} {
+	short := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		switch val := v.(type) {
+		case []map[string]string:
+			list := make([]map[string]string, len(val))
+			for i, entry := range val {
+				list[i] = abbreviateKeys(entry, availabilityAbbreviations)
+			}
+			v = list
+		case map[string]string:
+			v = abbreviateKeys(val, deviceAbbreviations)
+		}
+		short[abbreviation(k, discoveryAbbreviations)] = v
+	}
+	return short
+}
+
+func abbreviateKeys(m map[string]string, abbreviations map[string]string) map[string]string {
+	short := make(map[string]string, len(m))
+	for k, v := range m {
+		short[abbreviation(k, abbreviations)] = v
+	}
+	return short
+}
+
+func abbreviation(key string, abbreviations map[string]string) string {
+	if short, ok := abbreviations[key]; ok {
+		return short
+	}
+	return key
+}
@@ -0,0 +1,109 @@
+package bridge
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// roundTrip decodes a payload the way it reaches Home Assistant.
+func roundTrip(t *testing.T, config map[string]interface{}) (map[string]interface{}, int) {
+	payload, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded, len(payload)
+}
+
+func TestAbbreviateDiscovery(t *testing.T) {
+	var c WallboxConfig
+	c.applyDefaults()
+
+	// The expected payloads use the abbreviations from Home Assistant's
+	// MQTT discovery docs, written out by hand rather than derived from the
+	// maps under test.
+	cases := []struct {
+		key    string
+		entity Entity
+		want   map[string]interface{}
+	}{
+		{
+			key: "lock",
+			entity: Entity{Component: "switch", Setter: func(string) {}, Config: map[string]string{
+				"name": "Lock", "payload_on": "1", "payload_off": "0",
+			}},
+			want: map[string]interface{}{
+				"~":            "wallbox_123/lock",
+				"name":         "Lock",
+				"uniq_id":      "123_lock",
+				"stat_t":       "~/state",
+				"cmd_t":        "~/set",
+				"avty_t":       "wallbox_123/availability",
+				"pl_avail":     "online",
+				"pl_not_avail": "offline",
+				"pl_on":        "1",
+				"pl_off":       "0",
+				"dev":          map[string]interface{}{"ids": "123", "name": ""},
+			},
+		},
+		{
+			key: "charging_efficiency",
+			entity: Entity{Component: "sensor", Available: func() bool { return true }, Config: map[string]string{
+				"name": "Charging efficiency", "entity_category": "diagnostic", "icon": "mdi:percent",
+				"unit_of_measurement": "%", "state_class": "measurement", "suggested_display_precision": "0",
+			}},
+			want: map[string]interface{}{
+				"~":       "wallbox_123/charging_efficiency",
+				"name":    "Charging efficiency",
+				"uniq_id": "123_charging_efficiency",
+				"stat_t":  "~/state",
+				"avty": []interface{}{
+					map[string]interface{}{"t": "wallbox_123/availability", "pl_avail": "online", "pl_not_avail": "offline"},
+					map[string]interface{}{"t": "wallbox_123/charging_efficiency/availability", "pl_avail": "online", "pl_not_avail": "offline"},
+				},
+				"avty_mode":    "all",
+				"ent_cat":      "diagnostic",
+				"ic":           "mdi:percent",
+				"unit_of_meas": "%",
+				"stat_cla":     "measurement",
+				"sug_dsp_prc":  "0",
+				"dev":          map[string]interface{}{"ids": "123", "name": ""},
+			},
+		},
+		{
+			key:    "charging_profile",
+			entity: Entity{Component: "select", Setter: func(string) {}, Options: []string{"Fast", "Custom"}},
+			want: map[string]interface{}{
+				"~":            "wallbox_123/charging_profile",
+				"uniq_id":      "123_charging_profile",
+				"stat_t":       "~/state",
+				"cmd_t":        "~/set",
+				"ops":          []interface{}{"Fast", "Custom"},
+				"avty_t":       "wallbox_123/availability",
+				"pl_avail":     "online",
+				"pl_not_avail": "offline",
+				"dev":          map[string]interface{}{"ids": "123", "name": ""},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		config := discoveryConfig(&c, tc.key, tc.entity, "123", "6.7.0")
+		full, fullSize := roundTrip(t, config)
+		short, shortSize := roundTrip(t, abbreviateDiscovery(config))
+
+		// The software version depends on the build, so take it from the
+		// unabbreviated payload.
+		tc.want["dev"].(map[string]interface{})["sw"] = full["device"].(map[string]interface{})["sw_version"]
+		if !reflect.DeepEqual(short, tc.want) {
+			t.Fatalf("%s: abbreviated payload is\n%v\nwant\n%v", tc.key, short, tc.want)
+		}
+		if shortSize >= fullSize {
+			t.Fatalf("%s: expected the abbreviated payload to be smaller, got %d >= %d bytes", tc.key, shortSize, fullSize)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package bridge
+
+// ghostSessionPowerThreshold is the power (W) below which a session that
+// claims to be charging is considered to not be delivering any energy.
+const ghostSessionPowerThreshold = 50.0
+
+// claimsCharging reports whether the OCPP status code or the charger status
+// say a session is actively charging. Suspended (4/5) and paused sessions are
+// legitimate zero-power states and never count.
+func claimsCharging(ocppCode int, status string) bool {
+	if ocppCode == 4 || ocppCode == 5 || status == "Paused" {
+		return false
+	}
+	return ocppCode == 3 || status == "Charging"
+}
+
+// isGhostSession reports whether one poll sample looks like a "ghost"
+// session: OCPP or the state machine report Charging while no power flows.
+// Only a sustained ghost session is flagged, see sustainedCondition.
+func isGhostSession(ocppCode int, status string, power float64) bool {
+	return claimsCharging(ocppCode, status) && power < ghostSessionPowerThreshold
+}
@@ -0,0 +1,29 @@
+package bridge
+
+import "testing"
+
+func TestIsGhostSession(t *testing.T) {
+	cases := []struct {
+		name     string
+		ocppCode int
+		status   string
+		power    float64
+		want     bool
+	}{
+		{"ChargingAtZeroPower", 3, "Charging", 0, true},
+		{"ChargingWithPower", 3, "Charging", 7200, false},
+		{"StateMachineChargingAlone", 0, "Charging", 10, true},
+		{"SuspendedEVSE", 4, "Charging", 0, false},
+		{"SuspendedEV", 5, "Charging", 0, false},
+		{"Paused", 3, "Paused", 0, false},
+		{"Available", 1, "Ready", 0, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isGhostSession(tc.ocppCode, tc.status, tc.power); got != tc.want {
+				t.Fatalf("isGhostSession(%d, %q, %.0f) = %v, want %v", tc.ocppCode, tc.status, tc.power, got, tc.want)
+			}
+		})
+	}
+}
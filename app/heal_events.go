@@ -0,0 +1,67 @@
+package bridge
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Heal event actions published to wallbox_<serial>/events/heal.
+const (
+	healActionRestart    = "restart"    // ocpp_service_name unit(s) restarted
+	healActionReboot     = "reboot"     // charger rebooted (restart failed or pilot error)
+	healActionEscalation = "escalation" // full reboot after the restart budget ran out
+)
+
+var healActions = []string{healActionRestart, healActionReboot, healActionEscalation}
+
+// healEvent is the JSON payload of a heal event.
+type healEvent struct {
+	Action   string `json:"action"`
+	Detail   string `json:"detail"`
+	OCPPCode int    `json:"ocpp_code"`
+	At       string `json:"at"`
+	// DryRun marks heals auto_restart_ocpp_dry_run only logged.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+func newHealEvent(action, detail string, ocppCode int, at time.Time) healEvent {
+	return healEvent{Action: action, Detail: detail, OCPPCode: ocppCode, At: at.Format(time.RFC3339)}
+}
+
+// healEventAction maps the action reported by restartCriticalServices to the
+// heal event action.
+func healEventAction(restartAction string) string {
+	if restartAction == "reboot" {
+		return healActionReboot
+	}
+	return healActionRestart
+}
+
+// healTriggerConfig builds the Home Assistant device trigger discovery
+// payload for one heal action.
+func healTriggerConfig(c *WallboxConfig, action, serialNumber, firmwareVersion string) map[string]interface{} {
+	return map[string]interface{}{
+		"automation_type": "trigger",
+		"topic":           "wallbox_" + serialNumber + "/events/heal",
+		"type":            "heal",
+		"subtype":         action,
+		"value_template":  "{{ value_json.action }}",
+		"payload":         action,
+		"device":          deviceInfo(c, serialNumber, firmwareVersion),
+	}
+}
+
+// publishHealTriggers registers every heal action as a device trigger so
+// automations can react to them from the device page.
+func publishHealTriggers(client mqtt.Client, c *WallboxConfig, serialNumber, firmwareVersion string) {
+	for _, action := range healActions {
+		payload, _ := json.Marshal(healTriggerConfig(c, action, serialNumber, firmwareVersion))
+		topic := "homeassistant/device_automation/" + serialNumber + "_heal_" + action + "/config"
+		if !waitPublish(client.Publish(topic, 1, true, payload), publishTimeout(c)) {
+			log.Printf("Timed out publishing heal trigger %s", action)
+		}
+	}
+}
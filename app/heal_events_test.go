@@ -0,0 +1,37 @@
+package bridge
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestHealEventPayload(t *testing.T) {
+	at := time.Date(2025, 11, 23, 22, 50, 0, 0, time.UTC)
+	payload, err := json.Marshal(newHealEvent(healEventAction("stop_start"), "ocppwallbox.service stopped+started", 2, at))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"action":"restart","detail":"ocppwallbox.service stopped+started","ocpp_code":2,"at":"2025-11-23T22:50:00Z"}`
+	if string(payload) != want {
+		t.Fatalf("expected %s, got %s", want, payload)
+	}
+
+	if got := healEventAction("reboot"); got != healActionReboot {
+		t.Fatalf("expected restart fallback reboot to map to %q, got %q", healActionReboot, got)
+	}
+}
+
+func TestHealTriggerConfig(t *testing.T) {
+	var c WallboxConfig
+	c.applyDefaults()
+
+	config := healTriggerConfig(&c, healActionEscalation, "123", "6.7.0")
+	if config["topic"] != "wallbox_123/events/heal" || config["payload"] != "escalation" {
+		t.Fatalf("unexpected trigger config: %v", config)
+	}
+	if device, ok := config["device"].(map[string]string); !ok || device["identifiers"] != "123" {
+		t.Fatalf("expected the trigger to belong to the charger device, got %v", config["device"])
+	}
+}
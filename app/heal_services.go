@@ -0,0 +1,102 @@
+package bridge
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// healServices are the systemd units the self-heal touches. Firmware
+// revisions name them differently, so they come from the config.
+type healServices struct {
+	// Restart is restarted in order by every heal.
+	Restart []string
+	// Dependencies are only checked; if they are down a restart will
+	// likely flap.
+	Dependencies []string
+	// DryRun logs the restarts and reboots instead of performing them.
+	DryRun bool
+}
+
+func newHealServices(c *WallboxConfig) healServices {
+	return healServices{
+		Restart:      splitUnits(c.Settings.OCPPServiceName),
+		Dependencies: append(splitUnits(c.Settings.RedisServiceName), splitUnits(c.Settings.MySQLServiceName)...),
+		DryRun:       c.Settings.AutoRestartOCPPDryRun,
+	}
+}
+
+// dryRunAction marks a heal action that was only logged.
+func dryRunAction(action string) string {
+	return "dry_run:" + action
+}
+
+// splitUnits parses a comma-separated list of unit names.
+func splitUnits(spec string) []string {
+	var units []string
+	for _, unit := range strings.Split(spec, ",") {
+		if unit = strings.TrimSpace(unit); unit != "" {
+			units = append(units, unit)
+		}
+	}
+	return units
+}
+
+// systemctl runs systemctl; tests replace it.
+var systemctl = func(args ...string) error {
+	return exec.Command("systemctl", args...).Run()
+}
+
+// restartCriticalServices restarts the heal units in order, preferring a
+// graceful stop + start. A unit that can't even be restarted escalates to a
+// full reboot, as nothing after it would help.
+func restartCriticalServices(services healServices) (action string, detail string, err error) {
+	// Basic dependency sanity checks; log but do not block the heal.
+	for _, name := range services.Dependencies {
+		if err := systemctl("is-active", "--quiet", name); err != nil {
+			log.Printf("warning: dependency %s is not active: %v", name, err)
+		}
+	}
+
+	if len(services.Restart) == 0 {
+		return "noop", "no services to restart", nil
+	}
+	if services.DryRun {
+		detail = "would stop+start " + strings.Join(services.Restart, ", ")
+		log.Printf("heal (dry run): %s", detail)
+		return dryRunAction("stop_start"), detail, nil
+	}
+
+	action = "stop_start"
+	var details []string
+	for _, svc := range services.Restart {
+		stopErr := systemctl("stop", svc)
+		if stopErr == nil {
+			log.Printf("heal: stopped %s", svc)
+			if startErr := systemctl("start", svc); startErr == nil {
+				log.Printf("heal: started %s", svc)
+				details = append(details, svc+" stopped+started")
+				continue
+			}
+			log.Printf("heal: start %s failed after stop, will retry with restart", svc)
+		} else {
+			log.Printf("heal: stop %s failed (%v), will retry with restart", svc, stopErr)
+		}
+
+		// If stop/start fails, fall back to a direct restart.
+		if err := systemctl("restart", svc); err != nil {
+			// As a final safeguard, invoke the Wallbox reboot flow.
+			log.Printf("restart %s failed (%v); escalating to full reboot", svc, err)
+			if rebootErr := rebootSystem(); rebootErr != nil {
+				return "reboot", fmt.Sprintf("reboot failed after restart error: %v", rebootErr), rebootErr
+			}
+			return "reboot", "reboot issued after restart failure", nil
+		}
+		log.Printf("heal: restarted %s via systemctl restart", svc)
+		action = "restart"
+		details = append(details, svc+" restarted")
+	}
+
+	return action, strings.Join(details, ", "), nil
+}
@@ -0,0 +1,77 @@
+package bridge
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRestartCriticalServices(t *testing.T) {
+	c := &WallboxConfig{}
+	c.Settings.OCPPServiceName = "ocppwallbox.service, wallboxsmachine.service"
+	c.applyDefaults()
+	services := newHealServices(c)
+	if want := []string{"redis.service", "mysqld.service"}; !reflect.DeepEqual(services.Dependencies, want) {
+		t.Fatalf("expected default dependencies %v, got %v", want, services.Dependencies)
+	}
+
+	var calls []string
+	defer func(orig func(...string) error) { systemctl = orig }(systemctl)
+	systemctl = func(args ...string) error {
+		calls = append(calls, strings.Join(args, " "))
+		if args[0] == "stop" && args[1] == "wallboxsmachine.service" {
+			return errors.New("stop failed")
+		}
+		return nil
+	}
+
+	action, detail, err := restartCriticalServices(services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"is-active --quiet redis.service",
+		"is-active --quiet mysqld.service",
+		"stop ocppwallbox.service",
+		"start ocppwallbox.service",
+		"stop wallboxsmachine.service",
+		"restart wallboxsmachine.service",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("expected systemctl calls %v, got %v", want, calls)
+	}
+	if action != "restart" || detail != "ocppwallbox.service stopped+started, wallboxsmachine.service restarted" {
+		t.Fatalf("unexpected result %q, %q", action, detail)
+	}
+}
+
+func TestRestartCriticalServicesDryRun(t *testing.T) {
+	c := &WallboxConfig{}
+	c.Settings.AutoRestartOCPPDryRun = true
+	c.applyDefaults()
+	services := newHealServices(c)
+
+	var calls []string
+	defer func(orig func(...string) error) { systemctl = orig }(systemctl)
+	systemctl = func(args ...string) error {
+		calls = append(calls, strings.Join(args, " "))
+		return nil
+	}
+
+	action, detail, err := restartCriticalServices(services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range calls {
+		if !strings.HasPrefix(call, "is-active ") {
+			t.Fatalf("dry run must only check dependencies, got systemctl %s", call)
+		}
+	}
+	if action != "dry_run:stop_start" || detail != "would stop+start ocppwallbox.service" {
+		t.Fatalf("unexpected result %q, %q", action, detail)
+	}
+	if healEventAction(action) != healActionRestart {
+		t.Fatalf("expected dry-run stop_start to map to %q", healActionRestart)
+	}
+}
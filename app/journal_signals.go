@@ -0,0 +1,120 @@
+package bridge
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wallbox-mqtt-bridge/app/wallbox"
+)
+
+var validJournalPatternName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// parseJournalPatterns turns the [journal_patterns] section into patterns.
+// Each "name = regex" entry watches the ocppwallbox journal unless a
+// "name.unit = other.service" entry names another unit. Invalid entries are
+// logged and skipped.
+func parseJournalPatterns(entries map[string]string) []wallbox.JournalPattern {
+	var patterns []wallbox.JournalPattern
+	for name, expr := range entries {
+		if strings.HasSuffix(name, ".unit") {
+			if _, ok := entries[strings.TrimSuffix(name, ".unit")]; !ok {
+				log.Printf("Ignoring journal pattern unit %s without a pattern", name)
+			}
+			continue
+		}
+		if !validJournalPatternName.MatchString(name) {
+			log.Printf("Ignoring journal pattern %q: names may only use a-z, 0-9 and '_'", name)
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			log.Printf("Ignoring journal pattern %s: %v", name, err)
+			continue
+		}
+		patterns = append(patterns, wallbox.JournalPattern{Name: name, Unit: entries[name+".unit"], Re: re})
+	}
+	sort.Slice(patterns, func(i, j int) bool { return patterns[i].Name < patterns[j].Name })
+	return patterns
+}
+
+// journalSignals counts the matches of each journal pattern. Matches arrive
+// from the journal watcher goroutines.
+type journalSignals struct {
+	mu    sync.RWMutex
+	count map[string]int
+	last  map[string]time.Time
+}
+
+func newJournalSignals() *journalSignals {
+	return &journalSignals{count: make(map[string]int), last: make(map[string]time.Time)}
+}
+
+func (s *journalSignals) Record(m wallbox.JournalMatch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count[m.Name]++
+	s.last[m.Name] = m.At
+}
+
+func (s *journalSignals) Count(name string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.count[name]
+}
+
+// LastAt returns the time of the last match as RFC3339, or "" if none.
+func (s *journalSignals) LastAt(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if last, ok := s.last[name]; ok {
+		return last.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// journalMatchEvent is the payload published to
+// wallbox_<serial>/events/journal/<name> for every reported match.
+type journalMatchEvent struct {
+	Name   string   `json:"name"`
+	Line   string   `json:"line"`
+	Groups []string `json:"groups,omitempty"`
+	At     string   `json:"at"`
+}
+
+func newJournalMatchEvent(m wallbox.JournalMatch) journalMatchEvent {
+	return journalMatchEvent{Name: m.Name, Line: m.Line, Groups: m.Groups, At: m.At.Format(time.RFC3339)}
+}
+
+// getJournalPatternEntities exposes a match counter and the last match time
+// for every configured journal pattern.
+func getJournalPatternEntities(patterns []wallbox.JournalPattern, signals *journalSignals) map[string]Entity {
+	entities := make(map[string]Entity)
+	for _, p := range patterns {
+		name := p.Name
+		entities["journal_"+name+"_count"] = Entity{
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(signals.Count(name)) },
+			Config: map[string]string{
+				"name":            "Journal " + name + " matches",
+				"icon":            "mdi:text-search",
+				"state_class":     "total_increasing",
+				"entity_category": "diagnostic",
+			},
+		}
+		entities["journal_"+name+"_last"] = Entity{
+			Component: "sensor",
+			Getter:    func() string { return signals.LastAt(name) },
+			Config: map[string]string{
+				"name":            "Journal " + name + " last match",
+				"device_class":    "timestamp",
+				"entity_category": "diagnostic",
+			},
+		}
+	}
+	return entities
+}
@@ -0,0 +1,43 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"wallbox-mqtt-bridge/app/wallbox"
+)
+
+func TestParseJournalPatterns(t *testing.T) {
+	patterns := parseJournalPatterns(map[string]string{
+		"ws_error":    `WebSocket error`,
+		"reset":       `Reset(Soft|Hard)`,
+		"reset.unit":  "wallboxsmachine.service",
+		"Bad Name":    `x`,
+		"broken":      `(`,
+		"orphan.unit": "foo.service",
+	})
+
+	if len(patterns) != 2 {
+		t.Fatalf("expected two valid patterns, got %v", patterns)
+	}
+	if patterns[0].Name != "reset" || patterns[0].Unit != "wallboxsmachine.service" {
+		t.Fatalf("expected reset on wallboxsmachine.service first, got %+v", patterns[0])
+	}
+	if patterns[1].Name != "ws_error" || patterns[1].Unit != "" {
+		t.Fatalf("expected ws_error on the default unit, got %+v", patterns[1])
+	}
+
+	signals := newJournalSignals()
+	entities := getJournalPatternEntities(patterns, signals)
+	at := time.Date(2025, 11, 23, 22, 50, 0, 0, time.UTC)
+	signals.Record(wallbox.JournalMatch{Name: "reset", At: at})
+	if got := entities["journal_reset_count"].Value(); got != "1" {
+		t.Fatalf("expected count 1, got %q", got)
+	}
+	if got := entities["journal_reset_last"].Value(); got != "2025-11-23T22:50:00Z" {
+		t.Fatalf("unexpected last match %q", got)
+	}
+	if got := entities["journal_ws_error_last"].Value(); got != "" {
+		t.Fatalf("expected no last match yet, got %q", got)
+	}
+}
@@ -0,0 +1,129 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricSpec maps one entity to a Prometheus metric. Entities sharing a
+// metric name become one series each, told apart by labels.
+type metricSpec struct {
+	Name   string
+	Type   string
+	Help   string
+	Key    string
+	Labels string
+}
+
+// metricSpecs are the exported metrics. Values come from the same entity
+// getters as the MQTT states; entities that are disabled or not numeric are
+// left out.
+var metricSpecs = []metricSpec{
+	{Name: "wallbox_charging_power_watts", Type: "gauge", Help: "Charging power.", Key: "charging_power"},
+	{Name: "wallbox_charging_current_amperes", Type: "gauge", Help: "Charging current per phase.", Key: "charging_current_l1", Labels: `phase="1"`},
+	{Name: "wallbox_charging_current_amperes", Type: "gauge", Help: "Charging current per phase.", Key: "charging_current_l2", Labels: `phase="2"`},
+	{Name: "wallbox_charging_current_amperes", Type: "gauge", Help: "Charging current per phase.", Key: "charging_current_l3", Labels: `phase="3"`},
+	{Name: "wallbox_ocpp_status_code", Type: "gauge", Help: "OCPP status code (1-9).", Key: "ocpp_status_code"},
+	{Name: "wallbox_ocpp_mismatch", Type: "gauge", Help: "1 while the pilot and OCPP status disagree.", Key: "ocpp_mismatch"},
+	{Name: "wallbox_ocpp_restart_attempts", Type: "gauge", Help: "OCPP service restarts tried for the current mismatch.", Key: "ocpp_restart_count"},
+	{Name: "wallbox_ocpp_restarts_total", Type: "counter", Help: "OCPP service restarts since the bridge started.", Key: "ocpp_restart_total"},
+}
+
+type metricSample struct {
+	spec  metricSpec
+	value float64
+}
+
+// metricsExporter serves the last poll's values in the Prometheus text
+// format. Like the status page, the poll loop pushes a snapshot so scrapes
+// never call getters.
+type metricsExporter struct {
+	mu            sync.RWMutex
+	device        string
+	samples       []metricSample
+	lastTelemetry time.Time
+}
+
+func newMetricsExporter(device string) *metricsExporter {
+	return &metricsExporter{device: device}
+}
+
+// Update snapshots the metric entities and when telemetry last arrived.
+func (m *metricsExporter) Update(entities map[string]Entity, lastTelemetry time.Time) {
+	samples := make([]metricSample, 0, len(metricSpecs))
+	for _, spec := range metricSpecs {
+		e, ok := entities[spec.Key]
+		if !ok || (e.Available != nil && !e.Available()) {
+			continue
+		}
+		value, err := strconv.ParseFloat(e.Value(), 64)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, metricSample{spec: spec, value: value})
+	}
+
+	m.mu.Lock()
+	m.samples = samples
+	m.lastTelemetry = lastTelemetry
+	m.mu.Unlock()
+}
+
+func (m *metricsExporter) labels(extra string) string {
+	labels := fmt.Sprintf("device=%q", m.device)
+	if extra != "" {
+		labels += "," + extra
+	}
+	return "{" + labels + "}"
+}
+
+// Write renders the snapshot; now dates the telemetry age.
+func (m *metricsExporter) Write(b *strings.Builder, now time.Time) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	described := make(map[string]bool)
+	for _, s := range m.samples {
+		if !described[s.spec.Name] {
+			fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", s.spec.Name, s.spec.Help, s.spec.Name, s.spec.Type)
+			described[s.spec.Name] = true
+		}
+		fmt.Fprintf(b, "%s%s %s\n", s.spec.Name, m.labels(s.spec.Labels), strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+	if !m.lastTelemetry.IsZero() {
+		b.WriteString("# HELP wallbox_telemetry_age_seconds Seconds since the last telemetry event.\n# TYPE wallbox_telemetry_age_seconds gauge\n")
+		fmt.Fprintf(b, "wallbox_telemetry_age_seconds%s %s\n", m.labels(""), strconv.FormatFloat(now.Sub(m.lastTelemetry).Seconds(), 'f', 1, 64))
+	}
+}
+
+func (m *metricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	m.Write(&b, time.Now())
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// startMetricsServer serves /metrics on addr in the background. Stop it
+// with the returned function.
+func startMetricsServer(addr string, exporter *metricsExporter) (stop func()) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	server := &http.Server{Addr: addr, Handler: mux}
+	log.Printf("Serving Prometheus metrics on http://%s/metrics", addr)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Metrics server stopped: %v", err)
+		}
+	}()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+}
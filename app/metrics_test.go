@@ -0,0 +1,51 @@
+package bridge
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsExporter(t *testing.T) {
+	m := newMetricsExporter("ABC123")
+	now := time.Date(2025, 11, 23, 8, 0, 0, 0, time.UTC)
+
+	var b strings.Builder
+	m.Write(&b, now)
+	if b.Len() != 0 {
+		t.Fatalf("expected no metrics before the first update, got %q", b.String())
+	}
+
+	entities := map[string]Entity{
+		"charging_power":      {Getter: func() string { return "7200" }},
+		"charging_current_l1": {Getter: func() string { return "10.5" }},
+		"charging_current_l2": {Getter: func() string { return "10.25" }},
+		"ocpp_status_code":    {Getter: func() string { return "3" }, Available: func() bool { return false }},
+		"ocpp_mismatch":       {Getter: func() string { return "not a number" }},
+		"ocpp_restart_total":  {Getter: func() string { return "2" }},
+	}
+	m.Update(entities, now.Add(-90*time.Second))
+	m.Write(&b, now)
+	got := b.String()
+
+	for _, want := range []string{
+		"# TYPE wallbox_charging_power_watts gauge\n",
+		`wallbox_charging_power_watts{device="ABC123"} 7200` + "\n",
+		`wallbox_charging_current_amperes{device="ABC123",phase="1"} 10.5` + "\n",
+		`wallbox_charging_current_amperes{device="ABC123",phase="2"} 10.25` + "\n",
+		"# TYPE wallbox_ocpp_restarts_total counter\n",
+		`wallbox_telemetry_age_seconds{device="ABC123"} 90.0` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "# TYPE wallbox_charging_current_amperes"); n != 1 {
+		t.Errorf("expected the per-phase series to share one TYPE line, got %d", n)
+	}
+	for _, absent := range []string{"ocpp_status_code", "ocpp_mismatch", "phase=\"3\""} {
+		if strings.Contains(got, absent) {
+			t.Errorf("expected %s to be left out, got\n%s", absent, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package bridge
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// mqttConnectionStats tracks the bridge's own MQTT connection for the
+// diagnostic sensors. OnConnect runs on paho's goroutines.
+type mqttConnectionStats struct {
+	mu             sync.RWMutex
+	connectedSince time.Time
+	reconnects     int
+}
+
+// Connected records a successful (re)connect at now and reports whether it
+// was a reconnect, i.e. any connect after the first.
+func (s *mqttConnectionStats) Connected(now time.Time) (reconnect bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reconnect = !s.connectedSince.IsZero()
+	if reconnect {
+		s.reconnects++
+	}
+	s.connectedSince = now
+	return reconnect
+}
+
+// Reconnects returns how often the broker connection was re-established
+// since the bridge started.
+func (s *mqttConnectionStats) Reconnects() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.reconnects
+}
+
+// ConnectedSince returns when the current connection was established as
+// RFC3339, or "" before the first connect.
+func (s *mqttConnectionStats) ConnectedSince() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.connectedSince.IsZero() {
+		return ""
+	}
+	return s.connectedSince.Format(time.RFC3339)
+}
+
+func getMQTTConnectionEntities(stats *mqttConnectionStats) map[string]Entity {
+	return map[string]Entity{
+		"mqtt_connected_since": {
+			Component: "sensor",
+			Getter:    stats.ConnectedSince,
+			Config: map[string]string{
+				"name":            "MQTT connected since",
+				"device_class":    "timestamp",
+				"entity_category": "diagnostic",
+			},
+		},
+		"mqtt_reconnect_total": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(stats.Reconnects()) },
+			Config: map[string]string{
+				"name":            "MQTT reconnects",
+				"icon":            "mdi:lan-disconnect",
+				"state_class":     "total_increasing",
+				"entity_category": "diagnostic",
+			},
+		},
+	}
+}
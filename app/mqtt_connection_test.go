@@ -0,0 +1,30 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMQTTConnectionStats(t *testing.T) {
+	var stats mqttConnectionStats
+	entity := getMQTTConnectionEntities(&stats)["mqtt_connected_since"]
+	if got := entity.Value(); got != "" {
+		t.Fatalf("expected no timestamp before the first connect, got %q", got)
+	}
+
+	if stats.Connected(time.Date(2025, 11, 23, 8, 0, 0, 0, time.UTC)) {
+		t.Fatal("expected the first connect not to be reported as a reconnect")
+	}
+	if got := stats.Reconnects(); got != 0 {
+		t.Fatalf("expected the first connect not to count as a reconnect, got %d", got)
+	}
+	if !stats.Connected(time.Date(2025, 11, 23, 9, 30, 0, 0, time.UTC)) {
+		t.Fatal("expected the second connect to be reported as a reconnect")
+	}
+	if got := entity.Value(); got != "2025-11-23T09:30:00Z" {
+		t.Fatalf("expected the last connect to reset the timestamp, got %q", got)
+	}
+	if got := getMQTTConnectionEntities(&stats)["mqtt_reconnect_total"].Value(); got != "1" {
+		t.Fatalf("expected one reconnect, got %q", got)
+	}
+}
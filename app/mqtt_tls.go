@@ -0,0 +1,52 @@
+package bridge
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// mqttBrokerURL returns the broker address, using ssl:// when tls is
+// enabled.
+func mqttBrokerURL(c *WallboxConfig) string {
+	scheme := "tcp"
+	if c.MQTT.TLS {
+		scheme = "ssl"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, c.MQTT.Host, c.MQTT.Port)
+}
+
+// mqttTLSConfig builds the TLS settings from [mqtt], or returns nil when tls
+// is off. Certificate files are loaded here so a wrong path fails at startup
+// instead of surfacing as a handshake error on every connect attempt.
+func mqttTLSConfig(c *WallboxConfig) (*tls.Config, error) {
+	if !c.MQTT.TLS {
+		return nil, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: c.MQTT.InsecureSkipVerify}
+
+	if c.MQTT.CACert != "" {
+		pem, err := os.ReadFile(c.MQTT.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("reading MQTT ca_cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("MQTT ca_cert %s contains no PEM certificates", c.MQTT.CACert)
+		}
+		config.RootCAs = pool
+	}
+
+	if (c.MQTT.ClientCert == "") != (c.MQTT.ClientKey == "") {
+		return nil, fmt.Errorf("MQTT client_cert and client_key must be set together")
+	}
+	if c.MQTT.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.MQTT.ClientCert, c.MQTT.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading MQTT client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,94 @@
+package bridge
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate and its key as PEM files.
+func writeTestCert(t *testing.T, dir string) (certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "bridge"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath = filepath.Join(dir, "client.crt")
+	keyPath = filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func TestMQTTTLSConfig(t *testing.T) {
+	c := &WallboxConfig{}
+	c.MQTT.Host = "broker"
+	c.MQTT.Port = 8883
+	if got := mqttBrokerURL(c); got != "tcp://broker:8883" {
+		t.Fatalf("expected tcp:// without tls, got %s", got)
+	}
+	if config, err := mqttTLSConfig(c); config != nil || err != nil {
+		t.Fatalf("expected no TLS config without tls, got %v, %v", config, err)
+	}
+
+	c.MQTT.TLS = true
+	if got := mqttBrokerURL(c); got != "ssl://broker:8883" {
+		t.Fatalf("expected ssl:// with tls, got %s", got)
+	}
+
+	dir := t.TempDir()
+	certPath, keyPath := writeTestCert(t, dir)
+	c.MQTT.CACert = certPath
+	c.MQTT.ClientCert = certPath
+	c.MQTT.ClientKey = keyPath
+	config, err := mqttTLSConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.RootCAs == nil || len(config.Certificates) != 1 {
+		t.Fatalf("expected the CA and client certificate to be loaded, got %+v", config)
+	}
+
+	c.MQTT.ClientKey = ""
+	if _, err := mqttTLSConfig(c); err == nil {
+		t.Fatal("expected an error for client_cert without client_key")
+	}
+
+	c.MQTT.ClientKey = keyPath
+	c.MQTT.CACert = filepath.Join(dir, "missing.crt")
+	if _, err := mqttTLSConfig(c); err == nil || !strings.Contains(err.Error(), "ca_cert") {
+		t.Fatalf("expected a ca_cert error for a missing file, got %v", err)
+	}
+
+	c.MQTT.CACert = keyPath
+	if _, err := mqttTLSConfig(c); err == nil {
+		t.Fatal("expected an error for a ca_cert without certificates")
+	}
+}
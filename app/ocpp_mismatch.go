@@ -0,0 +1,79 @@
+package bridge
+
+import "time"
+
+// mismatchTracker times how long the pilot reports a connected car while OCPP
+// says the connector is free. Clearing is debounced by clearAfter: the
+// condition has to stay false that long, so a flapping pilot/OCPP pair keeps
+// accumulating mismatch time instead of restarting the heal timer each poll.
+// While clearing, the mismatch still counts as active but its timer is
+// frozen and no heal is due. A clearAfter of 0 clears on the first good
+// sample.
+type mismatchTracker struct {
+	clearAfter time.Duration
+	start      time.Time
+	clearStart time.Time
+}
+
+func newMismatchTracker(clearAfter time.Duration) *mismatchTracker {
+	return &mismatchTracker{clearAfter: clearAfter}
+}
+
+// Update feeds one poll sample and reports whether a mismatch started or
+// cleared with it.
+func (t *mismatchTracker) Update(now time.Time, mismatched bool) (started, cleared bool) {
+	if mismatched {
+		t.clearStart = time.Time{}
+		if t.start.IsZero() {
+			t.start = now
+			return true, false
+		}
+		return false, false
+	}
+
+	if t.start.IsZero() {
+		return false, false
+	}
+	if t.clearStart.IsZero() {
+		t.clearStart = now
+	}
+	if now.Sub(t.clearStart) < t.clearAfter {
+		return false, false
+	}
+
+	t.start = time.Time{}
+	t.clearStart = time.Time{}
+	return false, true
+}
+
+// Active reports whether a mismatch is currently flagged.
+func (t *mismatchTracker) Active() bool {
+	return !t.start.IsZero()
+}
+
+// Duration returns how long the current mismatch timer has been running. It
+// stops at the last mismatched sample while the mismatch is clearing.
+func (t *mismatchTracker) Duration(now time.Time) time.Duration {
+	if t.start.IsZero() {
+		return 0
+	}
+	if !t.clearStart.IsZero() {
+		return t.clearStart.Sub(t.start)
+	}
+	return now.Sub(t.start)
+}
+
+// HealDue reports whether the mismatch has lasted threshold and the latest
+// sample still shows it, so a heal never acts on a mismatch that already
+// recovered and is only waiting out the clear hysteresis.
+func (t *mismatchTracker) HealDue(now time.Time, threshold time.Duration) bool {
+	return t.Active() && t.clearStart.IsZero() && t.Duration(now) >= threshold
+}
+
+// RestartTimer restarts the mismatch timer after a heal attempt so the next
+// attempt waits for a full threshold again.
+func (t *mismatchTracker) RestartTimer(now time.Time) {
+	if !t.start.IsZero() {
+		t.start = now
+	}
+}
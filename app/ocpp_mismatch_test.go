@@ -0,0 +1,139 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMismatchTracker_FlappingKeepsAccumulating(t *testing.T) {
+	m := newMismatchTracker(30 * time.Second)
+	start := time.Now()
+
+	// Poll every 10s; the condition flaps on every other sample.
+	for i := 0; i <= 18; i++ {
+		now := start.Add(time.Duration(i) * 10 * time.Second)
+		started, cleared := m.Update(now, i%2 == 0)
+		if started && i != 0 {
+			t.Fatalf("mismatch restarted at sample %d", i)
+		}
+		if cleared {
+			t.Fatalf("flapping condition cleared the mismatch at sample %d", i)
+		}
+		if !m.Active() {
+			t.Fatalf("expected mismatch to stay active at sample %d", i)
+		}
+	}
+
+	if got := m.Duration(start.Add(180 * time.Second)); got != 180*time.Second {
+		t.Fatalf("expected 3 minutes of accumulated mismatch, got %s", got)
+	}
+}
+
+func TestMismatchTracker_ClearsAfterHysteresis(t *testing.T) {
+	m := newMismatchTracker(30 * time.Second)
+	start := time.Now()
+
+	if started, _ := m.Update(start, true); !started {
+		t.Fatalf("expected mismatch to start")
+	}
+	if _, cleared := m.Update(start.Add(10*time.Second), false); cleared {
+		t.Fatalf("cleared before the hysteresis elapsed")
+	}
+	if _, cleared := m.Update(start.Add(39*time.Second), false); cleared {
+		t.Fatalf("cleared 29s into the hysteresis")
+	}
+	if _, cleared := m.Update(start.Add(40*time.Second), false); !cleared {
+		t.Fatalf("expected mismatch to clear after 30s without the condition")
+	}
+	if m.Active() || m.Duration(start.Add(time.Minute)) != 0 {
+		t.Fatalf("expected no active mismatch after clearing")
+	}
+
+	// A new mismatch starts a fresh timer.
+	now := start.Add(2 * time.Minute)
+	if started, _ := m.Update(now, true); !started {
+		t.Fatalf("expected a new mismatch to start")
+	}
+	if got := m.Duration(now.Add(5 * time.Second)); got != 5*time.Second {
+		t.Fatalf("expected fresh timer, got %s", got)
+	}
+}
+
+func TestMismatchTracker_ZeroHysteresisClearsImmediately(t *testing.T) {
+	m := newMismatchTracker(0)
+	start := time.Now()
+
+	m.Update(start, true)
+	if _, cleared := m.Update(start.Add(10*time.Second), false); !cleared {
+		t.Fatalf("expected immediate clear without hysteresis")
+	}
+}
+
+func TestMismatchTracker_RestartTimer(t *testing.T) {
+	m := newMismatchTracker(30 * time.Second)
+	start := time.Now()
+
+	m.Update(start, true)
+	m.RestartTimer(start.Add(time.Minute))
+	if got := m.Duration(start.Add(90 * time.Second)); got != 30*time.Second {
+		t.Fatalf("expected timer to restart at the heal, got %s", got)
+	}
+
+	idle := newMismatchTracker(0)
+	idle.RestartTimer(start)
+	if idle.Active() {
+		t.Fatalf("RestartTimer must not start a mismatch")
+	}
+}
+
+func TestMismatchTracker_DurationZeroWhenInactive(t *testing.T) {
+	m := newMismatchTracker(0)
+	start := time.Now()
+
+	if got := m.Duration(start); got != 0 {
+		t.Fatalf("expected 0 before any mismatch, got %s", got)
+	}
+	m.Update(start, true)
+	if got := m.Duration(start.Add(45 * time.Second)); got != 45*time.Second {
+		t.Fatalf("expected 45s of mismatch, got %s", got)
+	}
+	if _, cleared := m.Update(start.Add(50*time.Second), false); !cleared {
+		t.Fatalf("expected the mismatch to clear immediately")
+	}
+	if got := m.Duration(start.Add(60 * time.Second)); got != 0 {
+		t.Fatalf("expected 0 after the mismatch cleared, got %s", got)
+	}
+}
+
+func TestMismatchTracker_NoHealWhileClearing(t *testing.T) {
+	m := newMismatchTracker(30 * time.Second)
+	threshold := time.Minute
+	start := time.Now()
+
+	m.Update(start, true)
+	m.Update(start.Add(50*time.Second), true)
+	// The condition recovers 10s before the threshold and the threshold
+	// expires inside the clear window.
+	for _, at := range []time.Duration{55 * time.Second, 65 * time.Second, 75 * time.Second} {
+		now := start.Add(at)
+		if _, cleared := m.Update(now, false); cleared {
+			t.Fatalf("cleared before the hysteresis elapsed at %s", at)
+		}
+		if !m.Active() {
+			t.Fatalf("expected the mismatch to stay active while clearing")
+		}
+		if m.HealDue(now, threshold) {
+			t.Fatalf("heal fired at %s although the mismatch already recovered", at)
+		}
+		if got := m.Duration(now); got != 55*time.Second {
+			t.Fatalf("expected the timer to freeze at 55s while clearing, got %s", got)
+		}
+	}
+
+	// Back to mismatched: the timer runs on and the heal is due again.
+	now := start.Add(80 * time.Second)
+	m.Update(now, true)
+	if !m.HealDue(now, threshold) {
+		t.Fatalf("expected a heal once the mismatch is back past the threshold")
+	}
+}
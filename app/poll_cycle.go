@@ -0,0 +1,78 @@
+package bridge
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"wallbox-mqtt-bridge/app/ratelimit"
+)
+
+// pollCycleWindow is how many recent poll cycles the rolling max covers.
+const pollCycleWindow = 60
+
+// pollCycleStats keeps the duration of the last poll cycle (refresh, heal
+// checks and publishing) and the longest of the last pollCycleWindow cycles.
+type pollCycleStats struct {
+	mu      sync.RWMutex
+	samples [pollCycleWindow]time.Duration
+	next    int
+	last    time.Duration
+}
+
+// Record adds the duration of a finished cycle.
+func (s *pollCycleStats) Record(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = d
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % pollCycleWindow
+}
+
+func (s *pollCycleStats) Last() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.last
+}
+
+func (s *pollCycleStats) Max() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var max time.Duration
+	for _, d := range s.samples {
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+func getPollCycleEntities(stats *pollCycleStats) map[string]Entity {
+	return map[string]Entity{
+		"poll_cycle_duration": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(stats.Last().Milliseconds()) },
+			RateLimit: ratelimit.NewDeltaRateLimit(60, 100),
+			Config: map[string]string{
+				"name":                "Poll cycle duration",
+				"icon":                "mdi:timer-outline",
+				"device_class":        "duration",
+				"unit_of_measurement": "ms",
+				"state_class":         "measurement",
+				"entity_category":     "diagnostic",
+			},
+		},
+		"poll_cycle_duration_max": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(stats.Max().Milliseconds()) },
+			Config: map[string]string{
+				"name":                "Poll cycle duration max",
+				"icon":                "mdi:timer-alert-outline",
+				"device_class":        "duration",
+				"unit_of_measurement": "ms",
+				"state_class":         "measurement",
+				"entity_category":     "diagnostic",
+			},
+		},
+	}
+}
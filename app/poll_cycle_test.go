@@ -0,0 +1,31 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPollCycleStats(t *testing.T) {
+	var stats pollCycleStats
+	if stats.Last() != 0 || stats.Max() != 0 {
+		t.Fatalf("expected zero durations before the first cycle")
+	}
+
+	stats.Record(900 * time.Millisecond)
+	stats.Record(120 * time.Millisecond)
+	if stats.Last() != 120*time.Millisecond || stats.Max() != 900*time.Millisecond {
+		t.Fatalf("expected last 120ms and max 900ms, got %s and %s", stats.Last(), stats.Max())
+	}
+
+	// The slow cycle drops out of the window once enough cycles follow it.
+	for i := 0; i < pollCycleWindow-1; i++ {
+		stats.Record(100 * time.Millisecond)
+	}
+	if got := stats.Max(); got != 120*time.Millisecond {
+		t.Fatalf("expected max 120ms after the window rolled, got %s", got)
+	}
+	stats.Record(100 * time.Millisecond)
+	if got := stats.Max(); got != 100*time.Millisecond {
+		t.Fatalf("expected max 100ms, got %s", got)
+	}
+}
@@ -0,0 +1,69 @@
+package bridge
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallbox-mqtt-bridge/app/ratelimit"
+)
+
+// defaultRateLimitSeconds is how long a [ratelimit] entry without an
+// interval holds back changes smaller than its threshold.
+const defaultRateLimitSeconds = 60
+
+// parseRateLimit parses a [ratelimit] value: "<threshold>" or
+// "<threshold>, <seconds>". A threshold of 0 turns rate limiting off.
+func parseRateLimit(value string) (threshold float64, seconds int, err error) {
+	parts := strings.Split(value, ",")
+	if len(parts) > 2 {
+		return 0, 0, fmt.Errorf("expected <threshold>[, <seconds>], got %q", value)
+	}
+	threshold, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || threshold < 0 {
+		return 0, 0, fmt.Errorf("invalid threshold %q", strings.TrimSpace(parts[0]))
+	}
+	seconds = defaultRateLimitSeconds
+	if len(parts) == 2 {
+		seconds, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || seconds <= 0 {
+			return 0, 0, fmt.Errorf("invalid interval %q", strings.TrimSpace(parts[1]))
+		}
+	}
+	return threshold, seconds, nil
+}
+
+// applyRateLimits replaces the rate limit of every entity listed in
+// [ratelimit]: a change smaller than the threshold is only published once
+// the interval has passed since the last publish. Unknown entities and
+// invalid values are logged and skipped.
+func applyRateLimits(entityConfig map[string]Entity, entries map[string]string) {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		e, ok := entityConfig[key]
+		if !ok {
+			log.Printf("Ignoring rate limit for unknown entity %q", key)
+			continue
+		}
+		threshold, seconds, err := parseRateLimit(entries[key])
+		if err != nil {
+			log.Printf("Ignoring rate limit for %s: %v", key, err)
+			continue
+		}
+		if threshold == 0 {
+			e.RateLimit = nil
+		} else {
+			// NewDeltaRateLimit takes the interval in seconds.
+			e.RateLimit = ratelimit.NewDeltaRateLimit(time.Duration(seconds), threshold)
+		}
+		entityConfig[key] = e
+	}
+}
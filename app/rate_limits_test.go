@@ -0,0 +1,73 @@
+package bridge
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"wallbox-mqtt-bridge/app/ratelimit"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	cases := []struct {
+		value     string
+		threshold float64
+		seconds   int
+		ok        bool
+	}{
+		{"50", 50, defaultRateLimitSeconds, true},
+		{" 0.5 , 30 ", 0.5, 30, true},
+		{"0", 0, defaultRateLimitSeconds, true},
+		{"fifty", 0, 0, false},
+		{"-1", 0, 0, false},
+		{"50, 0", 0, 0, false},
+		{"50, 30, 10", 0, 0, false},
+	}
+	for _, tc := range cases {
+		threshold, seconds, err := parseRateLimit(tc.value)
+		if (err == nil) != tc.ok {
+			t.Fatalf("%q: unexpected error %v", tc.value, err)
+		}
+		if tc.ok && (threshold != tc.threshold || seconds != tc.seconds) {
+			t.Fatalf("%q: expected %v/%d, got %v/%d", tc.value, tc.threshold, tc.seconds, threshold, seconds)
+		}
+	}
+}
+
+func TestApplyRateLimits(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	entities := map[string]Entity{
+		"charging_power":  {RateLimit: ratelimit.NewDeltaRateLimit(10, 100)},
+		"charging_energy": {RateLimit: ratelimit.NewDeltaRateLimit(10, 100)},
+		"temperature":     {},
+	}
+	applyRateLimits(entities, map[string]string{
+		"charging_power":  "50",
+		"charging_energy": "0",
+		"temperature":     "warm",
+		"no_such_entity":  "10",
+	})
+
+	limit := entities["charging_power"].RateLimit
+	if limit == nil || !limit.Allow(1000) || limit.Allow(1040) || !limit.Allow(1060) {
+		t.Fatalf("expected charging_power to hold back changes below 50")
+	}
+	if entities["charging_energy"].RateLimit != nil {
+		t.Fatalf("expected a threshold of 0 to remove the rate limit")
+	}
+	if entities["temperature"].RateLimit != nil {
+		t.Fatalf("expected an invalid value to be ignored")
+	}
+	if _, ok := entities["no_such_entity"]; ok {
+		t.Fatalf("unknown entities must not be created")
+	}
+	out := buf.String()
+	if !strings.Contains(out, `unknown entity "no_such_entity"`) || !strings.Contains(out, "temperature") {
+		t.Fatalf("expected warnings for the unknown entity and the invalid value, got:\n%s", out)
+	}
+}
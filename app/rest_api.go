@@ -0,0 +1,79 @@
+package bridge
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxSetterBody bounds the payload accepted by POST /entities/{key}; setter
+// payloads are single values like "16" or "PRESS".
+const maxSetterBody = 1024
+
+// entityAPI is a small REST API next to the status page:
+//
+//	GET  /entities        current values of all entities as a JSON object
+//	POST /entities/{key}  call the entity's setter with the request body,
+//	                      the same payload its MQTT command topic takes
+//
+// Values come from the status page snapshot, so reads never call getters.
+// With a token, writes need an "Authorization: Bearer <token>" header.
+type entityAPI struct {
+	page     *statusPage
+	entities map[string]Entity
+	token    string
+}
+
+func (a *entityAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/entities" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a.page.Values())
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/entities/")
+	e, ok := a.entities[key]
+	if !ok || strings.Contains(key, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if e.Setter == nil {
+		http.Error(w, key+" is read-only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSetterBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	payload := strings.TrimSpace(string(body))
+	log.Printf("Setting %s %s via REST API", key, payload)
+	e.Setter(payload)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *entityAPI) authorized(r *http.Request) bool {
+	if a.token == "" {
+		return true
+	}
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(a.token)) == 1
+}
@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEntityAPI(t *testing.T) {
+	var set []string
+	entities := map[string]Entity{
+		"max_charging_current": {
+			Component: "number",
+			Getter:    func() string { return "16" },
+			Setter:    func(val string) { set = append(set, val) },
+		},
+		"charging_power": {Component: "sensor", Getter: func() string { return "7200" }},
+	}
+	page := newStatusPage("Wallbox", 5)
+	page.Update(entities, time.Now())
+	api := &entityAPI{page: page, entities: entities, token: "s3cret"}
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest("GET", "/entities", nil))
+	var values map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &values); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if want := map[string]string{"max_charging_current": "16", "charging_power": "7200"}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("expected %v, got %v", want, values)
+	}
+
+	cases := []struct {
+		method, path, token, body string
+		want                      int
+	}{
+		{"POST", "/entities/max_charging_current", "", "20", 401},
+		{"POST", "/entities/max_charging_current", "wrong", "20", 401},
+		{"POST", "/entities/max_charging_current", "s3cret", " 20\n", 204},
+		{"GET", "/entities/max_charging_current", "s3cret", "", 405},
+		{"POST", "/entities/charging_power", "s3cret", "1", 405},
+		{"POST", "/entities/missing", "s3cret", "1", 404},
+		{"POST", "/entities", "s3cret", "1", 405},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s %s (token %q): expected %d, got %d", tc.method, tc.path, tc.token, tc.want, rec.Code)
+		}
+	}
+	if !reflect.DeepEqual(set, []string{"20"}) {
+		t.Fatalf("expected the setter to be called once with the trimmed body, got %v", set)
+	}
+}
@@ -0,0 +1,109 @@
+package bridge
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"wallbox-mqtt-bridge/app/wallbox"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	selfTestSerial = "selftest"
+	selfTestHold   = 10 * time.Second
+)
+
+// RunSelfTest validates the MQTT/Home Assistant side without a charger. It
+// connects to the broker from the config, publishes discovery and dummy
+// values for every entity under a separate "selftest" device, holds them for
+// a few seconds and then removes the device again. MySQL and Redis are never
+// touched.
+func RunSelfTest(configPath string) {
+	c := LoadConfig(configPath)
+	c.applyDefaults()
+	c.Settings.DeviceName += " (self-test)"
+
+	w := wallbox.NewStub()
+	entityConfig := buildEntityConfig(w, c)
+
+	topicPrefix := "wallbox_" + selfTestSerial
+	availabilityTopic := topicPrefix + "/availability"
+
+	opts, err := mqttClientOptions(c, availabilityTopic)
+	if err != nil {
+		log.Fatalf("Self-test: %v", err)
+	}
+	// Fail fast instead of waiting for an unreachable broker.
+	opts.SetConnectRetry(false)
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		log.Fatalf("Self-test: cannot connect to MQTT broker %s:%d: %v", c.MQTT.Host, c.MQTT.Port, token.Error())
+	}
+	fmt.Printf("Self-test: connected to MQTT broker %s:%d\n", c.MQTT.Host, c.MQTT.Port)
+
+	publishDiscovery(client, c, entityConfig, selfTestSerial, "self-test")
+	client.Publish(availabilityTopic, 1, true, c.MQTT.PayloadAvailable).Wait()
+
+	for key, val := range entityConfig {
+		if val.Component == "button" {
+			continue
+		}
+		client.Publish(topicPrefix+"/"+key+"/state", 1, true, selfTestValue(val)).Wait()
+		if val.Available != nil {
+			client.Publish(topicPrefix+"/"+key+"/availability", 1, true, c.MQTT.PayloadAvailable).Wait()
+		}
+	}
+	fmt.Printf("Self-test: published %d entities under device %q; check Home Assistant now\n", len(entityConfig), c.Settings.DeviceName)
+
+	time.Sleep(selfTestHold)
+
+	// Remove the fake device again so it does not linger in Home Assistant.
+	client.Publish(availabilityTopic, 1, true, c.MQTT.PayloadNotAvailable).Wait()
+	for key, val := range entityConfig {
+		uid := selfTestSerial + "_" + key
+		client.Publish("homeassistant/"+val.Component+"/"+uid+"/config", 1, true, "").Wait()
+		client.Publish(topicPrefix+"/"+key+"/state", 1, true, "").Wait()
+		if val.Available != nil {
+			client.Publish(topicPrefix+"/"+key+"/availability", 1, true, "").Wait()
+		}
+	}
+	client.Publish(availabilityTopic, 1, true, "").Wait()
+	client.Disconnect(250)
+	fmt.Println("Self-test: done, self-test device removed")
+}
+
+// selfTestValue picks a plausible dummy state for an entity based on its
+// component and discovery config.
+func selfTestValue(e Entity) string {
+	switch e.Component {
+	case "binary_sensor", "switch":
+		if on, ok := e.Config["payload_on"]; ok {
+			return on
+		}
+		return "ON"
+	case "lock":
+		if locked, ok := e.Config["state_locked"]; ok {
+			return locked
+		}
+		return "LOCKED"
+	case "select":
+		if len(e.Options) > 0 {
+			return e.Options[0]
+		}
+	case "number":
+		if min, ok := e.Config["min"]; ok {
+			return min
+		}
+		return "0"
+	}
+
+	if e.Config["device_class"] == "timestamp" {
+		return time.Now().Format(time.RFC3339)
+	}
+	if e.Config["unit_of_measurement"] != "" || e.Config["state_class"] != "" {
+		return "0"
+	}
+	return "self-test"
+}
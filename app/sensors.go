@@ -3,7 +3,10 @@ package bridge
 import (
 	"fmt"
 	"log"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 
 	"wallbox-mqtt-bridge/app/ratelimit"
 	"wallbox-mqtt-bridge/app/wallbox"
@@ -15,6 +18,102 @@ type Entity struct {
 	Setter    func(string)
 	RateLimit *ratelimit.DeltaRateLimit
 	Config    map[string]string
+	// Condition, when set, withholds discovery and state publishing until it
+	// first returns true (e.g. only once the charger reports a GSM link).
+	Condition func() bool
+	// Available, when set, gives the entity its own availability topic next
+	// to the bridge-wide one, for entities whose data source can be missing
+	// independently (e.g. telemetry-only sensors on legacy firmware).
+	Available func() bool
+	// Format, when set, rewrites the getter's value before it is published,
+	// e.g. to round floats; see precisionFormat.
+	Format func(string) string
+	// Options lists the choices of a select entity.
+	Options []string
+	// AlwaysPublish re-sends the state every poll even when it is unchanged
+	// and bypasses RateLimit; see applyAlwaysPublish.
+	AlwaysPublish bool
+}
+
+// Value returns the entity's current value as it is published.
+func (e Entity) Value() string {
+	value := e.Getter()
+	if e.Format != nil {
+		value = e.Format(value)
+	}
+	return value
+}
+
+// precisionFormat returns a Format that rounds numeric values to digits
+// decimals. Non-numeric values are left untouched.
+func precisionFormat(digits int) func(string) string {
+	return func(value string) string {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return value
+		}
+		return strconv.FormatFloat(f, 'f', digits, 64)
+	}
+}
+
+// applyPrecision sets a precisionFormat on the entities listed in spec, a
+// comma-separated list of "key:digits" entries.
+func applyPrecision(entityConfig map[string]Entity, spec string) {
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, digitsStr, found := strings.Cut(entry, ":")
+		key = strings.TrimSpace(key)
+		digits, err := strconv.Atoi(strings.TrimSpace(digitsStr))
+		if !found || err != nil || digits < 0 {
+			log.Printf("Ignoring invalid precision entry %q, expected key:digits", entry)
+			continue
+		}
+		e, ok := entityConfig[key]
+		if !ok {
+			log.Printf("Ignoring precision for unknown entity %q", key)
+			continue
+		}
+		e.Format = precisionFormat(digits)
+		entityConfig[key] = e
+	}
+}
+
+// applyAlwaysPublish sets AlwaysPublish on the entities listed in spec, a
+// comma-separated list of keys, or on every entity for "*".
+func applyAlwaysPublish(entityConfig map[string]Entity, spec string) {
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if key == "*" {
+			for k, e := range entityConfig {
+				e.AlwaysPublish = true
+				entityConfig[k] = e
+			}
+			continue
+		}
+		e, ok := entityConfig[key]
+		if !ok {
+			log.Printf("Ignoring always_publish for unknown entity %q", key)
+			continue
+		}
+		e.AlwaysPublish = true
+		entityConfig[key] = e
+	}
+}
+
+// forgetAlwaysPublished drops the last published state of AlwaysPublish
+// entities so the next publishChangedStates sends them again.
+func forgetAlwaysPublished(entityConfig map[string]Entity, published map[string]interface{}) {
+	for key, e := range entityConfig {
+		if e.AlwaysPublish {
+			delete(published, key)
+		}
+	}
 }
 
 func strToInt(val string) int {
@@ -22,6 +121,74 @@ func strToInt(val string) int {
 	return i
 }
 
+// parseIntList parses a comma-separated list of integers, skipping (and
+// logging) anything that is not one.
+func parseIntList(spec string) []int {
+	var values []int
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		v, err := strconv.Atoi(entry)
+		if err != nil {
+			log.Printf("Ignoring invalid number %q in %q", entry, spec)
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// parseCodeMap parses "code:value" pairs such as "195:Charging 3, 196:C",
+// skipping (and logging) entries without a numeric code or a value.
+func parseCodeMap(spec string) map[int]string {
+	values := make(map[int]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		codeStr, value, _ := strings.Cut(entry, ":")
+		code, err := strconv.Atoi(strings.TrimSpace(codeStr))
+		value = strings.TrimSpace(value)
+		if err != nil || value == "" {
+			log.Printf("Ignoring invalid entry %q in %q, expected code:value", entry, spec)
+			continue
+		}
+		values[code] = value
+	}
+	return values
+}
+
+// controlPilotOverrides builds the control-pilot map overrides from the
+// [control_pilot] section.
+func controlPilotOverrides(c *WallboxConfig) wallbox.ControlPilotOverrides {
+	overrides := wallbox.ControlPilotOverrides{
+		States:    parseCodeMap(c.ControlPilot.States),
+		Letters:   parseCodeMap(c.ControlPilot.Letters),
+		Connected: make(map[int]bool),
+	}
+	for code, value := range parseCodeMap(c.ControlPilot.Connected) {
+		connected, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Printf("Ignoring invalid connected value %q for control pilot %d, expected true/false", value, code)
+			continue
+		}
+		overrides.Connected[code] = connected
+	}
+	return overrides
+}
+
+// ocppSourceValue formats one OCPP status source, "unknown" if it has no
+// recent status.
+func ocppSourceValue(code int, ok bool) string {
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprint(code)
+}
+
 func strToFloat(val string) float64 {
 	f, _ := strconv.ParseFloat(val, 64)
 	return f
@@ -41,6 +208,17 @@ func getEntities(w *wallbox.Wallbox) map[string]Entity {
 				"suggested_display_precision": "1",
 			},
 		},
+		"resync_session_energy": {
+			Component: "button",
+			Getter:    func() string { return "" }, // stateless button
+			Setter:    func(_ string) { w.ResyncSessionEnergyBaseline() },
+			Config: map[string]string{
+				"name":            "Re-sync session energy",
+				"icon":            "mdi:counter",
+				"payload_press":   "PRESS",
+				"entity_category": "config",
+			},
+		},
 		"added_range": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.SQL.AddedRange) },
@@ -53,6 +231,25 @@ func getEntities(w *wallbox.Wallbox) map[string]Entity {
 				"icon":                        "mdi:map-marker-distance",
 			},
 		},
+		"lifetime_added_range": {
+			Component: "sensor",
+			Getter: func() string {
+				km, _ := w.LifetimeAddedRange()
+				return fmt.Sprint(km)
+			},
+			Condition: func() bool {
+				_, ok := w.LifetimeAddedRange()
+				return ok
+			},
+			Config: map[string]string{
+				"name":                        "Lifetime added range",
+				"device_class":                "distance",
+				"unit_of_measurement":         "km",
+				"state_class":                 "total_increasing",
+				"suggested_display_precision": "0",
+				"icon":                        "mdi:map-marker-distance",
+			},
+		},
 		"cable_connected": {
 			Component: "binary_sensor",
 			Getter:    func() string { return fmt.Sprint(w.CableConnected()) },
@@ -64,6 +261,33 @@ func getEntities(w *wallbox.Wallbox) map[string]Entity {
 				"device_class": "plug",
 			},
 		},
+		"car_connected_duration": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(int(w.CarConnectedDuration().Seconds())) },
+			RateLimit: ratelimit.NewDeltaRateLimit(60, 60),
+			Config: map[string]string{
+				"name":                "Car connected duration",
+				"device_class":        "duration",
+				"unit_of_measurement": "s",
+				"state_class":         "measurement",
+				"icon":                "mdi:car-clock",
+			},
+		},
+		"charging": {
+			Component: "binary_sensor",
+			Getter: func() string {
+				if w.IsCharging() {
+					return "1"
+				}
+				return "0"
+			},
+			Config: map[string]string{
+				"name":         "Charging",
+				"payload_on":   "1",
+				"payload_off":  "0",
+				"device_class": "battery_charging",
+			},
+		},
 		"charging_enable": {
 			Component: "switch",
 			Setter:    func(val string) { w.SetChargingEnable(strToInt(val)) },
@@ -159,6 +383,56 @@ func getEntities(w *wallbox.Wallbox) map[string]Entity {
 				"suggested_display_precision": "1",
 			},
 		},
+		"charging_voltage_l1": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.ChargingVoltageL1()) },
+			RateLimit: ratelimit.NewDeltaRateLimit(10, 1),
+			Config: map[string]string{
+				"name":                        "Charging voltage L1",
+				"device_class":                "voltage",
+				"unit_of_measurement":         "V",
+				"state_class":                 "measurement",
+				"suggested_display_precision": "0",
+			},
+		},
+		"charging_voltage_l2": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.ChargingVoltageL2()) },
+			RateLimit: ratelimit.NewDeltaRateLimit(10, 1),
+			Config: map[string]string{
+				"name":                        "Charging voltage L2",
+				"device_class":                "voltage",
+				"unit_of_measurement":         "V",
+				"state_class":                 "measurement",
+				"suggested_display_precision": "0",
+			},
+		},
+		"charging_voltage_l3": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.ChargingVoltageL3()) },
+			RateLimit: ratelimit.NewDeltaRateLimit(10, 1),
+			Config: map[string]string{
+				"name":                        "Charging voltage L3",
+				"device_class":                "voltage",
+				"unit_of_measurement":         "V",
+				"state_class":                 "measurement",
+				"suggested_display_precision": "0",
+			},
+		},
+		"grid_frequency": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.GridFrequency()) },
+			// A zero reading means unknown; don't graph it as a drop to 0 Hz.
+			Available: func() bool { return w.GridFrequency() != 0 },
+			RateLimit: ratelimit.NewDeltaRateLimit(10, 0.05),
+			Config: map[string]string{
+				"name":                        "Grid frequency",
+				"device_class":                "frequency",
+				"unit_of_measurement":         "Hz",
+				"state_class":                 "measurement",
+				"suggested_display_precision": "2",
+			},
+		},
 		"cumulative_added_energy": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.SQL.CumulativeAddedEnergy) },
@@ -170,6 +444,224 @@ func getEntities(w *wallbox.Wallbox) map[string]Entity {
 				"suggested_display_precision": "1",
 			},
 		},
+		"ecosmart_green_energy": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.EcosmartGreenEnergy()) },
+			// Until its own sample arrives the counter reads 0, even with
+			// other telemetry in; a total_increasing sensor must not drop
+			// to it or Home Assistant counts a meter reset.
+			Available: func() bool { return w.HasTelemetry && w.EcosmartGreenEnergy() > 0 },
+			Config: map[string]string{
+				"name":                        "EcoSmart green energy",
+				"icon":                        "mdi:solar-power",
+				"device_class":                "energy",
+				"unit_of_measurement":         "Wh",
+				"state_class":                 "total_increasing",
+				"suggested_display_precision": "1",
+			},
+		},
+		"ecosmart_energy_total": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.EcosmartEnergyTotal()) },
+			Available: func() bool { return w.HasTelemetry && w.EcosmartEnergyTotal() > 0 },
+			Config: map[string]string{
+				"name":                        "EcoSmart total energy",
+				"icon":                        "mdi:leaf",
+				"device_class":                "energy",
+				"unit_of_measurement":         "Wh",
+				"state_class":                 "total_increasing",
+				"suggested_display_precision": "1",
+			},
+		},
+		"contactor_cycles": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.ContactorCycles()) },
+			Config: map[string]string{
+				"name":            "Contactor cycles",
+				"icon":            "mdi:electric-switch",
+				"state_class":     "total_increasing",
+				"entity_category": "diagnostic",
+			},
+		},
+		// The default schedules query is a guess, so the configured windows
+		// are only offered once it has worked.
+		"schedule_window": {
+			Component: "sensor",
+			Getter:    w.ScheduleWindow,
+			Condition: w.SchedulesKnown,
+			Config: map[string]string{
+				"name": "Schedule window",
+				"icon": "mdi:calendar-clock",
+			},
+		},
+		"schedule_days": {
+			Component: "sensor",
+			Getter:    w.ScheduleDays,
+			Condition: w.SchedulesKnown,
+			Config: map[string]string{
+				"name": "Schedule days",
+				"icon": "mdi:calendar-week",
+			},
+		},
+		"schedule_next_start": {
+			Component: "sensor",
+			Getter:    w.ScheduleNextStart,
+			Condition: w.SchedulesKnown,
+			Config: map[string]string{
+				"name":         "Schedule start",
+				"device_class": "timestamp",
+			},
+		},
+		// What the charger's own schedule currently does to the current,
+		// as opposed to the configured windows above.
+		"schedule_status": {
+			Component: "sensor",
+			Getter:    w.ScheduleStatus,
+			Available: func() bool { return w.HasTelemetry },
+			Config: map[string]string{
+				"name": "Schedule status",
+				"icon": "mdi:calendar-clock",
+			},
+		},
+		"schedule_current_proposal": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.ScheduleCurrentProposal()) },
+			Available: func() bool { return w.HasTelemetry },
+			Config: map[string]string{
+				"name":                        "Schedule current proposal",
+				"icon":                        "mdi:calendar-clock",
+				"device_class":                "current",
+				"unit_of_measurement":         "A",
+				"state_class":                 "measurement",
+				"suggested_display_precision": "1",
+			},
+		},
+		// Turning this off hands scheduling over to Home Assistant without
+		// deleting the charger's schedules. It needs both the schedules
+		// read and the set_schedules_enabled write to fit the database.
+		"schedules_enabled": {
+			Component: "switch",
+			Condition: func() bool { return w.SchedulesWritable() && w.SchedulesKnown() },
+			Setter: func(val string) {
+				if err := w.SetSchedulesEnabled(val == "1"); err != nil {
+					log.Printf("Failed to set charging schedules: %v", err)
+				}
+			},
+			Getter: func() string {
+				if w.SchedulesEnabled() {
+					return "1"
+				}
+				return "0"
+			},
+			Config: map[string]string{
+				"name":            "Charging schedules",
+				"payload_on":      "1",
+				"payload_off":     "0",
+				"icon":            "mdi:calendar-check",
+				"entity_category": "config",
+			},
+		},
+		"ocpp_status_journal": {
+			Component: "sensor",
+			Getter:    func() string { return ocppSourceValue(w.JournalOCPPStatusCode()) },
+			Config: map[string]string{
+				"name":            "OCPP status (journal)",
+				"icon":            "mdi:numeric",
+				"entity_category": "diagnostic",
+			},
+		},
+		"ocpp_status_session": {
+			Component: "sensor",
+			Getter:    func() string { return ocppSourceValue(w.SessionOCPPStatusCode()) },
+			Config: map[string]string{
+				"name":            "OCPP status (session)",
+				"icon":            "mdi:numeric",
+				"entity_category": "diagnostic",
+			},
+		},
+		"active_session_id": {
+			Component: "sensor",
+			Getter:    w.ActiveSessionID,
+			Config: map[string]string{
+				"name":            "Active session ID",
+				"icon":            "mdi:identifier",
+				"entity_category": "diagnostic",
+			},
+		},
+		"timezone": {
+			Component: "sensor",
+			Getter:    w.Timezone,
+			Config: map[string]string{
+				"name":            "Timezone",
+				"icon":            "mdi:map-clock-outline",
+				"entity_category": "diagnostic",
+			},
+		},
+		"ocpp_transaction_id": {
+			Component: "sensor",
+			Getter:    w.OCPPTransactionID,
+			Config: map[string]string{
+				"name":            "OCPP transaction ID",
+				"icon":            "mdi:identifier",
+				"entity_category": "diagnostic",
+			},
+		},
+		"connector_type": {
+			Component: "sensor",
+			Getter:    w.ConnectorType,
+			Config: map[string]string{
+				"name":            "Connector type",
+				"icon":            "mdi:ev-plug-type2",
+				"entity_category": "diagnostic",
+			},
+		},
+		"lock_count": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.LockCount()) },
+			Config: map[string]string{
+				"name":            "Lock count",
+				"icon":            "mdi:lock-outline",
+				"state_class":     "total_increasing",
+				"entity_category": "diagnostic",
+			},
+		},
+		"unlock_count": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.UnlockCount()) },
+			Config: map[string]string{
+				"name":            "Unlock count",
+				"icon":            "mdi:lock-open-variant-outline",
+				"state_class":     "total_increasing",
+				"entity_category": "diagnostic",
+			},
+		},
+		"last_unlocked_at": {
+			Component: "sensor",
+			Getter:    w.LastUnlockedAt,
+			Config: map[string]string{
+				"name":            "Last unlocked at",
+				"device_class":    "timestamp",
+				"entity_category": "diagnostic",
+			},
+		},
+		"current_limit_source": {
+			Component: "sensor",
+			Getter:    w.CurrentLimitSource,
+			Config: map[string]string{
+				"name":            "Current limit source",
+				"icon":            "mdi:speedometer-slow",
+				"entity_category": "diagnostic",
+			},
+		},
+		"data_mode": {
+			Component: "sensor",
+			Getter:    w.DataMode,
+			Config: map[string]string{
+				"name":            "Data mode",
+				"icon":            "mdi:database-sync",
+				"entity_category": "diagnostic",
+			},
+		},
 		"halo_brightness": {
 			Component: "number",
 			Setter:    func(val string) { w.SetHaloBrightness(strToInt(val)) },
@@ -184,6 +676,14 @@ func getEntities(w *wallbox.Wallbox) map[string]Entity {
 				"entity_category":     "config",
 			},
 		},
+		"last_session_end_reason": {
+			Component: "sensor",
+			Getter:    w.LastSessionEndReason,
+			Config: map[string]string{
+				"name": "Last session end reason",
+				"icon": "mdi:ev-plug-type2",
+			},
+		},
 		"lock": {
 			Component: "lock",
 			Setter:    func(val string) { w.SetLocked(strToInt(val)) },
@@ -197,6 +697,24 @@ func getEntities(w *wallbox.Wallbox) map[string]Entity {
 				"command_topic":  "~/set",
 			},
 		},
+		"ecosmart": {
+			Component: "select",
+			Getter:    w.EcosmartModeName,
+			Setter: func(val string) {
+				if err := w.SetEcosmartMode(val); err != nil {
+					log.Printf("Failed to set EcoSmart mode %q: %v", val, err)
+				}
+			},
+			// Only offered where set_ecosmart_mode fits the database; the
+			// mode itself is only known from telemetry.
+			Condition: w.EcosmartWritable,
+			Available: func() bool { return w.EcosmartModeName() != "unknown" },
+			Options:   []string{wallbox.EcosmartOff, wallbox.EcosmartEco, wallbox.EcosmartFullSolar},
+			Config: map[string]string{
+				"name": "EcoSmart",
+				"icon": "mdi:solar-power",
+			},
+		},
 		"max_charging_current": {
 			Component: "number",
 			Setter:    func(val string) { w.SetMaxChargingCurrent(strToInt(val)) },
@@ -241,6 +759,22 @@ func getEntities(w *wallbox.Wallbox) map[string]Entity {
 				"icon": "mdi:alpha-a-box-outline",
 			},
 		},
+		"telemetry_available": {
+			Component: "binary_sensor",
+			Getter: func() string {
+				if w.HasTelemetry {
+					return "1"
+				}
+				return "0"
+			},
+			Config: map[string]string{
+				"name":            "Telemetry available",
+				"payload_on":      "1",
+				"payload_off":     "0",
+				"device_class":    "connectivity",
+				"entity_category": "diagnostic",
+			},
+		},
 		"temp_l1": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.TemperatureL1()) },
@@ -395,34 +929,558 @@ func getPowerBoostEntities(w *wallbox.Wallbox, c *WallboxConfig) map[string]Enti
 	}
 }
 
-func getDebugEntities(w *wallbox.Wallbox) map[string]Entity {
+// getPhaseEnergyEntities creates the per-phase lifetime energy sensors. These
+// are only registered when phase_energy_enabled is set, because most firmware
+// reports just the total internal meter energy, and are only discovered once
+// telemetry has delivered a per-phase counter.
+func getPhaseEnergyEntities(w *wallbox.Wallbox) map[string]Entity {
 	return map[string]Entity{
-		"control_pilot": {
+		"energy_l1": {
 			Component: "sensor",
-			Getter:    w.ControlPilotStatus,
+			Getter:    func() string { return fmt.Sprint(w.EnergyL1()) },
+			Condition: w.HasPhaseEnergy,
+			RateLimit: ratelimit.NewDeltaRateLimit(10, 50),
 			Config: map[string]string{
-				"name": "Control pilot",
+				"name":                        "Energy L1",
+				"device_class":                "energy",
+				"unit_of_measurement":         "Wh",
+				"state_class":                 "total_increasing",
+				"suggested_display_precision": "1",
 			},
 		},
-		"firmware_version": {
+		"energy_l2": {
 			Component: "sensor",
-			Getter:    w.FirmwareVersion,
+			Getter:    func() string { return fmt.Sprint(w.EnergyL2()) },
+			Condition: w.HasPhaseEnergy,
+			RateLimit: ratelimit.NewDeltaRateLimit(10, 50),
 			Config: map[string]string{
-				"name":            "Wallbox firmware version",
-				"icon":            "mdi:chip",
-				"entity_category": "diagnostic",
+				"name":                        "Energy L2",
+				"device_class":                "energy",
+				"unit_of_measurement":         "Wh",
+				"state_class":                 "total_increasing",
+				"suggested_display_precision": "1",
 			},
 		},
-		"bridge_version": {
+		"energy_l3": {
 			Component: "sensor",
-			Getter:    bridgeVersion,
+			Getter:    func() string { return fmt.Sprint(w.EnergyL3()) },
+			Condition: w.HasPhaseEnergy,
+			RateLimit: ratelimit.NewDeltaRateLimit(10, 50),
 			Config: map[string]string{
-				"name":            "MQTT bridge version",
-				"icon":            "mdi:alpha-b-box-outline",
-				"entity_category": "diagnostic",
+				"name":                        "Energy L3",
+				"device_class":                "energy",
+				"unit_of_measurement":         "Wh",
+				"state_class":                 "total_increasing",
+				"suggested_display_precision": "1",
 			},
 		},
-		"m2w_status": {
+	}
+}
+
+// getGSMEntities exposes cellular link details. They are only discovered once
+// telemetry reports a GSM connection, so Wi-Fi/Ethernet installs never see them.
+func getGSMEntities(w *wallbox.Wallbox) map[string]Entity {
+	return map[string]Entity{
+		"gsm_connection_state": {
+			Component: "sensor",
+			Getter:    w.ConnectivityStatus,
+			Condition: w.IsGSM,
+			Config: map[string]string{
+				"name":            "GSM connection state",
+				"icon":            "mdi:signal-cellular-3",
+				"entity_category": "diagnostic",
+			},
+		},
+		"gsm_signal_quality": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.GSMSignalQuality()) },
+			Condition: func() bool { return w.IsGSM() && w.GSMSignalQuality() != 0 },
+			Config: map[string]string{
+				"name":                        "GSM signal quality",
+				"icon":                        "mdi:signal-cellular-outline",
+				"state_class":                 "measurement",
+				"suggested_display_precision": "0",
+				"entity_category":             "diagnostic",
+			},
+		},
+		"gsm_reconnect_trigger": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.GSMRecoTrigger) },
+			Condition: w.IsGSM,
+			Config: map[string]string{
+				"name":            "GSM reconnect trigger",
+				"icon":            "mdi:restart",
+				"entity_category": "diagnostic",
+			},
+		},
+	}
+}
+
+// getAutoLockEntities controls the charger's auto-lock on models that have
+// it; the entities are only discovered once the settings could be read.
+func getAutoLockEntities(w *wallbox.Wallbox) map[string]Entity {
+	return map[string]Entity{
+		"auto_lock": {
+			Component: "switch",
+			Setter: func(val string) {
+				if err := w.SetAutoLockEnabled(val == "1"); err != nil {
+					log.Printf("Failed to set auto-lock: %v", err)
+				}
+			},
+			Getter: func() string {
+				if w.AutoLockEnabled() {
+					return "1"
+				}
+				return "0"
+			},
+			Condition: func() bool { return w.AutoLockSupported() && w.AutoLockEnabledWritable() },
+			Config: map[string]string{
+				"name":            "Auto-lock",
+				"payload_on":      "1",
+				"payload_off":     "0",
+				"icon":            "mdi:lock-clock",
+				"entity_category": "config",
+			},
+		},
+		"auto_lock_time": {
+			Component: "number",
+			Setter: func(val string) {
+				if err := w.SetAutoLockTime(strToInt(val)); err != nil {
+					log.Printf("Failed to set auto-lock time: %v", err)
+				}
+			},
+			Getter:    func() string { return fmt.Sprint(w.AutoLockTime()) },
+			Condition: func() bool { return w.AutoLockSupported() && w.AutoLockTimeWritable() },
+			Config: map[string]string{
+				"name":                "Auto-lock time",
+				"min":                 fmt.Sprint(wallbox.MinAutoLockTime),
+				"max":                 fmt.Sprint(wallbox.MaxAutoLockTime),
+				"step":                "60",
+				"unit_of_measurement": "s",
+				"device_class":        "duration",
+				"icon":                "mdi:timer-lock-outline",
+				"entity_category":     "config",
+			},
+		},
+	}
+}
+
+// getPhaseCurrentLimitEntities adds a current limit per phase on firmware
+// that supports it; elsewhere the entities are never discovered.
+func getPhaseCurrentLimitEntities(w *wallbox.Wallbox) map[string]Entity {
+	entities := make(map[string]Entity)
+	for phase := 1; phase <= 3; phase++ {
+		phase := phase
+		entities[fmt.Sprintf("max_charging_current_l%d", phase)] = Entity{
+			Component: "number",
+			Setter: func(val string) {
+				if err := w.SetMaxChargingCurrentPhase(phase, strToInt(val)); err != nil {
+					log.Printf("Failed to set L%d current limit: %v", phase, err)
+				}
+			},
+			Getter:    func() string { return fmt.Sprint(w.MaxChargingCurrentPhase(phase)) },
+			Condition: w.PhaseCurrentLimitSupported,
+			Config: map[string]string{
+				"name":                fmt.Sprintf("Max charging current L%d", phase),
+				"min":                 fmt.Sprint(wallbox.MinChargingCurrent),
+				"max":                 fmt.Sprint(w.AvailableCurrent()),
+				"unit_of_measurement": "A",
+				"device_class":        "current",
+			},
+		}
+	}
+	return entities
+}
+
+// getRemoteControlEntities tells users upfront whether lock and charging
+// control can work on their firmware.
+func getRemoteControlEntities(w *wallbox.Wallbox) map[string]Entity {
+	return map[string]Entity{
+		"remote_control_available": {
+			Component: "binary_sensor",
+			Getter: func() string {
+				if w.RemoteControlAvailable() {
+					return "1"
+				}
+				return "0"
+			},
+			Config: map[string]string{
+				"name":            "Remote control available",
+				"icon":            "mdi:remote",
+				"payload_on":      "1",
+				"payload_off":     "0",
+				"entity_category": "diagnostic",
+			},
+		},
+	}
+}
+
+// getNetworkEntities exposes the charger's network address and link quality,
+// e.g. to tell a flaky Wi-Fi link from an OCPP backend problem.
+func getNetworkEntities(w *wallbox.Wallbox) map[string]Entity {
+	telemetry := func() bool { return w.HasTelemetry }
+	return map[string]Entity{
+		"connection_type": {
+			Component: "sensor",
+			Getter:    w.ConnectionType,
+			Available: telemetry,
+			Config: map[string]string{
+				"name":            "Connection Type",
+				"icon":            "mdi:lan-connect",
+				"entity_category": "diagnostic",
+			},
+		},
+		"connectivity_status": {
+			Component: "sensor",
+			Getter:    w.ConnectivityStatus,
+			Available: telemetry,
+			Config: map[string]string{
+				"name":            "Connectivity Status",
+				"icon":            "mdi:cloud-check-outline",
+				"entity_category": "diagnostic",
+			},
+		},
+		"wifi_signal_strength": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.WifiSignalStrength()) },
+			// Ethernet and GSM installs report no Wi-Fi signal.
+			Available: func() bool { return w.WifiSignalStrength() != 0 },
+			RateLimit: ratelimit.NewDeltaRateLimit(10, 3),
+			Config: map[string]string{
+				"name":                        "Wi-Fi Signal Strength",
+				"icon":                        "mdi:wifi",
+				"device_class":                "signal_strength",
+				"unit_of_measurement":         "dBm",
+				"state_class":                 "measurement",
+				"suggested_display_precision": "0",
+				"entity_category":             "diagnostic",
+			},
+		},
+		"ip_address": {
+			Component: "sensor",
+			Getter:    func() string { return w.NetworkInfo().IP },
+			Config: map[string]string{
+				"name":            "IP address",
+				"icon":            "mdi:ip-network",
+				"entity_category": "diagnostic",
+			},
+		},
+		"network_interface": {
+			Component: "sensor",
+			Getter:    func() string { return w.NetworkInfo().Interface },
+			Config: map[string]string{
+				"name":            "Network interface",
+				"icon":            "mdi:ethernet",
+				"entity_category": "diagnostic",
+			},
+		},
+		"wifi_ssid": {
+			Component: "sensor",
+			Getter:    func() string { return w.NetworkInfo().SSID },
+			Config: map[string]string{
+				"name":            "Wi-Fi SSID",
+				"icon":            "mdi:wifi",
+				"entity_category": "diagnostic",
+			},
+		},
+	}
+}
+
+// getSystemResourceEntities exposes free memory and storage so slow leaks in
+// the charger's services, which end in OCPP crashes, can be trended.
+func getSystemResourceEntities(w *wallbox.Wallbox) map[string]Entity {
+	return map[string]Entity{
+		"available_memory": systemResourceEntity(w, "Available memory", "mdi:memory",
+			func(r wallbox.SystemResources) float64 { return r.AvailableMemory }),
+		"cma_free_memory": systemResourceEntity(w, "CMA free memory", "mdi:memory",
+			func(r wallbox.SystemResources) float64 { return r.CMAFreeMemory }),
+		"available_storage": systemResourceEntity(w, "Available storage", "mdi:harddisk",
+			func(r wallbox.SystemResources) float64 { return r.AvailableStorageRoot }),
+	}
+}
+
+func systemResourceEntity(w *wallbox.Wallbox, name, icon string, value func(wallbox.SystemResources) float64) Entity {
+	return Entity{
+		Component: "sensor",
+		Getter:    func() string { return fmt.Sprint(value(w.SystemResources())) },
+		Available: func() bool { return value(w.SystemResources()) > 0 },
+		RateLimit: ratelimit.NewDeltaRateLimit(60, 1024),
+		Config: map[string]string{
+			"name":                        name,
+			"icon":                        icon,
+			"device_class":                "data_size",
+			"unit_of_measurement":         "kB",
+			"state_class":                 "measurement",
+			"suggested_display_precision": "0",
+			"entity_category":             "diagnostic",
+		},
+	}
+}
+
+// getBackendHealthEntities exposes Redis/MySQL error counters so flaky
+// charger services show up in Home Assistant.
+func getBackendHealthEntities(w *wallbox.Wallbox) map[string]Entity {
+	counter := func(name, icon string, get func() int) Entity {
+		return Entity{
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(get()) },
+			Config: map[string]string{
+				"name":            name,
+				"icon":            icon,
+				"state_class":     "total_increasing",
+				"entity_category": "diagnostic",
+			},
+		}
+	}
+
+	return map[string]Entity{
+		"redis_errors":        counter("Redis errors", "mdi:database-alert", w.RedisErrors),
+		"mysql_errors":        counter("MySQL errors", "mdi:database-alert-outline", w.MySQLErrors),
+		"skipped_poll_cycles": counter("Skipped poll cycles", "mdi:debug-step-over", w.SkippedCycles),
+		"last_backend_error": {
+			Component: "sensor",
+			Getter:    w.LastBackendError,
+			Config: map[string]string{
+				"name":            "Last backend error",
+				"icon":            "mdi:alert-circle-outline",
+				"entity_category": "diagnostic",
+			},
+		},
+		"last_backend_error_at": {
+			Component: "sensor",
+			Getter:    w.LastBackendErrorAt,
+			Config: map[string]string{
+				"name":            "Last backend error at",
+				"device_class":    "timestamp",
+				"entity_category": "diagnostic",
+			},
+		},
+	}
+}
+
+// getEfficiencyEntities exposes the session charging efficiency. It is only
+// discovered on chargers reporting both grid-side and delivered energy, and is
+// unavailable until the session has delivered enough energy.
+func getEfficiencyEntities(w *wallbox.Wallbox) map[string]Entity {
+	return map[string]Entity{
+		"charging_efficiency": {
+			Component: "sensor",
+			Getter: func() string {
+				percent, _ := w.Efficiency()
+				return fmt.Sprint(percent)
+			},
+			Condition: w.HasEfficiencySources,
+			Available: func() bool {
+				_, ok := w.Efficiency()
+				return ok
+			},
+			Config: map[string]string{
+				"name":                        "Charging efficiency",
+				"icon":                        "mdi:percent-circle-outline",
+				"unit_of_measurement":         "%",
+				"state_class":                 "measurement",
+				"suggested_display_precision": "1",
+			},
+		},
+	}
+}
+
+// getEventStatsEntities exposes how many Redis pub/sub events were received
+// and how many failed to parse per channel, to catch firmware format changes.
+func getEventStatsEntities(w *wallbox.Wallbox) map[string]Entity {
+	entities := map[string]Entity{
+		"events_processed": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.EventsProcessed()) },
+			RateLimit: ratelimit.NewDeltaRateLimit(60, 1000),
+			Config: map[string]string{
+				"name":            "Events processed",
+				"icon":            "mdi:counter",
+				"state_class":     "total_increasing",
+				"entity_category": "diagnostic",
+			},
+		},
+		"redis_resubscribe_total": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.RedisResubscribes()) },
+			Config: map[string]string{
+				"name":            "Redis resubscribes",
+				"icon":            "mdi:lan-disconnect",
+				"state_class":     "total_increasing",
+				"entity_category": "diagnostic",
+			},
+		},
+		"last_event_parse_error": {
+			Component: "sensor",
+			Getter:    w.LastEventParseError,
+			Config: map[string]string{
+				"name":            "Last event parse error",
+				"icon":            "mdi:alert-circle-outline",
+				"entity_category": "diagnostic",
+			},
+		},
+	}
+
+	for _, name := range wallbox.EventChannelNames() {
+		name := name
+		entities["event_parse_failures_"+name] = Entity{
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.EventParseFailures(name)) },
+			Config: map[string]string{
+				"name":            "Event parse failures (" + strings.ReplaceAll(name, "_", " ") + ")",
+				"icon":            "mdi:alert-circle-outline",
+				"state_class":     "total_increasing",
+				"entity_category": "diagnostic",
+			},
+		}
+	}
+
+	return entities
+}
+
+// getTemperatureEntities exposes a single headline charger temperature, the
+// hottest phase by default or the average with temperature_mode = avg.
+func getTemperatureEntities(w *wallbox.Wallbox, c *WallboxConfig) map[string]Entity {
+	temperature := w.Temperature
+	if c.Settings.TemperatureMode == "avg" {
+		temperature = w.AverageTemperature
+	}
+
+	return map[string]Entity{
+		"temperature": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(math.Round(temperature()*10) / 10) },
+			Config: map[string]string{
+				"name":                        "Temperature",
+				"unit_of_measurement":         "°C",
+				"device_class":                "temperature",
+				"state_class":                 "measurement",
+				"suggested_display_precision": "1",
+			},
+		},
+	}
+}
+
+// getOCPPHeartbeatEntities exposes the last Heartbeat acknowledged by the
+// OCPP central system, as seen by the journal watcher.
+func getOCPPHeartbeatEntities(w *wallbox.Wallbox, c *WallboxConfig) map[string]Entity {
+	return map[string]Entity{
+		"ocpp_last_heartbeat": {
+			Component: "sensor",
+			Getter:    w.OCPPLastHeartbeat,
+			Config: map[string]string{
+				"name":            "OCPP last heartbeat",
+				"device_class":    "timestamp",
+				"entity_category": "diagnostic",
+			},
+		},
+		"backend_connected": {
+			Component: "binary_sensor",
+			Getter: func() string {
+				if w.OCPPBackendConnected(time.Duration(c.Settings.OCPPHeartbeatTimeout) * time.Second) {
+					return "1"
+				}
+				return "0"
+			},
+			Config: map[string]string{
+				"name":            "OCPP backend connected",
+				"payload_on":      "1",
+				"payload_off":     "0",
+				"device_class":    "connectivity",
+				"entity_category": "diagnostic",
+			},
+		},
+	}
+}
+
+// getTimeSyncEntities exposes the charger clock offset derived from event
+// timestamps, plus a problem flag once it exceeds time_sync_threshold_seconds.
+func getTimeSyncEntities(w *wallbox.Wallbox, c *WallboxConfig) map[string]Entity {
+	return map[string]Entity{
+		"time_sync_offset": {
+			Component: "sensor",
+			Getter: func() string {
+				offset, _ := w.TimeSyncOffset()
+				return fmt.Sprint(math.Round(offset))
+			},
+			Config: map[string]string{
+				"name":                "Time sync offset",
+				"icon":                "mdi:clock-alert-outline",
+				"unit_of_measurement": "s",
+				"state_class":         "measurement",
+				"entity_category":     "diagnostic",
+			},
+		},
+		"time_sync_problem": {
+			Component: "binary_sensor",
+			Getter: func() string {
+				offset, known := w.TimeSyncOffset()
+				if known && math.Abs(offset) > float64(c.Settings.TimeSyncThreshold) {
+					return "1"
+				}
+				return "0"
+			},
+			Config: map[string]string{
+				"name":            "Time sync problem",
+				"payload_on":      "1",
+				"payload_off":     "0",
+				"device_class":    "problem",
+				"entity_category": "diagnostic",
+			},
+		},
+	}
+}
+
+func getDebugEntities(w *wallbox.Wallbox) map[string]Entity {
+	return map[string]Entity{
+		"charging_power_raw": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.RawChargingPower()) },
+			RateLimit: ratelimit.NewDeltaRateLimit(10, 100),
+			Config: map[string]string{
+				"name":                        "Charging power (raw)",
+				"device_class":                "power",
+				"unit_of_measurement":         "W",
+				"state_class":                 "measurement",
+				"suggested_display_precision": "1",
+			},
+		},
+		"control_pilot": {
+			Component: "sensor",
+			Getter:    w.ControlPilotStatus,
+			Config: map[string]string{
+				"name": "Control pilot",
+			},
+		},
+		"pms_dominant_feature": {
+			Component: "sensor",
+			Getter:    func() string { return fmt.Sprint(w.PMSDominantFeature()) },
+			Available: func() bool { return w.HasTelemetry },
+			Config: map[string]string{
+				"name":            "Limiting feature code",
+				"icon":            "mdi:tune-variant",
+				"entity_category": "diagnostic",
+			},
+		},
+		"firmware_version": {
+			Component: "sensor",
+			Getter:    w.FirmwareVersion,
+			Config: map[string]string{
+				"name":            "Wallbox firmware version",
+				"icon":            "mdi:chip",
+				"entity_category": "diagnostic",
+			},
+		},
+		"bridge_version": {
+			Component: "sensor",
+			Getter:    bridgeVersion,
+			Config: map[string]string{
+				"name":            "MQTT bridge version",
+				"icon":            "mdi:alpha-b-box-outline",
+				"entity_category": "diagnostic",
+			},
+		},
+		"m2w_status": {
 			Component: "sensor",
 			Getter:    w.StateMachineState,
 			Config: map[string]string{
@@ -454,24 +1512,14 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.ICPMaxCurrent) },
 			Config: map[string]string{
-				"name":                        "ICP Max Current",
-				"device_class":                "current",
-				"unit_of_measurement":         "A",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "ICP Max Current",
 			},
 		},
 		"user_current_proposal": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.UserCurrentProposal) },
 			Config: map[string]string{
-				"name":                        "User Current Proposal",
-				"device_class":                "current",
-				"unit_of_measurement":         "A",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "User Current Proposal",
 			},
 		},
 
@@ -481,12 +1529,7 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.InternalMeterVoltageL1) },
 			RateLimit: ratelimit.NewDeltaRateLimit(10, 2),
 			Config: map[string]string{
-				"name":                        "Internal Meter Voltage L1",
-				"device_class":                "voltage",
-				"unit_of_measurement":         "V",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "Internal Meter Voltage L1",
 			},
 		},
 		"internal_meter_voltage_l2": {
@@ -494,12 +1537,7 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.InternalMeterVoltageL2) },
 			RateLimit: ratelimit.NewDeltaRateLimit(10, 2),
 			Config: map[string]string{
-				"name":                        "Internal Meter Voltage L2",
-				"device_class":                "voltage",
-				"unit_of_measurement":         "V",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "Internal Meter Voltage L2",
 			},
 		},
 		"internal_meter_voltage_l3": {
@@ -507,36 +1545,21 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.InternalMeterVoltageL3) },
 			RateLimit: ratelimit.NewDeltaRateLimit(10, 2),
 			Config: map[string]string{
-				"name":                        "Internal Meter Voltage L3",
-				"device_class":                "voltage",
-				"unit_of_measurement":         "V",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "Internal Meter Voltage L3",
 			},
 		},
 		"control_pilot_high_voltage": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.ControlPilotHighVolts / 10.0) }, // Convert tenths to volts
 			Config: map[string]string{
-				"name":                        "Control Pilot High Voltage",
-				"device_class":                "voltage",
-				"unit_of_measurement":         "V",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "Control Pilot High Voltage",
 			},
 		},
 		"control_pilot_low_voltage": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.ControlPilotLowVolts / 10.0) }, // Convert tenths to volts
 			Config: map[string]string{
-				"name":                        "Control Pilot Low Voltage",
-				"device_class":                "voltage",
-				"unit_of_measurement":         "V",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "Control Pilot Low Voltage",
 			},
 		},
 
@@ -545,40 +1568,9 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.InternalMeterEnergy) },
 			Config: map[string]string{
-				"name":                        "Internal Meter Energy",
-				"device_class":                "energy",
-				"unit_of_measurement":         "Wh",
-				"state_class":                 "total_increasing",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
-			},
-		},
-		"ecosmart_green_energy": {
-			Component: "sensor",
-			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.EcosmartGreenEnergy) },
-			Config: map[string]string{
-				"name":                        "EcoSmart Green Energy",
-				"device_class":                "energy",
-				"unit_of_measurement":         "Wh",
-				"state_class":                 "total_increasing",
-				"suggested_display_precision": "1",
-				"icon":                        "mdi:leaf",
-				"entity_category":             "diagnostic",
+				"name": "Internal Meter Energy",
 			},
 		},
-		"ecosmart_energy_total": {
-			Component: "sensor",
-			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.EcosmartEnergyTotal) },
-			Config: map[string]string{
-				"name":                        "EcoSmart Total Energy",
-				"device_class":                "energy",
-				"unit_of_measurement":         "Wh",
-				"state_class":                 "total_increasing",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
-			},
-		},
-
 		// System Status
 		"ecosmart_mode": {
 			Component: "sensor",
@@ -602,13 +1594,8 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.EcosmartCurrentProposal) },
 			Config: map[string]string{
-				"name":                        "EcoSmart Current Proposal",
-				"device_class":                "current",
-				"unit_of_measurement":         "A",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"icon":                        "mdi:leaf",
-				"entity_category":             "diagnostic",
+				"name": "EcoSmart Current Proposal",
+				"icon": "mdi:leaf",
 			},
 		},
 
@@ -617,96 +1604,56 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.InternalMeterFrequency) },
 			Config: map[string]string{
-				"name":                        "Internal Meter Frequency",
-				"device_class":                "frequency",
-				"unit_of_measurement":         "Hz",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "Internal Meter Frequency",
 			},
 		},
 		"dca_voltage_l1": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.DCA_VoltageL1) },
 			Config: map[string]string{
-				"name":                        "DCA Voltage L1",
-				"device_class":                "voltage",
-				"unit_of_measurement":         "V",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "DCA Voltage L1",
 			},
 		},
 		"dca_voltage_l2": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.DCA_VoltageL2) },
 			Config: map[string]string{
-				"name":                        "DCA Voltage L2",
-				"device_class":                "voltage",
-				"unit_of_measurement":         "V",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "DCA Voltage L2",
 			},
 		},
 		"dca_voltage_l3": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.DCA_VoltageL3) },
 			Config: map[string]string{
-				"name":                        "DCA Voltage L3",
-				"device_class":                "voltage",
-				"unit_of_measurement":         "V",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "DCA Voltage L3",
 			},
 		},
 		"dca_current_l1": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.DCA_CurrentL1) },
 			Config: map[string]string{
-				"name":                        "DCA Current L1",
-				"device_class":                "current",
-				"unit_of_measurement":         "A",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "DCA Current L1",
 			},
 		},
 		"dca_current_l2": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.DCA_CurrentL2) },
 			Config: map[string]string{
-				"name":                        "DCA Current L2",
-				"device_class":                "current",
-				"unit_of_measurement":         "A",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "DCA Current L2",
 			},
 		},
 		"dca_current_l3": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.DCA_CurrentL3) },
 			Config: map[string]string{
-				"name":                        "DCA Current L3",
-				"device_class":                "current",
-				"unit_of_measurement":         "A",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "DCA Current L3",
 			},
 		},
 		"dca_meter_frequency": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.DCAMeterFrequency) },
 			Config: map[string]string{
-				"name":                        "DCA Meter Frequency",
-				"device_class":                "frequency",
-				"unit_of_measurement":         "Hz",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "DCA Meter Frequency",
 			},
 		},
 		"external_meter_status": {
@@ -728,30 +1675,16 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 				"name": "OCPP status",
 			},
 		},
-
-		// Schedule and PowerBoost
-		"schedule_status": {
+		"ocpp_status_code": {
 			Component: "sensor",
-			Getter:    w.ScheduleStatus,
+			Getter:    func() string { return fmt.Sprint(w.OCPPStatusCode()) },
 			Config: map[string]string{
-				"name":            "Schedule Status",
-				"icon":            "mdi:calendar-clock",
-				"entity_category": "diagnostic",
-			},
-		},
-		"schedule_current_proposal": {
-			Component: "sensor",
-			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.ScheduleCurrentProposal) },
-			Config: map[string]string{
-				"name":                        "Schedule Current Proposal",
-				"device_class":                "current",
-				"unit_of_measurement":         "A",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"icon":                        "mdi:calendar-clock",
-				"entity_category":             "diagnostic",
+				"name": "OCPP status code",
+				"icon": "mdi:numeric",
 			},
 		},
+
+		// PowerBoost
 		"powerboost_status": {
 			Component: "sensor",
 			Getter:    w.PowerBoostStatus,
@@ -764,12 +1697,7 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.PowerboostProposalCurrent) },
 			Config: map[string]string{
-				"name":                        "PowerBoost Current Proposal",
-				"device_class":                "current",
-				"unit_of_measurement":         "A",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "PowerBoost Current Proposal",
 			},
 		},
 
@@ -788,9 +1716,7 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.ControlPilotDuty) },
 			Config: map[string]string{
-				"name":            "Control Pilot Duty",
-				"state_class":     "measurement",
-				"entity_category": "diagnostic",
+				"name": "Control Pilot Duty",
 			},
 		},
 		"control_pilot_status_raw": {
@@ -805,24 +1731,14 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.MaxAvailableCurrent) },
 			Config: map[string]string{
-				"name":                        "Max Available Current",
-				"device_class":                "current",
-				"unit_of_measurement":         "A",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "Max Available Current",
 			},
 		},
 		"max_charging_current_sensor": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.MaxChargingCurrent) },
 			Config: map[string]string{
-				"name":                        "Max Charging Current (sensor)",
-				"device_class":                "current",
-				"unit_of_measurement":         "A",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "Max Charging Current (sensor)",
 			},
 		},
 		"mid_status": {
@@ -859,12 +1775,7 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.DynamicPowerSharingMaxCurrent) },
 			Config: map[string]string{
-				"name":                        "Dynamic Power Sharing Max Current",
-				"device_class":                "current",
-				"unit_of_measurement":         "A",
-				"state_class":                 "measurement",
-				"suggested_display_precision": "1",
-				"entity_category":             "diagnostic",
+				"name": "Dynamic Power Sharing Max Current",
 			},
 		},
 		"control_mode": {
@@ -875,41 +1786,15 @@ func getTelemetryEventEntities(w *wallbox.Wallbox) map[string]Entity {
 				"entity_category": "diagnostic",
 			},
 		},
-		"connectivity_status": {
-			Component: "sensor",
-			Getter:    w.ConnectivityStatus,
-			Config: map[string]string{
-				"name":            "Connectivity Status",
-				"entity_category": "diagnostic",
-			},
-		},
 		"on_time": {
 			Component: "sensor",
 			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.OnTime) },
 			Config: map[string]string{
-				"name":            "System On Time",
-				"entity_category": "diagnostic",
-			},
-		},
-		"wifi_signal_strength": {
-			Component: "sensor",
-			Getter:    func() string { return fmt.Sprint(w.Data.RedisTelemetry.WifiSignalStrength) },
-			Config: map[string]string{
-				"name":                        "Wi-Fi Signal Strength",
-				"icon":                        "mdi:wifi",
-				"unit_of_measurement":         "dBm",
-				"suggested_display_precision": "0",
-			},
-		},
-		"connection_type": {
-			Component: "sensor",
-			Getter:    w.ConnectionType,
-			Config: map[string]string{
-				"name":            "Connection Type",
-				"entity_category": "diagnostic",
+				"name": "System On Time",
 			},
 		},
 	}
 
+	applyTelemetryMeta(entities)
 	return entities
 }
@@ -0,0 +1,145 @@
+package bridge
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSmoothedEntities are the measured power/current sensors smoothed when
+// [smoothing] does not list entities explicitly. Limits and proposals are
+// setpoints rather than measurements and are left alone.
+var defaultSmoothedEntities = []string{
+	"charging_power",
+	"charging_power_l1",
+	"charging_power_l2",
+	"charging_power_l3",
+	"charging_current_l1",
+	"charging_current_l2",
+	"charging_current_l3",
+}
+
+type timedSample struct {
+	at    time.Time
+	value float64
+}
+
+// movingAverage averages the last samples values and/or the values from the
+// last window. With both set, a sample has to satisfy both limits. Until the
+// window fills up the average is over whatever has been collected so far.
+type movingAverage struct {
+	samples int
+	window  time.Duration
+	values  []timedSample
+}
+
+func newMovingAverage(samples int, window time.Duration) *movingAverage {
+	return &movingAverage{samples: samples, window: window}
+}
+
+// Add records a sample and returns the current average.
+func (m *movingAverage) Add(now time.Time, value float64) float64 {
+	m.values = append(m.values, timedSample{at: now, value: value})
+
+	if m.samples > 0 && len(m.values) > m.samples {
+		m.values = m.values[len(m.values)-m.samples:]
+	}
+	if m.window > 0 {
+		cutoff := now.Add(-m.window)
+		drop := 0
+		// Always keep the newest sample, even if the poll interval is longer
+		// than the window.
+		for drop < len(m.values)-1 && !m.values[drop].at.After(cutoff) {
+			drop++
+		}
+		m.values = m.values[drop:]
+	}
+
+	sum := 0.0
+	for _, s := range m.values {
+		sum += s.value
+	}
+	return sum / float64(len(m.values))
+}
+
+type smoothedEntity struct {
+	raw     func() string
+	average *movingAverage
+	value   string
+}
+
+// entitySmoother replaces the getters of selected entities with a moving
+// average that is fed once per poll cycle by Sample. The wallbox accessors
+// themselves stay instantaneous for internal logic such as the self-heal.
+type entitySmoother struct {
+	entities map[string]*smoothedEntity
+}
+
+// applySmoothing wraps the configured entities' getters in entityConfig.
+// Entries in c.Smoothing.Entities may carry their own sample count as
+// "key:N"; others use the global samples/seconds window.
+func applySmoothing(entityConfig map[string]Entity, c *WallboxConfig) *entitySmoother {
+	s := &entitySmoother{entities: make(map[string]*smoothedEntity)}
+
+	keys := defaultSmoothedEntities
+	if strings.TrimSpace(c.Smoothing.Entities) != "" {
+		keys = strings.Split(c.Smoothing.Entities, ",")
+	}
+
+	for _, entry := range keys {
+		key, samples, window := parseSmoothingEntry(entry, c)
+		if samples <= 0 && window <= 0 {
+			continue
+		}
+		e, ok := entityConfig[key]
+		if !ok {
+			continue
+		}
+
+		smoothed := &smoothedEntity{raw: e.Getter, average: newMovingAverage(samples, window)}
+		e.Getter = func() string {
+			if smoothed.value == "" {
+				return smoothed.raw()
+			}
+			return smoothed.value
+		}
+		entityConfig[key] = e
+		s.entities[key] = smoothed
+	}
+
+	return s
+}
+
+func parseSmoothingEntry(entry string, c *WallboxConfig) (key string, samples int, window time.Duration) {
+	key = strings.TrimSpace(entry)
+	samples = c.Smoothing.Samples
+	window = time.Duration(c.Smoothing.Seconds) * time.Second
+
+	if name, count, found := strings.Cut(key, ":"); found {
+		key = strings.TrimSpace(name)
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil {
+			log.Printf("Ignoring invalid smoothing window %q for %s", count, key)
+			return key, 0, 0
+		}
+		samples, window = n, 0
+	}
+	return key, samples, window
+}
+
+// Sample reads every smoothed entity once and updates its average. Values
+// that are not numeric are passed through unchanged.
+func (s *entitySmoother) Sample(now time.Time) {
+	for _, e := range s.entities {
+		raw := e.raw()
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			e.value = raw
+			continue
+		}
+		e.value = fmt.Sprint(math.Round(e.average.Add(now, value)*100) / 100)
+	}
+}
@@ -0,0 +1,72 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMovingAverage_Samples(t *testing.T) {
+	m := newMovingAverage(3, 0)
+	start := time.Now()
+
+	// Fewer than N samples: average over what has been seen so far.
+	if got := m.Add(start, 100); got != 100 {
+		t.Fatalf("expected first sample to be returned as-is, got %v", got)
+	}
+	if got := m.Add(start.Add(time.Second), 200); got != 150 {
+		t.Fatalf("expected average of 2 samples to be 150, got %v", got)
+	}
+	if got := m.Add(start.Add(2*time.Second), 600); got != 300 {
+		t.Fatalf("expected average of 3 samples to be 300, got %v", got)
+	}
+	// The oldest sample (100) drops out.
+	if got := m.Add(start.Add(3*time.Second), 400); got != 400 {
+		t.Fatalf("expected sliding average of 400, got %v", got)
+	}
+}
+
+func TestMovingAverage_Window(t *testing.T) {
+	m := newMovingAverage(0, 30*time.Second)
+	start := time.Now()
+
+	m.Add(start, 1000)
+	m.Add(start.Add(10*time.Second), 2000)
+	if got := m.Add(start.Add(20*time.Second), 3000); got != 2000 {
+		t.Fatalf("expected all samples inside the window to count, got %v", got)
+	}
+	if got := m.Add(start.Add(35*time.Second), 4000); got != 3000 {
+		t.Fatalf("expected the sample older than 30s to drop out, got %v", got)
+	}
+	if got := m.Add(start.Add(5*time.Minute), 500); got != 500 {
+		t.Fatalf("expected the newest sample to be kept after a long gap, got %v", got)
+	}
+}
+
+func TestEntitySmoother(t *testing.T) {
+	raw := "0"
+	entities := map[string]Entity{
+		"charging_power": {Component: "sensor", Getter: func() string { return raw }},
+		"status":         {Component: "sensor", Getter: func() string { return "Charging" }},
+	}
+
+	var c WallboxConfig
+	c.Smoothing.Entities = "charging_power:2, status:2, missing:2"
+	s := applySmoothing(entities, &c)
+
+	if got := entities["charging_power"].Getter(); got != "0" {
+		t.Fatalf("expected raw value before the first sample, got %q", got)
+	}
+
+	for _, v := range []string{"1000", "2000"} {
+		raw = v
+		s.Sample(time.Now())
+	}
+	if got := entities["charging_power"].Getter(); got != "1500" {
+		t.Fatalf("expected smoothed value 1500, got %q", got)
+	}
+
+	s.Sample(time.Now())
+	if got := entities["status"].Getter(); got != "Charging" {
+		t.Fatalf("expected non-numeric values to pass through, got %q", got)
+	}
+}
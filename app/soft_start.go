@@ -0,0 +1,186 @@
+package bridge
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// rampStep is one max_charging_current change of a soft-start ramp, to be
+// applied after the given delay from the previous step.
+type rampStep struct {
+	delay   time.Duration
+	current int
+}
+
+// rampSteps spreads the increase from min to target amps evenly over
+// duration, one amp at a time. The first step (min) is applied immediately.
+func rampSteps(min, target int, duration time.Duration) []rampStep {
+	if target <= min || duration <= 0 {
+		return []rampStep{{current: target}}
+	}
+
+	interval := duration / time.Duration(target-min)
+	steps := []rampStep{{current: min}}
+	for current := min + 1; current <= target; current++ {
+		steps = append(steps, rampStep{delay: interval, current: current})
+	}
+	return steps
+}
+
+// runRamp applies steps through setCurrent, sleeping between them. It stops
+// early and returns false as soon as abort reports true.
+func runRamp(steps []rampStep, setCurrent func(int), abort func() bool, sleep func(time.Duration)) bool {
+	for _, step := range steps {
+		if step.delay > 0 {
+			sleep(step.delay)
+		}
+		if abort() {
+			return false
+		}
+		setCurrent(step.current)
+	}
+	return true
+}
+
+// softStartCharger is what a soft-start ramp acts on; *wallbox.Wallbox in
+// production.
+type softStartCharger interface {
+	ChargingEnable() int
+	CableConnected() int
+	MaxChargingCurrent() int
+	SetMaxChargingCurrent(current int)
+}
+
+// softStart ramps max_charging_current up after charging is enabled. Any new
+// enable/disable or manual current change cancels a running ramp.
+type softStart struct {
+	min      int
+	duration time.Duration
+	// grace is how long after enabling the ramp ignores the charger state,
+	// which only shows the enable once the next RefreshData has run.
+	grace time.Duration
+	sleep func(time.Duration)
+
+	mu         sync.Mutex
+	generation int
+	running    bool
+	// setpoint is the maximum current the user asked for. lastSet is the
+	// last current a ramp wrote, to tell its leftovers from changes made
+	// elsewhere (e.g. in the Wallbox app).
+	setpoint int
+	lastSet  int
+
+	wg sync.WaitGroup
+}
+
+func newSoftStart(min int, duration, grace time.Duration) *softStart {
+	return &softStart{min: min, duration: duration, grace: grace, sleep: time.Sleep}
+}
+
+// SetCurrent records a manual max_charging_current change, which takes over
+// from any running ramp.
+func (s *softStart) SetCurrent(current int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generation++
+	s.running = false
+	s.setpoint = current
+	s.lastSet = 0
+}
+
+// target is the current a new ramp ends at: the user's setpoint, unless the
+// charger's maximum was changed elsewhere since a ramp last wrote it.
+func (s *softStart) target(c softStartCharger) int {
+	current := c.MaxChargingCurrent()
+	if s.setpoint > 0 && (s.running || current == s.lastSet) {
+		return s.setpoint
+	}
+	return current
+}
+
+// Enable switches charging on through enable. Only a real off->on change
+// ramps: the current drops to min first and then rises in the background.
+func (s *softStart) Enable(c softStartCharger, enable func()) {
+	if c.ChargingEnable() == 1 {
+		enable()
+		return
+	}
+
+	s.mu.Lock()
+	s.generation++
+	generation := s.generation
+	target := s.target(c)
+	s.setpoint = target
+	s.running = true
+	s.mu.Unlock()
+
+	cancelled := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.generation != generation
+	}
+	setCurrent := func(current int) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.generation != generation {
+			return
+		}
+		s.lastSet = current
+		c.SetMaxChargingCurrent(current)
+	}
+
+	steps := rampSteps(s.min, target, s.duration)
+	log.Printf("Soft-start: ramping max charging current from %dA to %dA over %s", steps[0].current, target, s.duration)
+	setCurrent(steps[0].current)
+	enable()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		var elapsed time.Duration
+		completed := runRamp(steps[1:], setCurrent, func() bool {
+			if cancelled() {
+				return true
+			}
+			// Until the charger state has caught up with the enable it
+			// still reads as off; don't mistake that for a disconnect.
+			return elapsed >= s.grace && (c.CableConnected() != 1 || c.ChargingEnable() != 1)
+		}, func(d time.Duration) {
+			s.sleep(d)
+			elapsed += d
+		})
+
+		if !completed && !cancelled() {
+			// The car went away or charging was disabled mid-ramp; leave the
+			// configured target in place for the next session.
+			log.Printf("Soft-start: aborted, restoring max charging current to %dA", target)
+			setCurrent(target)
+		}
+		s.mu.Lock()
+		if s.generation == generation {
+			s.running = false
+		}
+		s.mu.Unlock()
+	}()
+}
+
+// Disable stops a running ramp, restoring the user's setpoint, and switches
+// charging off through disable.
+func (s *softStart) Disable(c softStartCharger, disable func()) {
+	s.mu.Lock()
+	wasRunning := s.running
+	s.generation++
+	s.running = false
+	target := s.setpoint
+	if wasRunning {
+		s.lastSet = target
+	}
+	s.mu.Unlock()
+
+	disable()
+	if wasRunning {
+		log.Printf("Soft-start: charging disabled, restoring max charging current to %dA", target)
+		c.SetMaxChargingCurrent(target)
+	}
+}
@@ -0,0 +1,146 @@
+package bridge
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRampSteps(t *testing.T) {
+	steps := rampSteps(6, 10, 40*time.Second)
+	want := []rampStep{
+		{0, 6},
+		{10 * time.Second, 7},
+		{10 * time.Second, 8},
+		{10 * time.Second, 9},
+		{10 * time.Second, 10},
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Fatalf("expected %v, got %v", want, steps)
+	}
+
+	// Nothing to ramp when the target is at or below the minimum.
+	if steps := rampSteps(6, 6, time.Minute); !reflect.DeepEqual(steps, []rampStep{{0, 6}}) {
+		t.Fatalf("expected a single step, got %v", steps)
+	}
+}
+
+func TestRunRamp_Completes(t *testing.T) {
+	var set []int
+	var slept time.Duration
+
+	ok := runRamp(rampSteps(6, 16, 100*time.Second),
+		func(current int) { set = append(set, current) },
+		func() bool { return false },
+		func(d time.Duration) { slept += d })
+
+	if !ok {
+		t.Fatalf("expected ramp to complete")
+	}
+	if want := []int{6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}; !reflect.DeepEqual(set, want) {
+		t.Fatalf("expected currents %v, got %v", want, set)
+	}
+	if slept != 100*time.Second {
+		t.Fatalf("expected the ramp to take 100s, slept %s", slept)
+	}
+}
+
+func TestRunRamp_AbortsOnDisconnect(t *testing.T) {
+	var set []int
+	connected := true
+
+	ok := runRamp(rampSteps(6, 16, 100*time.Second),
+		func(current int) {
+			set = append(set, current)
+			if current == 8 {
+				connected = false
+			}
+		},
+		func() bool { return !connected },
+		func(time.Duration) {})
+
+	if ok {
+		t.Fatalf("expected ramp to abort")
+	}
+	if want := []int{6, 7, 8}; !reflect.DeepEqual(set, want) {
+		t.Fatalf("expected ramp to stop after 8A, got %v", set)
+	}
+}
+
+// fakeSoftStartCharger reads charging_enable the way the bridge does: the
+// value only changes once a refresh has picked the enable up.
+type fakeSoftStartCharger struct {
+	enabled   int
+	cable     int
+	current   int
+	currents  []int
+	requested bool
+}
+
+func (f *fakeSoftStartCharger) ChargingEnable() int     { return f.enabled }
+func (f *fakeSoftStartCharger) CableConnected() int     { return f.cable }
+func (f *fakeSoftStartCharger) MaxChargingCurrent() int { return f.current }
+func (f *fakeSoftStartCharger) SetMaxChargingCurrent(current int) {
+	f.current = current
+	f.currents = append(f.currents, current)
+}
+
+func TestSoftStart_RampsOnEnable(t *testing.T) {
+	charger := &fakeSoftStartCharger{cable: 1, current: 10}
+	ramp := newSoftStart(6, 40*time.Second, 20*time.Second)
+	var elapsed time.Duration
+	ramp.sleep = func(d time.Duration) {
+		elapsed += d
+		// The next poll, 15s in, sees the enable.
+		if charger.requested && elapsed >= 15*time.Second {
+			charger.enabled = 1
+		}
+	}
+
+	ramp.Enable(charger, func() { charger.requested = true })
+	ramp.wg.Wait()
+
+	if want := []int{6, 7, 8, 9, 10}; !reflect.DeepEqual(charger.currents, want) {
+		t.Fatalf("expected currents %v, got %v", want, charger.currents)
+	}
+
+	// Enabling again while charging is already on must not ramp.
+	charger.currents = nil
+	ramp.Enable(charger, func() {})
+	ramp.wg.Wait()
+	if len(charger.currents) != 0 {
+		t.Fatalf("expected no ramp while already enabled, got %v", charger.currents)
+	}
+}
+
+func TestSoftStart_DisableRestoresSetpoint(t *testing.T) {
+	charger := &fakeSoftStartCharger{cable: 1, current: 16}
+	ramp := newSoftStart(6, 100*time.Second, 20*time.Second)
+	disabled := make(chan struct{})
+	steps := 0
+	ramp.sleep = func(time.Duration) {
+		if steps++; steps == 3 {
+			// Disabled mid-ramp, at 8A.
+			ramp.Disable(charger, func() { charger.enabled = 0 })
+			close(disabled)
+		}
+	}
+
+	ramp.Enable(charger, func() { charger.enabled = 1 })
+	ramp.wg.Wait()
+	<-disabled
+	if charger.current != 16 {
+		t.Fatalf("expected the 16A setpoint to be restored, got %dA (%v)", charger.current, charger.currents)
+	}
+
+	// A maximum changed elsewhere in the meantime becomes the new target.
+	charger.currents = nil
+	charger.enabled = 0
+	charger.current = 20
+	ramp.sleep = func(time.Duration) {}
+	ramp.Enable(charger, func() { charger.enabled = 1 })
+	ramp.wg.Wait()
+	if last := charger.currents[len(charger.currents)-1]; last != 20 {
+		t.Fatalf("expected the ramp to end at 20A, got %v", charger.currents)
+	}
+}
@@ -0,0 +1,164 @@
+package bridge
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var validSQLSensorName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// sqlSensor is one user-defined sensor from the [sql_sensors] section.
+type sqlSensor struct {
+	Name        string
+	Query       string
+	DeviceClass string
+	Unit        string
+	StateClass  string
+}
+
+// sqlSensorAttributes are the optional "name.<attribute>" keys of
+// [sql_sensors].
+var sqlSensorAttributes = []string{"device_class", "unit", "state_class"}
+
+// parseSQLSensors turns the [sql_sensors] section into sensors. Each
+// "name = SELECT ..." entry defines a sensor; "name.device_class",
+// "name.unit" and "name.state_class" optionally type it. Invalid entries are
+// logged and skipped.
+func parseSQLSensors(entries map[string]string) []sqlSensor {
+	var sensors []sqlSensor
+	for name, query := range entries {
+		if base, attr, ok := strings.Cut(name, "."); ok {
+			if _, ok := entries[base]; !ok || !isSQLSensorAttribute(attr) {
+				log.Printf("Ignoring SQL sensor setting %s", name)
+			}
+			continue
+		}
+		if !validSQLSensorName.MatchString(name) {
+			log.Printf("Ignoring SQL sensor %q: names may only use a-z, 0-9 and '_'", name)
+			continue
+		}
+		if err := checkReadOnlyQuery(query); err != nil {
+			log.Printf("Ignoring SQL sensor %s: %v", name, err)
+			continue
+		}
+		sensors = append(sensors, sqlSensor{
+			Name:        name,
+			Query:       query,
+			DeviceClass: entries[name+".device_class"],
+			Unit:        entries[name+".unit"],
+			StateClass:  entries[name+".state_class"],
+		})
+	}
+	sort.Slice(sensors, func(i, j int) bool { return sensors[i].Name < sensors[j].Name })
+	return sensors
+}
+
+func isSQLSensorAttribute(attr string) bool {
+	for _, a := range sqlSensorAttributes {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReadOnlyQuery rejects anything but a single SELECT statement. The
+// query also runs in a read-only transaction; this catches mistakes early
+// and with a clearer message.
+func checkReadOnlyQuery(query string) error {
+	q := strings.TrimSuffix(strings.TrimSpace(query), ";")
+	if strings.Contains(q, ";") {
+		return fmt.Errorf("only a single statement is allowed")
+	}
+	// Multi-line queries often put a newline or tab after the keyword.
+	words := strings.Fields(q)
+	if len(words) == 0 {
+		return fmt.Errorf("only SELECT queries are allowed")
+	}
+	if first := strings.ToLower(words[0]); first != "select" && first != "with" {
+		return fmt.Errorf("only SELECT queries are allowed")
+	}
+	return nil
+}
+
+// sqlSensorValues caches the result of each SQL sensor between polls.
+// Getters run on other goroutines (REST API, status page).
+type sqlSensorValues struct {
+	mu      sync.RWMutex
+	values  map[string]string
+	lastErr map[string]string
+}
+
+func newSQLSensorValues() *sqlSensorValues {
+	return &sqlSensorValues{values: make(map[string]string), lastErr: make(map[string]string)}
+}
+
+// Refresh runs every sensor's query. A failing sensor keeps no value (and
+// turns unavailable); its error is logged when it first appears or changes.
+func (v *sqlSensorValues) Refresh(sensors []sqlSensor, query func(string) (string, error)) {
+	for _, s := range sensors {
+		value, err := query(s.Query)
+
+		v.mu.Lock()
+		if err != nil {
+			if msg := err.Error(); v.lastErr[s.Name] != msg {
+				log.Printf("SQL sensor %s failed: %v", s.Name, err)
+				v.lastErr[s.Name] = msg
+			}
+			delete(v.values, s.Name)
+		} else {
+			if v.lastErr[s.Name] != "" {
+				log.Printf("SQL sensor %s recovered", s.Name)
+				delete(v.lastErr, s.Name)
+			}
+			v.values[s.Name] = value
+		}
+		v.mu.Unlock()
+	}
+}
+
+// Value returns the last value of the named sensor and whether it has one.
+func (v *sqlSensorValues) Value(name string) (string, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	value, ok := v.values[name]
+	return value, ok
+}
+
+// getSQLSensorEntities creates a sql_<name> sensor per configured query.
+func getSQLSensorEntities(sensors []sqlSensor, values *sqlSensorValues) map[string]Entity {
+	entities := make(map[string]Entity, len(sensors))
+	for _, s := range sensors {
+		name := s.Name
+		config := map[string]string{
+			"name": strings.ReplaceAll(name, "_", " "),
+			"icon": "mdi:database",
+		}
+		if s.DeviceClass != "" {
+			config["device_class"] = s.DeviceClass
+		}
+		if s.Unit != "" {
+			config["unit_of_measurement"] = s.Unit
+		}
+		if s.StateClass != "" {
+			config["state_class"] = s.StateClass
+		}
+		entities["sql_"+name] = Entity{
+			Component: "sensor",
+			Getter: func() string {
+				value, _ := values.Value(name)
+				return value
+			},
+			Available: func() bool {
+				_, ok := values.Value(name)
+				return ok
+			},
+			Config: config,
+		}
+	}
+	return entities
+}
@@ -0,0 +1,82 @@
+package bridge
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSQLSensors(t *testing.T) {
+	sensors := parseSQLSensors(map[string]string{
+		"session_cost":              "SELECT SUM(`cost`) FROM `session`",
+		"session_cost.device_class": "monetary",
+		"session_cost.unit":         "EUR",
+		"session_cost.state_class":  "total",
+		"halo":                      "select `halo_brightness` from `wallbox_config`;",
+		"drop_it":                   "DELETE FROM `session`",
+		"two":                       "SELECT 1; DROP TABLE `session`",
+		"Bad-Name":                  "SELECT 1",
+		"orphan.unit":               "W",
+		"halo.color":                "red",
+	})
+
+	if len(sensors) != 2 {
+		t.Fatalf("expected 2 valid sensors, got %+v", sensors)
+	}
+	if s := sensors[0]; s.Name != "halo" || s.DeviceClass != "" {
+		t.Fatalf("unexpected first sensor %+v", s)
+	}
+	if s := sensors[1]; s.Name != "session_cost" || s.DeviceClass != "monetary" || s.Unit != "EUR" || s.StateClass != "total" {
+		t.Fatalf("unexpected second sensor %+v", s)
+	}
+}
+
+func TestCheckReadOnlyQuery(t *testing.T) {
+	for _, q := range []string{
+		"SELECT 1",
+		"SELECT\n  SUM(`cost`)\nFROM `session`",
+		"select\t`halo_brightness` from `wallbox_config`;",
+		"WITH\nt AS (SELECT 1) SELECT * FROM t",
+	} {
+		if err := checkReadOnlyQuery(q); err != nil {
+			t.Fatalf("%q: unexpected error %v", q, err)
+		}
+	}
+	for _, q := range []string{"", "DELETE FROM `session`", "selection", "SELECT 1; SELECT 2"} {
+		if err := checkReadOnlyQuery(q); err == nil {
+			t.Fatalf("%q: expected an error", q)
+		}
+	}
+}
+
+func TestSQLSensorValues(t *testing.T) {
+	sensors := []sqlSensor{{Name: "cost", Query: "SELECT 1", Unit: "EUR"}, {Name: "broken", Query: "SELECT 2"}}
+	values := newSQLSensorValues()
+	entities := getSQLSensorEntities(sensors, values)
+
+	cost := entities["sql_cost"]
+	if cost.Available() || cost.Config["unit_of_measurement"] != "EUR" {
+		t.Fatalf("expected an unavailable sensor with a unit before the first poll, got %+v", cost.Config)
+	}
+
+	results := map[string]string{"SELECT 1": "12.5"}
+	query := func(q string) (string, error) {
+		if v, ok := results[q]; ok {
+			return v, nil
+		}
+		return "", errors.New("query returns more than one row")
+	}
+	values.Refresh(sensors, query)
+
+	if !cost.Available() || cost.Value() != "12.5" {
+		t.Fatalf("expected cost 12.5, got %q", cost.Value())
+	}
+	if entities["sql_broken"].Available() {
+		t.Fatalf("expected a failing query to leave its sensor unavailable")
+	}
+
+	delete(results, "SELECT 1")
+	values.Refresh(sensors, query)
+	if cost.Available() {
+		t.Fatalf("expected a sensor to turn unavailable once its query fails")
+	}
+}
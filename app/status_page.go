@@ -0,0 +1,137 @@
+package bridge
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type statusEntry struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Unit  string `json:"unit,omitempty"`
+}
+
+// statusPage serves the last published entity values as a small HTML page
+// (/) and as JSON (/status.json) for users without Home Assistant. The poll
+// loop pushes a snapshot every cycle so HTTP requests never call getters.
+type statusPage struct {
+	mu             sync.RWMutex
+	device         string
+	refreshSeconds int
+	updated        time.Time
+	entries        []statusEntry
+}
+
+func newStatusPage(device string, refreshSeconds int) *statusPage {
+	if refreshSeconds < 5 {
+		refreshSeconds = 5
+	}
+	return &statusPage{device: device, refreshSeconds: refreshSeconds}
+}
+
+// Update snapshots the current value of every entity that has a state.
+func (p *statusPage) Update(entities map[string]Entity, now time.Time) {
+	entries := make([]statusEntry, 0, len(entities))
+	for key, e := range entities {
+		if e.Component == "button" {
+			continue
+		}
+		name := e.Config["name"]
+		if name == "" {
+			name = key
+		}
+		entries = append(entries, statusEntry{
+			Key:   key,
+			Name:  name,
+			Value: e.Value(),
+			Unit:  e.Config["unit_of_measurement"],
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	p.mu.Lock()
+	p.entries = entries
+	p.updated = now
+	p.mu.Unlock()
+}
+
+// Values returns the last snapshot as a key to value map.
+func (p *statusPage) Values() map[string]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	values := make(map[string]string, len(p.entries))
+	for _, e := range p.entries {
+		values[e.Key] = e.Value
+	}
+	return values
+}
+
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>{{.Device}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 1em; border-bottom: 1px solid #ddd; text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Device}}</h1>
+<p>Updated {{.Updated}} &middot; <a href="status.json">JSON</a></p>
+<table>
+<tr><th>Entity</th><th>Value</th></tr>
+{{range .Entries}}<tr><td title="{{.Key}}">{{.Name}}</td><td>{{.Value}}{{if .Unit}} {{.Unit}}{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (p *statusPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	updated := "never"
+	if !p.updated.IsZero() {
+		updated = p.updated.Format(time.RFC3339)
+	}
+
+	switch r.URL.Path {
+	case "/status.json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"device":   p.device,
+			"updated":  updated,
+			"entities": p.entries,
+		})
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		statusPageTemplate.Execute(w, map[string]interface{}{
+			"Device":  p.device,
+			"Refresh": p.refreshSeconds,
+			"Updated": updated,
+			"Entries": p.entries,
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// startStatusPage serves handler (the status page plus any optional
+// endpoints) on addr in the background.
+func startStatusPage(addr string, handler http.Handler) {
+	log.Printf("Serving status page on http://%s/", addr)
+	go func() {
+		if err := http.ListenAndServe(addr, handler); err != nil {
+			log.Printf("Status page stopped: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,67 @@
+package bridge
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusPage(t *testing.T) {
+	page := newStatusPage("Wallbox", 1)
+	page.Update(map[string]Entity{
+		"charging_power": {
+			Component: "sensor",
+			Getter:    func() string { return "7200" },
+			Config:    map[string]string{"name": "Charging power", "unit_of_measurement": "W"},
+		},
+		"status": {
+			Component: "sensor",
+			Getter:    func() string { return "<Charging>" },
+			Config:    map[string]string{"name": "Status"},
+		},
+		"restart_wallbox": {
+			Component: "button",
+			Getter:    func() string { return "" },
+			Config:    map[string]string{"name": "Restart Wallbox"},
+		},
+	}, time.Date(2025, 11, 23, 8, 0, 0, 0, time.UTC))
+
+	rec := httptest.NewRecorder()
+	page.ServeHTTP(rec, httptest.NewRequest("GET", "/status.json", nil))
+
+	var body struct {
+		Device   string        `json:"device"`
+		Updated  string        `json:"updated"`
+		Entities []statusEntry `json:"entities"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body.Updated != "2025-11-23T08:00:00Z" || len(body.Entities) != 2 {
+		t.Fatalf("unexpected JSON status: %+v", body)
+	}
+	if e := body.Entities[0]; e.Key != "charging_power" || e.Value != "7200" || e.Unit != "W" {
+		t.Fatalf("unexpected first entity: %+v", e)
+	}
+
+	rec = httptest.NewRecorder()
+	page.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	html := rec.Body.String()
+	if !strings.Contains(html, `content="5"`) {
+		t.Fatalf("expected refresh to be clamped to 5 seconds")
+	}
+	if !strings.Contains(html, "7200 W") || !strings.Contains(html, "&lt;Charging&gt;") {
+		t.Fatalf("expected escaped entity values in HTML, got:\n%s", html)
+	}
+	if strings.Contains(html, "Restart Wallbox") {
+		t.Fatalf("buttons have no state and should not be listed")
+	}
+
+	rec = httptest.NewRecorder()
+	page.ServeHTTP(rec, httptest.NewRequest("GET", "/missing", nil))
+	if rec.Code != 404 {
+		t.Fatalf("expected 404 for unknown paths, got %d", rec.Code)
+	}
+}
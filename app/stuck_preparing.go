@@ -0,0 +1,12 @@
+package bridge
+
+// ocppPreparing is the OCPP StatusNotification code for Preparing.
+const ocppPreparing = 2
+
+// isStuckPreparing reports whether one poll sample has OCPP in Preparing
+// while the car is connected, i.e. the car was plugged in and authorized
+// but the session hasn't moved on to Charging. A short Preparing phase is
+// normal, so only the sustained case is flagged, see sustainedCondition.
+func isStuckPreparing(ocppCode int, pilotConnected bool) bool {
+	return ocppCode == ocppPreparing && pilotConnected
+}
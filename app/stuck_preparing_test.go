@@ -0,0 +1,17 @@
+package bridge
+
+import "testing"
+
+func TestIsStuckPreparing(t *testing.T) {
+	if !isStuckPreparing(ocppPreparing, true) {
+		t.Fatalf("expected Preparing with a connected car to count")
+	}
+	if isStuckPreparing(ocppPreparing, false) {
+		t.Fatalf("expected Preparing without a car not to count")
+	}
+	for _, code := range []int{1, 3, 4, 5, 6, 9} {
+		if isStuckPreparing(code, true) {
+			t.Fatalf("unexpected stuck Preparing for OCPP %d", code)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package bridge
+
+import "time"
+
+// sustainedCondition flags a condition once it has held for a sustained
+// period, so brief blips that are part of normal operation never count.
+type sustainedCondition struct {
+	threshold time.Duration
+	start     time.Time
+	active    bool
+}
+
+func newSustainedCondition(threshold time.Duration) *sustainedCondition {
+	return &sustainedCondition{threshold: threshold}
+}
+
+// Update feeds one poll sample into the detector and returns whether the
+// condition is currently flagged.
+func (d *sustainedCondition) Update(now time.Time, holds bool) bool {
+	if !holds {
+		d.Reset()
+		return false
+	}
+
+	if d.start.IsZero() {
+		d.start = now
+	}
+	if now.Sub(d.start) >= d.threshold {
+		d.active = true
+	}
+	return d.active
+}
+
+// Reset clears any pending or active detection, e.g. after a heal.
+func (d *sustainedCondition) Reset() {
+	d.start = time.Time{}
+	d.active = false
+}
@@ -0,0 +1,44 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSustainedCondition_Timing(t *testing.T) {
+	d := newSustainedCondition(10 * time.Minute)
+	start := time.Now()
+
+	if d.Update(start, true) {
+		t.Fatalf("a brief condition must not be flagged")
+	}
+	if d.Update(start.Add(9*time.Minute+59*time.Second), true) {
+		t.Fatalf("flagged before the threshold elapsed")
+	}
+	if !d.Update(start.Add(10*time.Minute), true) {
+		t.Fatalf("expected the condition to be flagged after 10 minutes")
+	}
+	if d.Update(start.Add(11*time.Minute), false) {
+		t.Fatalf("expected the flag to clear once the condition no longer holds")
+	}
+}
+
+func TestSustainedCondition_RestartsTimer(t *testing.T) {
+	d := newSustainedCondition(5 * time.Minute)
+	start := time.Now()
+
+	d.Update(start, true)
+	// A sample without the condition in between restarts the timer.
+	d.Update(start.Add(4*time.Minute), false)
+	if d.Update(start.Add(6*time.Minute), true) {
+		t.Fatalf("expected the timer to restart after the condition cleared")
+	}
+	if !d.Update(start.Add(11*time.Minute), true) {
+		t.Fatalf("expected the condition 5 minutes after the restart")
+	}
+
+	d.Reset()
+	if d.Update(start.Add(12*time.Minute), true) {
+		t.Fatalf("expected Reset to restart the timer")
+	}
+}
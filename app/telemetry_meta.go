@@ -0,0 +1,72 @@
+package bridge
+
+// telemetryMeta is the Home Assistant typing for one kind of telemetry value.
+type telemetryMeta struct {
+	DeviceClass string
+	Unit        string
+	StateClass  string
+	Precision   string
+	Category    string
+}
+
+// telemetrySemantics maps what a telemetry value measures to its HA typing.
+var telemetrySemantics = map[string]telemetryMeta{
+	"current":    {DeviceClass: "current", Unit: "A", StateClass: "measurement", Precision: "1", Category: "diagnostic"},
+	"voltage":    {DeviceClass: "voltage", Unit: "V", StateClass: "measurement", Precision: "1", Category: "diagnostic"},
+	"energy":     {DeviceClass: "energy", Unit: "Wh", StateClass: "total_increasing", Precision: "1", Category: "diagnostic"},
+	"frequency":  {DeviceClass: "frequency", Unit: "Hz", StateClass: "measurement", Precision: "1", Category: "diagnostic"},
+	"duration":   {DeviceClass: "duration", Unit: "s", StateClass: "total_increasing", Precision: "0", Category: "diagnostic"},
+	"percentage": {Unit: "%", StateClass: "measurement", Precision: "1", Category: "diagnostic"},
+}
+
+// telemetryFieldSemantics says what each numeric telemetry debug sensor
+// measures. Sensors not listed here (states, enums, flags) keep only the
+// config they declare themselves.
+var telemetryFieldSemantics = map[string]string{
+	"icp_max_current":                   "current",
+	"user_current_proposal":             "current",
+	"ecosmart_current_proposal":         "current",
+	"powerboost_proposal_current":       "current",
+	"max_available_current":             "current",
+	"max_charging_current_sensor":       "current",
+	"dynamic_power_sharing_max_current": "current",
+	"dca_current_l1":                    "current",
+	"dca_current_l2":                    "current",
+	"dca_current_l3":                    "current",
+	"internal_meter_voltage_l1":         "voltage",
+	"internal_meter_voltage_l2":         "voltage",
+	"internal_meter_voltage_l3":         "voltage",
+	"control_pilot_high_voltage":        "voltage",
+	"control_pilot_low_voltage":         "voltage",
+	"dca_voltage_l1":                    "voltage",
+	"dca_voltage_l2":                    "voltage",
+	"dca_voltage_l3":                    "voltage",
+	"internal_meter_energy":             "energy",
+	"internal_meter_frequency":          "frequency",
+	"dca_meter_frequency":               "frequency",
+	"on_time":                           "duration",
+	"control_pilot_duty":                "percentage",
+}
+
+// applyTelemetryMeta fills in device class, unit, state class, precision and
+// entity category from telemetryFieldSemantics. Keys an entity sets itself
+// are left alone.
+func applyTelemetryMeta(entities map[string]Entity) {
+	for key, entity := range entities {
+		meta, ok := telemetrySemantics[telemetryFieldSemantics[key]]
+		if !ok || entity.Config == nil {
+			continue
+		}
+		for field, value := range map[string]string{
+			"device_class":                meta.DeviceClass,
+			"unit_of_measurement":         meta.Unit,
+			"state_class":                 meta.StateClass,
+			"suggested_display_precision": meta.Precision,
+			"entity_category":             meta.Category,
+		} {
+			if _, set := entity.Config[field]; !set && value != "" {
+				entity.Config[field] = value
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package bridge
+
+import (
+	"testing"
+
+	"wallbox-mqtt-bridge/app/wallbox"
+)
+
+func TestTelemetryMeta(t *testing.T) {
+	entities := getTelemetryEventEntities(&wallbox.Wallbox{})
+
+	for key, semantic := range telemetryFieldSemantics {
+		if _, ok := telemetrySemantics[semantic]; !ok {
+			t.Errorf("%s: unknown semantic %q", key, semantic)
+		}
+		if _, ok := entities[key]; !ok {
+			t.Errorf("%s: no such telemetry entity", key)
+		}
+	}
+
+	onTime := entities["on_time"].Config
+	if onTime["device_class"] != "duration" || onTime["unit_of_measurement"] != "s" || onTime["entity_category"] != "diagnostic" {
+		t.Fatalf("expected on_time to be typed as a duration, got %v", onTime)
+	}
+	if got := entities["ocpp_status"].Config["device_class"]; got != "" {
+		t.Fatalf("expected untyped sensors to stay untyped, got device_class %q", got)
+	}
+
+	// Config an entity declares itself wins over the table.
+	custom := map[string]Entity{"icp_max_current": {Config: map[string]string{"suggested_display_precision": "0"}}}
+	applyTelemetryMeta(custom)
+	if got := custom["icp_max_current"].Config; got["suggested_display_precision"] != "0" || got["device_class"] != "current" {
+		t.Fatalf("expected explicit precision to be kept and the rest filled in, got %v", got)
+	}
+}
+
+func TestPhaseEnergyEntities(t *testing.T) {
+	w := wallbox.NewStub()
+	entities := getPhaseEnergyEntities(w)
+	for key, e := range entities {
+		if e.Condition() {
+			t.Fatalf("%s: expected no discovery before telemetry reports per-phase energy", key)
+		}
+	}
+
+	w.HasTelemetry = true
+	w.Data.RedisTelemetry.InternalMeterEnergyL2 = 1200
+	for key, e := range entities {
+		if !e.Condition() {
+			t.Fatalf("%s: expected discovery once a per-phase counter arrived", key)
+		}
+	}
+}
+
+func TestEcosmartEnergyAvailability(t *testing.T) {
+	w := wallbox.NewStub()
+	entities := getEntities(w)
+	keys := []string{"ecosmart_green_energy", "ecosmart_energy_total"}
+
+	// Other telemetry arrived first: the counters must not publish 0.
+	w.HasTelemetry = true
+	for _, key := range keys {
+		if entities[key].Available() {
+			t.Fatalf("%s: expected unavailable before its own sample arrived", key)
+		}
+	}
+
+	w.Data.RedisTelemetry.EcosmartGreenEnergy = 1500
+	w.Data.RedisTelemetry.EcosmartEnergyTotal = 4200
+	for _, key := range keys {
+		if !entities[key].Available() {
+			t.Fatalf("%s: expected available once the counter was received", key)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package wallbox
+
+import "testing"
+
+func TestSetAutoLockTime(t *testing.T) {
+	// Without auto-lock support no SQL client is touched.
+	var w Wallbox
+
+	for _, seconds := range []int{0, MinAutoLockTime - 1, MaxAutoLockTime + 1} {
+		if err := w.SetAutoLockTime(seconds); err == nil {
+			t.Errorf("expected %ds to be rejected", seconds)
+		}
+	}
+	if err := w.SetAutoLockTime(300); err != nil {
+		t.Fatalf("expected a no-op on chargers without auto-lock, got %v", err)
+	}
+	if err := w.SetAutoLockEnabled(true); err != nil {
+		t.Fatalf("expected a no-op on chargers without auto-lock, got %v", err)
+	}
+	if w.AutoLockSupported() || w.AutoLockEnabled() {
+		t.Fatalf("expected auto-lock to stay unsupported")
+	}
+}
+
+func TestSetAutoLock_NotWritable(t *testing.T) {
+	// Supported, but the writes don't fit the schema: nothing is executed.
+	w := Wallbox{autoLockSupported: true}
+
+	if err := w.SetAutoLockEnabled(true); err == nil {
+		t.Fatalf("expected an error without a validated set_auto_lock_enabled")
+	}
+	if err := w.SetAutoLockTime(300); err == nil {
+		t.Fatalf("expected an error without a validated set_auto_lock_time")
+	}
+}
@@ -0,0 +1,31 @@
+package wallbox
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestAvailableCurrentFallback(t *testing.T) {
+	var w Wallbox
+
+	if got := w.availableCurrentOrFallback(0, nil); got != DefaultAvailableCurrent {
+		t.Fatalf("expected 0 A to fall back to %d A, got %d", DefaultAvailableCurrent, got)
+	}
+
+	w.SetFallbackAvailableCurrent(16)
+	if got := w.availableCurrentOrFallback(0, sql.ErrNoRows); got != 16 {
+		t.Fatalf("expected a failed read to fall back to 16 A, got %d", got)
+	}
+	if got := clampChargingCurrent(32, w.availableCurrentOrFallback(0, nil)); got != 16 {
+		t.Fatalf("expected setters to clamp to the fallback, got %d", got)
+	}
+
+	if got := w.availableCurrentOrFallback(25, nil); got != 25 {
+		t.Fatalf("expected a known available current to be used, got %d", got)
+	}
+
+	w.SetFallbackAvailableCurrent(-1)
+	if got := w.availableCurrentOrFallback(0, nil); got != DefaultAvailableCurrent {
+		t.Fatalf("expected an invalid fallback to select the default, got %d", got)
+	}
+}
@@ -0,0 +1,143 @@
+package wallbox
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestRecordBackendError(t *testing.T) {
+	var w Wallbox
+	if got := w.LastBackendError(); got != "None" {
+		t.Fatalf("expected no last error, got %q", got)
+	}
+	if got := w.LastBackendErrorAt(); got != "" {
+		t.Fatalf("expected no last error time, got %q", got)
+	}
+
+	w.recordBackendError("redis", errors.New("connection refused"))
+	err := w.recordBackendError("mysql", errors.New("bad connection"))
+	w.RecordSkippedCycle()
+
+	if err.Error() != "mysql: bad connection" {
+		t.Fatalf("expected wrapped error, got %q", err)
+	}
+	if w.RedisErrors() != 1 || w.MySQLErrors() != 1 || w.SkippedCycles() != 1 {
+		t.Fatalf("unexpected counters: redis=%d mysql=%d skipped=%d", w.RedisErrors(), w.MySQLErrors(), w.SkippedCycles())
+	}
+	if got := w.LastBackendError(); !strings.Contains(got, "bad connection") {
+		t.Fatalf("expected last error to be the MySQL one, got %q", got)
+	}
+	if w.LastBackendErrorAt() == "" {
+		t.Fatalf("expected a last error timestamp")
+	}
+}
+
+func TestLastSeen(t *testing.T) {
+	var w Wallbox
+	if !w.LastSeen().IsZero() {
+		t.Fatalf("expected no last-seen time before any data")
+	}
+
+	t0 := time.Date(2025, 11, 23, 22, 0, 0, 0, time.UTC)
+	w.markSeen(&w.lastStateAt, t0)
+	w.markSeen(&w.lastTelemetryAt, t0.Add(time.Minute))
+	if got := w.LastSeen(); !got.Equal(t0.Add(time.Minute)) {
+		t.Fatalf("expected the newer telemetry time, got %s", got)
+	}
+	w.markSeen(&w.lastStateAt, t0.Add(2*time.Minute))
+	if got := w.LastSeen(); !got.Equal(t0.Add(2 * time.Minute)) {
+		t.Fatalf("expected the newer state time, got %s", got)
+	}
+}
+
+func TestBackendHealthTransitions(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	var w Wallbox
+	w.recordBackendOK("mysql")
+	w.recordBackendError("mysql", errors.New("bad connection"))
+	w.recordBackendError("mysql", errors.New("bad connection"))
+	w.recordBackendOK("mysql")
+	w.recordBackendOK("mysql")
+
+	out := buf.String()
+	if n := strings.Count(out, "mysql unhealthy"); n != 1 {
+		t.Fatalf("expected the failure to be logged once, got %d times:\n%s", n, out)
+	}
+	if n := strings.Count(out, "mysql healthy again"); n != 1 {
+		t.Fatalf("expected the recovery to be logged once, got %d times:\n%s", n, out)
+	}
+}
+
+func TestReconnectMySQLBackoff(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	// Nothing listens on port 1, so every attempt fails right away.
+	w := Wallbox{mysqlDSN: "root:@tcp(127.0.0.1:1)/wallbox", mysqlAddr: "127.0.0.1:1"}
+	t0 := time.Now()
+	w.reconnectMySQL(t0)
+	if !w.mysqlRetryAt.Equal(t0.Add(mysqlReconnectDelay)) {
+		t.Fatalf("expected the next attempt after %s, got %s", mysqlReconnectDelay, w.mysqlRetryAt.Sub(t0))
+	}
+
+	// Within the backoff no attempt is made.
+	w.reconnectMySQL(t0.Add(time.Second))
+	if !w.mysqlRetryAt.Equal(t0.Add(mysqlReconnectDelay)) {
+		t.Fatalf("reconnected during the backoff")
+	}
+
+	t1 := t0.Add(mysqlReconnectDelay)
+	w.reconnectMySQL(t1)
+	if !w.mysqlRetryAt.Equal(t1.Add(2 * mysqlReconnectDelay)) {
+		t.Fatalf("expected the delay to double, got %s", w.mysqlRetryAt.Sub(t1))
+	}
+
+	w.recordBackendOK("mysql")
+	if w.mysqlRetryDelay != 0 || !w.mysqlRetryAt.IsZero() {
+		t.Fatalf("expected a successful read to reset the backoff")
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	cases := map[error]bool{
+		driver.ErrBadConn: true,
+		fmt.Errorf("query: %w", mysql.ErrInvalidConn):                   true,
+		&net.OpError{Op: "dial", Err: errors.New("connection refused")}: true,
+		&mysql.MySQLError{Number: 1054, Message: "Unknown column"}:      false,
+		sql.ErrNoRows: false,
+	}
+	for err, want := range cases {
+		if got := isConnectionError(err); got != want {
+			t.Errorf("isConnectionError(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
+
+func TestIsSchemaError(t *testing.T) {
+	cases := map[error]bool{
+		&mysql.MySQLError{Number: 1054, Message: "Unknown column 'max_charging_current_l1'"}:            true,
+		fmt.Errorf("phase limits: %w", &mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"}): true,
+		&mysql.MySQLError{Number: 1045, Message: "Access denied"}:                                       false,
+		driver.ErrBadConn: false,
+	}
+	for err, want := range cases {
+		if got := isSchemaError(err); got != want {
+			t.Errorf("isSchemaError(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
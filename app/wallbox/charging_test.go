@@ -0,0 +1,177 @@
+package wallbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChargingDetected(t *testing.T) {
+	const threshold = 100.0
+
+	cases := []struct {
+		mode  string
+		pilot bool
+		power float64
+		want  bool
+	}{
+		{ChargingModePilot, true, 0, true},
+		{ChargingModePilot, false, 7000, false},
+
+		{ChargingModePower, true, 0, false},
+		{ChargingModePower, false, 7000, true},
+		{ChargingModePower, true, threshold, false},
+
+		{ChargingModePilotAndPower, true, 0, false},
+		{ChargingModePilotAndPower, false, 7000, false},
+		{ChargingModePilotAndPower, true, 7000, true},
+
+		{ChargingModePilotOrPower, true, 0, true},
+		{ChargingModePilotOrPower, false, 7000, true},
+		{ChargingModePilotOrPower, false, 50, false},
+
+		{"", true, 0, true},
+	}
+
+	for _, tc := range cases {
+		if got := chargingDetected(tc.mode, tc.pilot, tc.power, threshold); got != tc.want {
+			t.Errorf("chargingDetected(%q, pilot=%v, power=%v) = %v, want %v", tc.mode, tc.pilot, tc.power, got, tc.want)
+		}
+	}
+}
+
+func TestSetChargingDetection_UnknownModeFallsBackToPilot(t *testing.T) {
+	var w Wallbox
+	w.SetChargingDetection("bogus", 100)
+	if w.chargingMode != ChargingModePilot {
+		t.Fatalf("expected fallback to %q, got %q", ChargingModePilot, w.chargingMode)
+	}
+}
+
+func TestIdlePowerFloor_ZeroesPhantomReadings(t *testing.T) {
+	var w Wallbox
+	w.HasTelemetry = true
+	w.SetIdlePowerFloor(100)
+
+	// Disconnected (pilot A) with a little meter noise on L1.
+	w.Data.RedisTelemetry.ControlPilotStatus = 161
+	w.Data.RedisTelemetry.InternalMeterVoltageL1 = 230
+	w.Data.RedisTelemetry.InternalMeterCurrentL1 = 0.2
+
+	if got := w.ChargingPower(); got != 0 {
+		t.Fatalf("expected phantom power to be zeroed, got %v", got)
+	}
+	if got := w.ChargingPowerL1(); got != 0 {
+		t.Fatalf("expected phantom L1 power to be zeroed, got %v", got)
+	}
+	if got := w.ChargingCurrentL1(); got != 0 {
+		t.Fatalf("expected phantom L1 current to be zeroed, got %v", got)
+	}
+	if got := w.RawChargingPower(); got != 46 {
+		t.Fatalf("expected raw power to stay available, got %v", got)
+	}
+
+	// The same reading while charging is passed through.
+	w.Data.RedisTelemetry.ControlPilotStatus = 194
+	if got := w.ChargingPower(); got != 46 {
+		t.Fatalf("expected power to be reported while charging, got %v", got)
+	}
+
+	// Real draw above the floor is never hidden.
+	w.Data.RedisTelemetry.ControlPilotStatus = 161
+	w.Data.RedisTelemetry.InternalMeterCurrentL1 = 6
+	if got := w.ChargingPower(); got != 1380 {
+		t.Fatalf("expected power above the floor to be reported, got %v", got)
+	}
+}
+
+func TestCarConnectedDuration(t *testing.T) {
+	w := &Wallbox{HasTelemetry: true}
+	t0 := time.Date(2025, 11, 23, 8, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		pilot float64
+		at    time.Duration
+		want  time.Duration
+	}{
+		{161, 0, 0},                               // A: nothing plugged in
+		{177, time.Minute, 0},                     // B: timer starts
+		{178, 10 * time.Minute, 9 * time.Minute},  // still B, not charging
+		{194, 30 * time.Minute, 29 * time.Minute}, // C: charging keeps the same start
+		{0, 40 * time.Minute, 39 * time.Minute},   // unknown reading keeps the timer
+		{161, 45 * time.Minute, 0},                // A: unplugged
+		{177, 50 * time.Minute, 0},                // plugged in again
+		{177, 55 * time.Minute, 5 * time.Minute},
+	}
+
+	for i, step := range steps {
+		w.Data.RedisTelemetry.ControlPilotStatus = step.pilot
+		now := t0.Add(step.at)
+		w.trackCarConnected(now)
+		if got := w.carConnectedDuration(now); got != step.want {
+			t.Fatalf("step %d (pilot %v): expected %s, got %s", i, step.pilot, step.want, got)
+		}
+	}
+}
+
+func TestResyncSessionEnergyBaseline(t *testing.T) {
+	var w Wallbox
+	if w.ResyncSessionEnergyBaseline() || w.sessionEnergyBaseline != 0 {
+		t.Fatalf("expected no re-sync without a meter reading")
+	}
+
+	w.HasTelemetry = true
+	w.Data.RedisTelemetry.StateMachine = 194
+	w.Data.RedisTelemetry.InternalMeterEnergy = 1000
+	w.AddedEnergy()
+	w.Data.RedisTelemetry.InternalMeterEnergy = 151000 // meter jumped after a firmware update
+	if got := w.AddedEnergy(); got != 150000 {
+		t.Fatalf("expected the drifted session energy before the re-sync, got %v", got)
+	}
+
+	if !w.ResyncSessionEnergyBaseline() {
+		t.Fatal("expected the re-sync to succeed with a meter reading")
+	}
+	if got := w.AddedEnergy(); got != 0 {
+		t.Fatalf("expected the session energy to restart at 0, got %v", got)
+	}
+	w.Data.RedisTelemetry.InternalMeterEnergy = 151500
+	if got := w.AddedEnergy(); got != 500 {
+		t.Fatalf("expected the session energy to count from the re-sync, got %v", got)
+	}
+}
+
+func TestChargingVoltage(t *testing.T) {
+	var w Wallbox
+	w.Data.RedisM2W.Line1Power = 3450
+	w.Data.RedisM2W.Line1Current = 15
+	if got := w.ChargingVoltageL1(); got != 230 {
+		t.Fatalf("expected the voltage derived from legacy power and current, got %v", got)
+	}
+	if got := w.ChargingVoltageL2(); got != 0 {
+		t.Fatalf("expected 0 without legacy current, got %v", got)
+	}
+
+	w.HasTelemetry = true
+	w.Data.RedisTelemetry.InternalMeterVoltageL1 = 233.5
+	if got := w.ChargingVoltageL1(); got != 233.5 {
+		t.Fatalf("expected the telemetry voltage to win, got %v", got)
+	}
+}
+
+func TestGridFrequency(t *testing.T) {
+	var w Wallbox
+	w.Data.RedisTelemetry.InternalMeterFrequency = 50
+	if got := w.GridFrequency(); got != 0 {
+		t.Fatalf("expected unknown frequency without telemetry, got %v", got)
+	}
+
+	w.HasTelemetry = true
+	if got := w.GridFrequency(); got != 50 {
+		t.Fatalf("expected the internal meter frequency, got %v", got)
+	}
+	w.Data.RedisTelemetry.InternalMeterFrequency = 0
+	w.Data.RedisTelemetry.DCAMeterFrequency = 49.98
+	if got := w.GridFrequency(); got != 49.98 {
+		t.Fatalf("expected the DCA meter fallback, got %v", got)
+	}
+}
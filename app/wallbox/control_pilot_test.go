@@ -0,0 +1,42 @@
+package wallbox
+
+import "testing"
+
+func TestApplyControlPilotOverrides(t *testing.T) {
+	saved := func(m map[int]string) map[int]string {
+		c := make(map[int]string, len(m))
+		for k, v := range m {
+			c[k] = v
+		}
+		return c
+	}
+	states, letters := saved(telemetryControlPilotStates), saved(telemetryControlPilotLetters)
+	connected := make(map[int]bool, len(telemetryControlPilotConnected))
+	for k, v := range telemetryControlPilotConnected {
+		connected[k] = v
+	}
+	defer func() {
+		telemetryControlPilotStates, telemetryControlPilotLetters, telemetryControlPilotConnected = states, letters, connected
+	}()
+
+	ApplyControlPilotOverrides(ControlPilotOverrides{
+		States:    map[int]string{195: "Connected 3"},
+		Letters:   map[int]string{195: "B"},
+		Connected: map[int]bool{162: true},
+	})
+
+	w := &Wallbox{HasTelemetry: true}
+	w.Data.RedisTelemetry.ControlPilotStatus = 195
+	if got := w.ControlPilotStatus(); got != "195: Connected 3" {
+		t.Fatalf("expected overridden description, got %q", got)
+	}
+	if got := w.ControlPilotLetter(); got != "B" {
+		t.Fatalf("expected overridden letter B, got %q", got)
+	}
+	if !isTelemetryCableConnected(162) {
+		t.Fatalf("expected code 162 to be treated as connected")
+	}
+	if got := telemetryControlPilotLetters[193]; got != "C" {
+		t.Fatalf("expected codes without override to keep their letter, got %q", got)
+	}
+}
@@ -0,0 +1,60 @@
+package wallbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// customQueryTimeout bounds a user-supplied query so a slow one can't stall
+// the poll loop.
+const customQueryTimeout = 5 * time.Second
+
+// QueryScalar runs a user-supplied query in a read-only transaction and
+// returns its single value as text. Anything other than exactly one row with
+// one column is an error; NULL reads as "".
+func (w *Wallbox) QueryScalar(query string) (string, error) {
+	db := w.db()
+	if db == nil {
+		return "", errors.New("no database connection")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), customQueryTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	if len(cols) != 1 {
+		return "", fmt.Errorf("query returns %d columns, expected 1", len(cols))
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", errors.New("query returns no rows")
+	}
+	var value sql.NullString
+	if err := rows.Scan(&value); err != nil {
+		return "", err
+	}
+	if rows.Next() {
+		return "", errors.New("query returns more than one row")
+	}
+	return value.String, rows.Err()
+}
@@ -0,0 +1,112 @@
+package wallbox
+
+import "testing"
+
+func TestEffectiveStatus_LegacyStateOverrides(t *testing.T) {
+	cases := []struct {
+		state int
+		want  string
+	}{
+		{0xA1, "Ready"},
+		{0xA2, "Unconfigured power sharing"},
+		{0xA3, "OCPP unavailable"},
+		{0xA4, "OCPP charge finishing"},
+		{0xA6, "Updating"},
+		{0xB1, "Connected waiting schedule"},
+		{0xB2, "Paused"},
+		{0xB3, "Connected waiting schedule"},
+		{0xB4, "Connected waiting car"},
+		{0xB5, "Connected waiting car"},
+		{0xB6, "Paused"},
+		{0xB7, "Connected waiting current assignation"},
+		{0xB8, "Connected waiting current assignation"},
+		{0xB9, "Queue by power boost"},
+		{0xBA, "Queue by power boost"},
+		{0xBB, "Connected waiting admin auth for mid"},
+		{0xBC, "Connected mid safety margin exceeded"},
+		{0xBD, "Queue by eco smart"},
+		{0xC1, "Charging"},
+		{0xC2, "Charging"},
+		{0xC3, "Discharging"},
+		{0xC4, "Discharging"},
+		{0xD1, "Locked"},
+		{0xD2, "Locked"},
+	}
+
+	if len(cases) != len(stateOverrides) {
+		t.Fatalf("table covers %d overrides but stateOverrides has %d entries", len(cases), len(stateOverrides))
+	}
+
+	for _, tc := range cases {
+		var w Wallbox
+		// The m2w charger status must be ignored whenever an override exists.
+		w.Data.RedisM2W.ChargerStatus = 7
+		w.Data.RedisState.SessionState = tc.state
+
+		if got := w.EffectiveStatus(); got != tc.want {
+			t.Errorf("session state 0x%X: expected %q, got %q", tc.state, tc.want, got)
+		}
+	}
+}
+
+func TestEffectiveStatus_LegacyChargerStatus(t *testing.T) {
+	cases := []struct {
+		name          string
+		chargerStatus int
+		sessionState  int
+		want          string
+	}{
+		{"no override uses m2w status", 1, 0, "Charging"},
+		{"error status", 7, 0xE, "Error"},
+		{"last status code", len(wallboxStatusCodes) - 1, 0, "Queue by eco smart"},
+		{"out of range", len(wallboxStatusCodes), 0, "Unknown"},
+		{"negative", -1, 0, "Unknown"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var w Wallbox
+			w.Data.RedisM2W.ChargerStatus = tc.chargerStatus
+			w.Data.RedisState.SessionState = tc.sessionState
+
+			if got := w.EffectiveStatus(); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEffectiveStatus_Telemetry(t *testing.T) {
+	cases := []struct {
+		stateMachine float64
+		want         string
+	}{
+		{161, "Ready"},
+		{178, "Paused"},
+		{181, "Waiting"},
+		{194, "Charging"},
+		{209, "Locked"},
+		{14, "Error"},
+		{250, "Unknown"},
+	}
+
+	for _, tc := range cases {
+		var w Wallbox
+		w.HasTelemetry = true
+		w.Data.RedisTelemetry.StateMachine = tc.stateMachine
+		// Legacy data must not leak into the telemetry path.
+		w.Data.RedisState.SessionState = 0xC1
+
+		if got := w.EffectiveStatus(); got != tc.want {
+			t.Errorf("state machine %v: expected %q, got %q", tc.stateMachine, tc.want, got)
+		}
+	}
+
+	// A zero state machine sample falls back to the legacy path.
+	var w Wallbox
+	w.HasTelemetry = true
+	w.Data.RedisState.SessionState = 0xC1
+	if got := w.EffectiveStatus(); got != "Charging" {
+		t.Fatalf("expected legacy fallback to give %q, got %q", "Charging", got)
+	}
+}
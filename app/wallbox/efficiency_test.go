@@ -0,0 +1,41 @@
+package wallbox
+
+import "testing"
+
+func TestSessionEfficiency(t *testing.T) {
+	if _, ok := sessionEfficiency(200, 220, 500); ok {
+		t.Fatalf("expected no efficiency below the minimum delivered energy")
+	}
+	if _, ok := sessionEfficiency(600, 0, 500); ok {
+		t.Fatalf("expected no efficiency without grid-side energy")
+	}
+	if got, ok := sessionEfficiency(9000, 10000, 500); !ok || got != 90 {
+		t.Fatalf("expected 90%%, got %v (ok=%v)", got, ok)
+	}
+}
+
+func TestEfficiency_TracksSessionBaseline(t *testing.T) {
+	var w Wallbox
+	w.HasTelemetry = true
+
+	// Idle: the baseline follows the lifetime meter.
+	w.Data.RedisTelemetry.InternalMeterEnergy = 120000
+	w.trackEfficiencyBaseline()
+	if _, ok := w.Efficiency(); ok {
+		t.Fatalf("expected no efficiency without delivered session energy")
+	}
+
+	// Session running: delivered 950 Wh while the meter moved 1000 Wh.
+	w.Data.SQL.ActiveSessionEnergyTotal = 950
+	w.Data.RedisTelemetry.InternalMeterEnergy = 121000
+	w.trackEfficiencyBaseline()
+	if got, ok := w.Efficiency(); !ok || got != 95 {
+		t.Fatalf("expected 95%%, got %v (ok=%v)", got, ok)
+	}
+
+	// Legacy firmware without telemetry only has one source.
+	w.HasTelemetry = false
+	if w.HasEfficiencySources() {
+		t.Fatalf("expected efficiency sources to be missing without telemetry")
+	}
+}
@@ -0,0 +1,47 @@
+package wallbox
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFirstFirmwareVersion(t *testing.T) {
+	var tried []string
+	source := func(name, version string, err error) func() (string, error) {
+		return func() (string, error) {
+			tried = append(tried, name)
+			return version, err
+		}
+	}
+	missing := errors.New("no rows")
+
+	cases := []struct {
+		sources []func() (string, error)
+		want    string
+		tried   []string
+	}{
+		{
+			[]func() (string, error){source("version", "6.7.12", nil), source("charger_info", "6.5.0", nil), source("redis", "6.0.0", nil)},
+			"6.7.12", []string{"version"},
+		},
+		{
+			[]func() (string, error){source("version", "", missing), source("charger_info", " ", nil), source("redis", "6.7.12", nil)},
+			"6.7.12", []string{"version", "charger_info", "redis"},
+		},
+		{
+			[]func() (string, error){source("version", "", missing), source("charger_info", "", missing), source("redis", "", missing)},
+			"unknown", []string{"version", "charger_info", "redis"},
+		},
+	}
+
+	for i, tc := range cases {
+		tried = nil
+		if got := firstFirmwareVersion(tc.sources...); got != tc.want {
+			t.Errorf("case %d: expected %q, got %q", i, tc.want, got)
+		}
+		if !reflect.DeepEqual(tried, tc.tried) {
+			t.Errorf("case %d: expected sources %v to be tried, got %v", i, tc.tried, tried)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package wallbox
+
+import (
+	"log"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// JournalPattern is a user-configured regular expression matched against
+// the journal of a systemd unit on the charger.
+type JournalPattern struct {
+	Name string
+	Unit string
+	Re   *regexp.Regexp
+}
+
+// JournalMatch is one journal line that matched a JournalPattern. Line has
+// secrets redacted; Groups holds the pattern's capture groups.
+type JournalMatch struct {
+	Name   string
+	Line   string
+	Groups []string
+	At     time.Time
+}
+
+// Journal patterns report at most journalMatchBurst matches per
+// journalMatchWindow each, so a crash loop printing the same line thousands
+// of times cannot flood MQTT.
+const (
+	journalMatchBurst  = 10
+	journalMatchWindow = time.Minute
+)
+
+type journalPatternState struct {
+	JournalPattern
+	limiter matchLimiter
+}
+
+// SetJournalPatterns configures the patterns watched by
+// StartOCPPJournalWatcher and the handler called for each (rate-limited)
+// match. It must be called before the watcher starts.
+func (w *Wallbox) SetJournalPatterns(patterns []JournalPattern, onMatch func(JournalMatch)) {
+	w.journalPatterns = make(map[string][]*journalPatternState)
+	for _, p := range patterns {
+		unit := p.Unit
+		if unit == "" {
+			unit = ocppJournalUnit
+		}
+		w.journalPatterns[unit] = append(w.journalPatterns[unit], &journalPatternState{
+			JournalPattern: p,
+			limiter:        matchLimiter{max: journalMatchBurst, window: journalMatchWindow},
+		})
+	}
+	w.onJournalMatch = onMatch
+}
+
+func (w *Wallbox) matchJournalPatterns(patterns []*journalPatternState, line string, now time.Time) {
+	for _, p := range patterns {
+		groups := p.Re.FindStringSubmatch(line)
+		if groups == nil {
+			continue
+		}
+		allowed, firstDrop := p.limiter.Allow(now)
+		if firstDrop {
+			log.Printf("Journal pattern %s matched more than %d times in %s, dropping matches until the window passes", p.Name, journalMatchBurst, journalMatchWindow)
+		}
+		if !allowed || w.onJournalMatch == nil {
+			continue
+		}
+		w.onJournalMatch(JournalMatch{
+			Name:   p.Name,
+			Line:   redactJournalLine(line),
+			Groups: groups[1:],
+			At:     now,
+		})
+	}
+}
+
+// matchLimiter allows up to max events per fixed window.
+type matchLimiter struct {
+	max    int
+	window time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+// Allow reports whether an event at now is within the limit, and whether it
+// is the first one dropped in the current window.
+func (l *matchLimiter) Allow(now time.Time) (allowed, firstDrop bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.windowStart) >= l.window {
+		l.windowStart = now
+		l.count = 0
+	}
+	l.count++
+	return l.count <= l.max, l.count == l.max+1
+}
@@ -0,0 +1,42 @@
+package wallbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackLockTransition(t *testing.T) {
+	var w Wallbox
+	var events []bool
+	w.SetLockTransitionHandler(func(locked bool, at time.Time) {
+		events = append(events, locked)
+	})
+
+	start := time.Date(2025, 11, 23, 8, 0, 0, 0, time.UTC)
+	samples := []int{1, 1, 0, 0, 1, 0}
+	for i, lock := range samples {
+		w.trackLockTransition(lock, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	// The initial locked sample is not a transition.
+	if got := w.LockCount(); got != 1 {
+		t.Fatalf("expected 1 lock, got %d", got)
+	}
+	if got := w.UnlockCount(); got != 2 {
+		t.Fatalf("expected 2 unlocks, got %d", got)
+	}
+	if got, want := w.LastUnlockedAt(), start.Add(5*time.Minute).Format(time.RFC3339); got != want {
+		t.Fatalf("expected last unlock at %s, got %s", want, got)
+	}
+	if len(events) != 3 || events[0] || !events[1] || events[2] {
+		t.Fatalf("expected unlocked, locked, unlocked events, got %v", events)
+	}
+}
+
+func TestLastUnlockedAt_EmptyWithoutUnlock(t *testing.T) {
+	var w Wallbox
+	w.trackLockTransition(1, time.Now())
+	if got := w.LastUnlockedAt(); got != "" {
+		t.Fatalf("expected no last unlock, got %q", got)
+	}
+}
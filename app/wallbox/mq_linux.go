@@ -5,8 +5,8 @@ import (
 	"unsafe"
 )
 
-func mqOpen(path []byte) uintptr {
-	mq, _, _ := syscall.Syscall6(
+func mqOpen(path []byte) (uintptr, error) {
+	mq, _, errno := syscall.Syscall6(
 		uintptr(MqOpenSyscall),
 		uintptr(unsafe.Pointer(&path[0])),
 		uintptr(0x02),
@@ -16,11 +16,14 @@ func mqOpen(path []byte) uintptr {
 		uintptr(0),
 	)
 
-	return mq
+	if errno != 0 {
+		return 0, errno
+	}
+	return mq, nil
 }
 
-func mqTimedsend(fd uintptr, data []byte) uintptr {
-	mqLock, _, _ := syscall.Syscall6(
+func mqTimedsend(fd uintptr, data []byte) error {
+	_, _, errno := syscall.Syscall6(
 		uintptr(MqTimedSendSyscall),
 		uintptr(fd),
 		uintptr(unsafe.Pointer(&data[0])),
@@ -30,7 +33,10 @@ func mqTimedsend(fd uintptr, data []byte) uintptr {
 		uintptr(0),
 	)
 
-	return mqLock
+	if errno != 0 {
+		return errno
+	}
+	return nil
 }
 
 func mqClose(fd uintptr) {
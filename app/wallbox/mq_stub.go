@@ -2,6 +2,10 @@
 
 package wallbox
 
-func mqOpen(path []byte) uintptr                  { return 0 }
-func mqTimedsend(fd uintptr, data []byte) uintptr { return 0 }
-func mqClose(fd uintptr)                          {}
+import "errors"
+
+var errNoPosixQueues = errors.New("posix message queues are only available on Linux")
+
+func mqOpen(path []byte) (uintptr, error)       { return 0, errNoPosixQueues }
+func mqTimedsend(fd uintptr, data []byte) error { return errNoPosixQueues }
+func mqClose(fd uintptr)                        {}
@@ -0,0 +1,17 @@
+package wallbox
+
+import "testing"
+
+func TestMySQLConfigDSN(t *testing.T) {
+	if got := (MySQLConfig{}).withDefaults().DSN(); got != "root:fJmExsJgmKV7cq8H@tcp(127.0.0.1:3306)/wallbox" {
+		t.Fatalf("expected the on-device DSN by default, got %q", got)
+	}
+
+	c := MySQLConfig{Host: "replica.lan", Port: 3307, Password: "p@ss/word"}.withDefaults()
+	if got := c.DSN(); got != "root:p@ss/word@tcp(replica.lan:3307)/wallbox" {
+		t.Fatalf("unexpected DSN %q", got)
+	}
+	if isOnDeviceEndpoint(c.Addr(), "3306") {
+		t.Fatalf("expected %s to count as off-device", c.Addr())
+	}
+}
@@ -0,0 +1,34 @@
+package wallbox
+
+import "testing"
+
+func TestPickInterfaceAddr(t *testing.T) {
+	cases := []struct {
+		name    string
+		addrs   map[string][]string
+		iface   string
+		ip      string
+		matched bool
+	}{
+		{"prefers wlan0", map[string][]string{"eth0": {"10.0.0.2"}, "wlan0": {"192.168.1.40"}}, "wlan0", "192.168.1.40", true},
+		{"falls back to eth0", map[string][]string{"eth0": {"10.0.0.2"}, "wlan0": nil}, "eth0", "10.0.0.2", true},
+		{"other interfaces sorted", map[string][]string{"ppp0": {"100.64.0.9"}, "enp1s0": {"10.1.1.1"}}, "enp1s0", "10.1.1.1", true},
+		{"offline", map[string][]string{}, "", "", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			iface, ip, ok := pickInterfaceAddr(tc.addrs)
+			if iface != tc.iface || ip != tc.ip || ok != tc.matched {
+				t.Fatalf("expected (%q, %q, %v), got (%q, %q, %v)", tc.iface, tc.ip, tc.matched, iface, ip, ok)
+			}
+		})
+	}
+}
+
+func TestNetworkInfo_OffDevice(t *testing.T) {
+	w := NewStub()
+	if info := w.NetworkInfo(); info.IP != "unknown" || info.SSID != "unknown" || info.Interface != "unknown" {
+		t.Fatalf("expected unknown network info off-device, got %+v", info)
+	}
+}
@@ -0,0 +1,60 @@
+package wallbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPickOCPPStatus(t *testing.T) {
+	now := time.Date(2025, 11, 23, 22, 50, 0, 0, time.UTC)
+	journal := ocppSample{code: 3, at: now.Add(-5 * time.Minute)} // Charging, missed the stop
+	session := ocppSample{code: 1, at: now.Add(-time.Minute)}     // Available
+	stale := ocppSample{code: 3, at: now.Add(-ocppStatusMaxAge)}
+	none := ocppSample{code: -1}
+
+	cases := []struct {
+		precedence       string
+		journal, session ocppSample
+		want             int
+		ok               bool
+	}{
+		{OCPPPrecedenceJournal, journal, session, 3, true},
+		{"", journal, session, 3, true},
+		{OCPPPrecedenceSession, journal, session, 1, true},
+		{OCPPPrecedenceNewest, journal, session, 1, true},
+		{OCPPPrecedenceNewest, ocppSample{code: 3, at: now}, session, 3, true},
+		{OCPPPrecedenceJournal, stale, session, 1, true},
+		{OCPPPrecedenceSession, journal, none, 3, true},
+		{OCPPPrecedenceNewest, stale, none, 0, false},
+	}
+
+	for i, tc := range cases {
+		got, ok := pickOCPPStatus(tc.precedence, tc.journal, tc.session, now)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("case %d (%s): expected %d/%v, got %d/%v", i, tc.precedence, tc.want, tc.ok, got, ok)
+		}
+	}
+}
+
+func TestOCPPStatusCode_Precedence(t *testing.T) {
+	w := &Wallbox{}
+	w.Data.RedisTelemetry.OCPPStatus = 9
+	if got := w.OCPPStatusCode(); got != 9 {
+		t.Fatalf("expected the telemetry fallback without journal or session status, got %d", got)
+	}
+
+	w.SetJournalOCPPStatus(3)
+	w.SetTelemetryOCPPStatus(1)
+
+	for mode, want := range map[string]int{
+		OCPPPrecedenceJournal: 3,
+		OCPPPrecedenceSession: 1,
+		OCPPPrecedenceNewest:  1,
+		"bogus":               3,
+	} {
+		w.SetOCPPPrecedence(mode)
+		if got := w.OCPPStatusCode(); got != want {
+			t.Errorf("%s: expected %d, got %d", mode, want, got)
+		}
+	}
+}
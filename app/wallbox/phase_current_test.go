@@ -0,0 +1,45 @@
+package wallbox
+
+import "testing"
+
+func TestPhaseCurrentColumn(t *testing.T) {
+	for phase, want := range map[int]string{1: "max_charging_current_l1", 2: "max_charging_current_l2", 3: "max_charging_current_l3"} {
+		if got, err := phaseCurrentColumn(phase); err != nil || got != want {
+			t.Errorf("phase %d: expected %q, got %q (%v)", phase, want, got, err)
+		}
+	}
+	for _, phase := range []int{0, 4, -1} {
+		if _, err := phaseCurrentColumn(phase); err == nil {
+			t.Errorf("expected phase %d to be rejected", phase)
+		}
+	}
+}
+
+func TestClampChargingCurrent(t *testing.T) {
+	cases := []struct{ current, available, want int }{
+		{16, 32, 16},
+		{40, 32, 32},
+		{2, 32, MinChargingCurrent},
+		{40, 0, 40}, // unknown available current
+		{0, 0, MinChargingCurrent},
+	}
+	for _, tc := range cases {
+		if got := clampChargingCurrent(tc.current, tc.available); got != tc.want {
+			t.Errorf("clampChargingCurrent(%d, %d) = %d, want %d", tc.current, tc.available, got, tc.want)
+		}
+	}
+}
+
+func TestSetMaxChargingCurrentPhase_Unsupported(t *testing.T) {
+	var w Wallbox
+	if err := w.SetMaxChargingCurrentPhase(2, 16); err != nil {
+		t.Fatalf("expected a no-op without per-phase support, got %v", err)
+	}
+	if err := w.SetMaxChargingCurrentPhase(4, 16); err == nil {
+		t.Fatalf("expected an invalid phase to be rejected")
+	}
+	w.phaseLimits = phaseCurrentLimits{L1: 16, L2: 10, L3: 8}
+	if w.MaxChargingCurrentPhase(2) != 10 || w.MaxChargingCurrentPhase(3) != 8 || w.MaxChargingCurrentPhase(0) != 0 {
+		t.Fatalf("unexpected per-phase limits")
+	}
+}
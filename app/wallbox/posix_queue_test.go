@@ -0,0 +1,18 @@
+package wallbox
+
+import "testing"
+
+func TestSendToPosixQueue_MissingQueue(t *testing.T) {
+	if posixQueueExists("WALLBOX_BRIDGE_TEST_MISSING_QUEUE") {
+		t.Fatalf("expected a missing queue to be reported as such")
+	}
+	if err := sendToPosixQueue("WALLBOX_BRIDGE_TEST_MISSING_QUEUE", "EVENT_REQUEST_LOCK"); err == nil {
+		t.Fatalf("expected an error when the queue does not exist")
+	}
+}
+
+func TestRemoteControlAvailable_OffDevice(t *testing.T) {
+	if NewStub().RemoteControlAvailable() {
+		t.Fatalf("expected remote control to be unavailable off-device")
+	}
+}
@@ -0,0 +1,84 @@
+package wallbox
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetRedisFields_SkipsUntaggedFields(t *testing.T) {
+	type mixed struct {
+		Power    float64 `redis:"tms.line1.power_watt.value"`
+		Untagged float64
+		Ignored  int `redis:"-"`
+		internal int `redis:"internal.value"`
+		State    int `redis:"session.state"`
+	}
+
+	got := getRedisFields(mixed{})
+	want := []string{"tms.line1.power_watt.value", "session.state"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected fields %v, got %v", want, got)
+	}
+}
+
+func TestGetRedisFields_DataCacheHasNoEmptyFields(t *testing.T) {
+	var data DataCache
+	for _, obj := range []interface{}{data.RedisState, data.RedisM2W, data.RedisTelemetry} {
+		for _, field := range getRedisFields(obj) {
+			if field == "" {
+				t.Fatalf("empty redis field returned for %T", obj)
+			}
+		}
+	}
+}
+
+func TestTelemetryFieldIndex(t *testing.T) {
+	telemetry := 0
+	for _, field := range getRedisFields(DataCache{}.RedisTelemetry) {
+		if strings.HasPrefix(field, "telemetry.") {
+			telemetry++
+		}
+	}
+	if len(telemetryFieldIndex) != telemetry {
+		t.Fatalf("expected %d indexed telemetry fields, got %d", telemetry, len(telemetryFieldIndex))
+	}
+
+	var w Wallbox
+	w.updateTelemetryField("SENSOR_INTERNAL_METER_VOLTAGE_L2", 231)
+	if got := w.Data.RedisTelemetry.InternalMeterVoltageL2; got != 231 {
+		t.Fatalf("expected the voltage field to be set, got %v", got)
+	}
+	w.updateTelemetryField("SENSOR_NOT_TRACKED", 1)
+}
+
+func TestLogUnmappedSensorOnce(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	var w Wallbox
+	w.updateTelemetryField("SENSOR_NOT_TRACKED", 1)
+	w.updateTelemetryField("SENSOR_NOT_TRACKED", 2)
+	if got := strings.Count(buf.String(), "SENSOR_NOT_TRACKED"); got != 1 {
+		t.Fatalf("expected one log line per unmapped id by default, got %d", got)
+	}
+
+	buf.Reset()
+	w.SetUnmappedSensorLogging(UnmappedSensorLogAll)
+	w.updateTelemetryField("SENSOR_NOT_TRACKED", 3)
+	w.updateTelemetryField("SENSOR_NOT_TRACKED", 4)
+	if got := strings.Count(buf.String(), "SENSOR_NOT_TRACKED"); got != 2 {
+		t.Fatalf("expected every sample to be logged in %q mode, got %d", UnmappedSensorLogAll, got)
+	}
+
+	buf.Reset()
+	w.SetUnmappedSensorLogging(UnmappedSensorLogNone)
+	w.updateTelemetryField("SENSOR_OTHER", 1)
+	if buf.Len() != 0 {
+		t.Fatalf("expected no logging in %q mode, got %q", UnmappedSensorLogNone, buf.String())
+	}
+}
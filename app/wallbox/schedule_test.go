@@ -0,0 +1,182 @@
+package wallbox
+
+import (
+	"testing"
+	"time"
+)
+
+const (
+	weekdays = 0x1F // Mon-Fri
+	everyDay = 0x7F
+)
+
+func TestNextScheduleWindow(t *testing.T) {
+	// Wednesday 2025-11-26.
+	wednesday := func(hour, minute int) time.Time {
+		return time.Date(2025, 11, 26, hour, minute, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name      string
+		schedules []Schedule
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+		wantOK    bool
+	}{
+		{
+			name:   "no schedules",
+			now:    wednesday(12, 0),
+			wantOK: false,
+		},
+		{
+			name:      "disabled schedule ignored",
+			schedules: []Schedule{{Start: "22:00", Stop: "06:00", Days: everyDay}},
+			now:       wednesday(12, 0),
+			wantOK:    false,
+		},
+		{
+			name:      "upcoming overnight window",
+			schedules: []Schedule{{Start: "22:00", Stop: "06:00", Days: everyDay, Enabled: true}},
+			now:       wednesday(12, 0),
+			wantStart: wednesday(22, 0),
+			wantEnd:   wednesday(22, 0).Add(8 * time.Hour),
+			wantOK:    true,
+		},
+		{
+			name:      "active window that started yesterday",
+			schedules: []Schedule{{Start: "22:00:00", Stop: "06:00:00", Days: everyDay, Enabled: true}},
+			now:       wednesday(3, 0),
+			wantStart: wednesday(22, 0).AddDate(0, 0, -1),
+			wantEnd:   wednesday(6, 0),
+			wantOK:    true,
+		},
+		{
+			name:      "weekday schedule on Friday evening points at Monday",
+			schedules: []Schedule{{Start: "01:00", Stop: "05:00", Days: weekdays, Enabled: true}},
+			now:       time.Date(2025, 11, 28, 20, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2025, 12, 1, 1, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2025, 12, 1, 5, 0, 0, 0, time.UTC),
+			wantOK:    true,
+		},
+		{
+			name: "earliest of several schedules wins",
+			schedules: []Schedule{
+				{Start: "23:00", Stop: "07:00", Days: everyDay, Enabled: true},
+				{Start: "13:00", Stop: "15:00", Days: everyDay, Enabled: true},
+			},
+			now:       wednesday(12, 0),
+			wantStart: wednesday(13, 0),
+			wantEnd:   wednesday(15, 0),
+			wantOK:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, start, end, ok := nextScheduleWindow(tc.schedules, tc.now)
+			if ok != tc.wantOK {
+				t.Fatalf("expected ok=%v, got %v", tc.wantOK, ok)
+			}
+			if !ok {
+				return
+			}
+			if !start.Equal(tc.wantStart) || !end.Equal(tc.wantEnd) {
+				t.Fatalf("expected %v-%v, got %v-%v", tc.wantStart, tc.wantEnd, start, end)
+			}
+		})
+	}
+}
+
+func TestChargerTimezone(t *testing.T) {
+	var w Wallbox
+	if w.Location() != time.Local {
+		t.Fatalf("expected the bridge host's timezone before the charger reports one")
+	}
+
+	w.setTimezone("Not/AZone")
+	if w.Location() != time.Local {
+		t.Fatalf("expected an unknown zone to keep the host's timezone")
+	}
+
+	w.setTimezone(" Asia/Tokyo ")
+	if got := w.Timezone(); got != "Asia/Tokyo" {
+		t.Fatalf("expected Asia/Tokyo, got %q", got)
+	}
+
+	// 23:30 UTC is 08:30 the next day in Tokyo, inside a 08:00-09:00 window.
+	w.schedules = []Schedule{{Start: "08:00", Stop: "09:00", Days: 0x7f, Enabled: true}}
+	now := time.Date(2025, 11, 23, 23, 30, 0, 0, time.UTC).In(w.Location())
+	_, start, _, ok := nextScheduleWindow(w.schedules, now)
+	if !ok || !start.Equal(time.Date(2025, 11, 24, 8, 0, 0, 0, w.Location())) {
+		t.Fatalf("expected the window to start at 08:00 Tokyo time, got %s", start)
+	}
+}
+
+func TestScheduleStatus(t *testing.T) {
+	var w Wallbox
+	if got := w.ScheduleStatus(); got != "Unknown" {
+		t.Fatalf("expected Unknown without telemetry, got %q", got)
+	}
+
+	w.HasTelemetry = true
+	for code, want := range map[float64]string{0: "Inactive", 1: "Active", 7: "Unknown (7)"} {
+		w.Data.RedisTelemetry.ScheduleStatus = code
+		if got := w.ScheduleStatus(); got != want {
+			t.Fatalf("code %v: expected %q, got %q", code, want, got)
+		}
+	}
+}
+
+func TestPlanScheduleWrites_OffOnCycle(t *testing.T) {
+	schedules := []Schedule{
+		{ID: 1, Start: "22:00", Stop: "06:00", Days: everyDay, Enabled: true},
+		{ID: 2, Start: "12:00", Stop: "14:00", Days: weekdays, Enabled: false},
+		{ID: 3, Start: "01:00", Stop: "05:00", Days: weekdays, Enabled: true},
+	}
+	apply := func(ids []int64, enabled bool) {
+		for _, id := range ids {
+			for i := range schedules {
+				if schedules[i].ID == id {
+					schedules[i].Enabled = enabled
+				}
+			}
+		}
+	}
+
+	writes, disarmed, err := planScheduleWrites(schedules, nil, false)
+	if err != nil {
+		t.Fatalf("disarm: %v", err)
+	}
+	apply(writes, false)
+	saved := parseScheduleIDs(formatScheduleIDs(disarmed))
+	for _, s := range schedules {
+		if s.Enabled {
+			t.Fatalf("expected every schedule off after disarming, got %+v", schedules)
+		}
+	}
+
+	// Disarming again must not forget what to restore.
+	if _, again, _ := planScheduleWrites(schedules, saved, false); again != nil {
+		t.Fatalf("expected the saved schedules to be kept, got %v", again)
+	}
+
+	writes, _, err = planScheduleWrites(schedules, saved, true)
+	if err != nil {
+		t.Fatalf("arm: %v", err)
+	}
+	apply(writes, true)
+	want := []bool{true, false, true}
+	for i, s := range schedules {
+		if s.Enabled != want[i] {
+			t.Fatalf("schedule %d: expected enabled=%v after re-arming, got %v", s.ID, want[i], s.Enabled)
+		}
+	}
+}
+
+func TestPlanScheduleWrites_NothingDisarmed(t *testing.T) {
+	schedules := []Schedule{{ID: 1, Start: "22:00", Stop: "06:00", Days: everyDay}}
+	if _, _, err := planScheduleWrites(schedules, nil, true); err == nil {
+		t.Fatalf("expected arming without saved schedules to fail instead of enabling all of them")
+	}
+}
@@ -0,0 +1,168 @@
+package wallbox
+
+import (
+	"fmt"
+	"testing"
+)
+
+func sessionUpdatePayload(state string, inSession bool, controlAction string) string {
+	return fmt.Sprintf(`{"header":{"message_id":"EVENT_SESSION_UPDATE","source":"charger_state_machine","timestamp":"2025-11-23T22:49:54Z"},
+		"body":{"session":{"state":%q,"in_session":%t,"control_mode":"","control_action":%q}}}`, state, inSession, controlAction)
+}
+
+func TestLastSessionEndReason(t *testing.T) {
+	type step struct {
+		state         string
+		inSession     bool
+		controlAction string
+	}
+
+	cases := []struct {
+		name  string
+		steps []step
+		want  string
+	}{
+		{
+			name: "completed after car stopped drawing",
+			steps: []step{
+				{"CHARGING_1", true, ""},
+				{"CONNECTED_5", true, ""},
+				{"READY", false, ""},
+			},
+			want: "Completed",
+		},
+		{
+			name: "unplugged while charging",
+			steps: []step{
+				{"CHARGING_2", true, ""},
+				{"READY", false, ""},
+			},
+			want: "Unplugged",
+		},
+		{
+			name: "stopped remotely",
+			steps: []step{
+				{"CHARGING_1", true, ""},
+				{"FINISH", false, "STOP"},
+			},
+			want: "Stopped remotely",
+		},
+		{
+			name: "error",
+			steps: []step{
+				{"CHARGING_1", true, ""},
+				{"ERROR", false, ""},
+			},
+			want: "Error",
+		},
+		{
+			name: "schedule end",
+			steps: []step{
+				{"SCHEDULED", true, ""},
+				{"FINISH", false, ""},
+			},
+			want: "Schedule end",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var w Wallbox
+			for _, s := range tc.steps {
+				w.ProcessSessionUpdateEvent(sessionUpdatePayload(s.state, s.inSession, s.controlAction))
+			}
+			if got := w.LastSessionEndReason(); got != tc.want {
+				t.Fatalf("expected end reason %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLastSessionEndReason_NoSession(t *testing.T) {
+	var w Wallbox
+	w.ProcessSessionUpdateEvent(sessionUpdatePayload("READY", false, ""))
+
+	if got := w.LastSessionEndReason(); got != "None" {
+		t.Fatalf("expected %q without a finished session, got %q", "None", got)
+	}
+}
+
+func TestProcessSessionUpdateEvent_OCPPStatus(t *testing.T) {
+	payload := func(messageID, state string) string {
+		return fmt.Sprintf(`{"header":{"message_id":%q,"source":"charger_state_machine","timestamp":"2025-11-23T22:49:54Z"},
+			"body":{"session":{"state":%q,"in_session":true,"control_mode":"","control_action":""}}}`, messageID, state)
+	}
+
+	cases := []struct {
+		name    string
+		payload string
+		want    int
+		set     bool
+	}{
+		{"charging", payload("EVENT_SESSION_UPDATE", "CHARGING_1"), 3, true},
+		{"connected", payload("EVENT_SESSION_UPDATE", "CONNECTED_5"), 5, true},
+		{"waiting maps to preparing", payload("EVENT_SESSION_UPDATE", "WAITING_MID"), 2, true},
+		{"ready", payload("EVENT_SESSION_UPDATE", "READY"), 1, true},
+		{"other message id", payload("EVENT_SESSION_CREATED", "CHARGING_1"), 0, false},
+		{"empty message id", payload("", "CHARGING_1"), 0, false},
+		{"empty state", payload("EVENT_SESSION_UPDATE", ""), 0, false},
+		{"unmapped state", payload("EVENT_SESSION_UPDATE", "BOGUS_STATE"), 0, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var w Wallbox
+			if err := w.ProcessSessionUpdateEvent(tc.payload); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			code, ok := w.getTelemetryOCPPStatus()
+			if ok != tc.set {
+				t.Fatalf("expected telemetry OCPP status set=%v, got set=%v (code %d)", tc.set, ok, code)
+			}
+			if ok && code != tc.want {
+				t.Fatalf("expected OCPP code %d, got %d", tc.want, code)
+			}
+		})
+	}
+}
+
+func TestProcessSessionUpdateEvent_InvalidJSON(t *testing.T) {
+	var w Wallbox
+	if err := w.ProcessSessionUpdateEvent(`{"header":`); err == nil {
+		t.Fatalf("expected an error for malformed JSON")
+	}
+	if _, ok := w.getTelemetryOCPPStatus(); ok {
+		t.Fatalf("expected no OCPP status from a malformed event")
+	}
+}
+
+func TestSessionEnergyBaselineResetsOnPlugIn(t *testing.T) {
+	w := &Wallbox{HasTelemetry: true}
+	steps := []struct {
+		meter     float64
+		inSession bool
+		want      float64
+	}{
+		// Already in a session when the bridge starts: not a new plug-in.
+		{1000, true, 0},
+		{1500, true, 0},
+		{2000, false, 0},
+		// New plug-in: the baseline moves to the meter reading, once.
+		{2100, true, 2100},
+		{2600, true, 2100},
+		{3000, true, 2100},
+		{3000, false, 2100},
+		{3000, false, 2100},
+		{3200, true, 3200},
+	}
+	for i, step := range steps {
+		w.Data.RedisTelemetry.InternalMeterEnergy = step.meter
+		if err := w.ProcessSessionUpdateEvent(sessionUpdatePayload("CHARGING_1", step.inSession, "")); err != nil {
+			t.Fatal(err)
+		}
+		if w.sessionEnergyBaseline != step.want {
+			t.Fatalf("step %d: expected baseline %.0f, got %.0f", i, step.want, w.sessionEnergyBaseline)
+		}
+	}
+}
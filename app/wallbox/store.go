@@ -0,0 +1,172 @@
+package wallbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store persists the bridge's own state, such as the lock audit and
+// contactor counters, across restarts.
+type Store interface {
+	// Get returns the value of key and whether it exists.
+	Get(key string) (string, bool, error)
+	Set(key, value string) error
+	// Incr adds one to the integer stored at key (0 if missing) and returns
+	// the new value.
+	Incr(key string) (int, error)
+}
+
+// DefaultStoreKeyPrefix is prepended to every key of the Redis store.
+const DefaultStoreKeyPrefix = "bridge:"
+
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore keeps state in Redis under prefix + key.
+func NewRedisStore(client *redis.Client, prefix string) Store {
+	return &redisStore{client: client, prefix: prefix}
+}
+
+func (s *redisStore) Get(key string) (string, bool, error) {
+	value, err := s.client.Get(context.Background(), s.prefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (s *redisStore) Set(key, value string) error {
+	return s.client.Set(context.Background(), s.prefix+key, value, 0).Err()
+}
+
+func (s *redisStore) Incr(key string) (int, error) {
+	n, err := s.client.Incr(context.Background(), s.prefix+key).Result()
+	return int(n), err
+}
+
+// MemoryStore keeps state in memory only. It backs the self-test stub and
+// tests.
+type MemoryStore struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: make(map[string]string)}
+}
+
+func (s *MemoryStore) Get(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[key]
+	return value, ok, nil
+}
+
+func (s *MemoryStore) Set(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	return nil
+}
+
+func (s *MemoryStore) Incr(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return incrValue(s.values, key)
+}
+
+func incrValue(values map[string]string, key string) (int, error) {
+	n := 0
+	if value, ok := values[key]; ok {
+		var err error
+		if n, err = strconv.Atoi(value); err != nil {
+			return 0, err
+		}
+	}
+	n++
+	values[key] = strconv.Itoa(n)
+	return n, nil
+}
+
+// fileStore keeps state in a local JSON object, for off-device deployments
+// where the charger's Redis isn't writable. Every change rewrites the file.
+type fileStore struct {
+	mu     sync.Mutex
+	path   string
+	values map[string]string
+}
+
+// NewFileStore loads path, which need not exist yet.
+func NewFileStore(path string) (Store, error) {
+	s := &fileStore{path: path, values: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.values); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileStore) Get(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[key]
+	return value, ok, nil
+}
+
+func (s *fileStore) Set(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	return s.save()
+}
+
+func (s *fileStore) Incr(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, err := incrValue(s.values, key)
+	if err != nil {
+		return 0, err
+	}
+	return n, s.save()
+}
+
+// save writes the values to a temporary file and renames it over path, so a
+// crash never leaves a truncated file behind.
+func (s *fileStore) save() error {
+	data, err := json.MarshalIndent(s.values, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,79 @@
+package wallbox
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+
+	if _, ok, err := s.Get("missing"); ok || err != nil {
+		t.Fatalf("expected a missing key, got ok=%v err=%v", ok, err)
+	}
+	if err := s.Set("name", "value"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if v, ok, err := s.Get("name"); !ok || err != nil || v != "value" {
+		t.Fatalf("expected value, got %q ok=%v err=%v", v, ok, err)
+	}
+	for want := 1; want <= 3; want++ {
+		if n, err := s.Incr("count"); err != nil || n != want {
+			t.Fatalf("expected Incr to return %d, got %d (%v)", want, n, err)
+		}
+	}
+	if _, err := s.Incr("name"); err == nil {
+		t.Fatalf("expected Incr on a non-number to fail")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	testStore(t, s)
+
+	reloaded, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reloading: %v", err)
+	}
+	if v, ok, _ := reloaded.Get("count"); !ok || v != "3" {
+		t.Fatalf("expected count 3 after reload, got %q", v)
+	}
+}
+
+func TestPersistedStateSurvivesRestart(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2025, 11, 23, 8, 0, 0, 0, time.UTC)
+
+	first := Wallbox{store: store}
+	first.trackLockTransition(1, start)
+	first.trackLockTransition(0, start.Add(time.Minute))
+	first.trackLockTransition(1, start.Add(2*time.Minute))
+	first.trackContactorCycle(161)
+	first.trackContactorCycle(194)
+	if got := first.LastHealReboot(); got != "" {
+		t.Fatalf("expected no heal reboot yet, got %q", got)
+	}
+	first.RecordHealReboot(start.Add(3 * time.Minute))
+
+	second := Wallbox{store: store}
+	second.loadPersistedState()
+	if second.LockCount() != 1 || second.UnlockCount() != 1 || second.ContactorCycles() != 1 {
+		t.Fatalf("expected 1 lock, 1 unlock and 1 contactor cycle, got %d, %d, %d",
+			second.LockCount(), second.UnlockCount(), second.ContactorCycles())
+	}
+	if got := second.LastUnlockedAt(); got != start.Add(time.Minute).Format(time.RFC3339) {
+		t.Fatalf("unexpected last unlock %q", got)
+	}
+	if got := second.LastHealReboot(); got != start.Add(3*time.Minute).Format(time.RFC3339) {
+		t.Fatalf("unexpected last heal reboot %q", got)
+	}
+}
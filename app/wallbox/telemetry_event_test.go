@@ -0,0 +1,155 @@
+package wallbox
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestProcessTelemetryEvent_MixedValueTypes(t *testing.T) {
+	payload := `{"header":{"message_id":"EVENT_TELEMETRY","source":"telemetry","timestamp":"2025-11-23T22:49:54Z"},
+		"body":{"sensors":[
+			{"id":"SENSOR_INTERNAL_METER_VOLTAGE_L1","value":231.5},
+			{"id":"SENSOR_CONTROL_PILOT_STATUS","value":"194"},
+			{"id":"SENSOR_MID_STATUS","value":"n/a"},
+			{"id":"SENSOR_INTERNAL_METER_CURRENT_L1","value":" 15.25 "}
+		]}}`
+
+	var w Wallbox
+	w.ProcessTelemetryEvent(payload)
+
+	if got := w.Data.RedisTelemetry.InternalMeterVoltageL1; got != 231.5 {
+		t.Fatalf("expected numeric value 231.5, got %v", got)
+	}
+	if got := w.Data.RedisTelemetry.ControlPilotStatus; got != 194 {
+		t.Fatalf("expected string value \"194\" to be parsed as 194, got %v", got)
+	}
+	if got := w.Data.RedisTelemetry.InternalMeterCurrentL1; got != 15.25 {
+		t.Fatalf("expected padded string value to be parsed as 15.25, got %v", got)
+	}
+	if got := w.Data.RedisTelemetry.MidStatus; got != 0 {
+		t.Fatalf("expected non-numeric value to be skipped, got %v", got)
+	}
+	if !w.HasTelemetry {
+		t.Fatalf("expected HasTelemetry to be set after a mixed event")
+	}
+}
+
+func TestProcessTelemetryEvent_ServiceOnlyKeepsLegacyPath(t *testing.T) {
+	payload := `{"header":{"message_id":"EVENT_TELEMETRY","source":"telemetry","timestamp":"2025-11-23T22:49:54Z"},
+		"body":{"sensors":[
+			{"id":"SENSOR_SOFTWARE_UPDATE_CPU_USAGE","value":3},
+			{"id":"SENSOR_MYSQLD_SIMPLE_STATE","value":1}
+		]}}`
+
+	var w Wallbox
+	w.ProcessTelemetryEvent(payload)
+	if w.HasTelemetry {
+		t.Fatalf("expected service-only telemetry not to set HasTelemetry")
+	}
+	if got := w.Data.RedisTelemetry.SoftwareUpdateCPUUsage; got != 3 {
+		t.Fatalf("expected the service metric to be stored anyway, got %v", got)
+	}
+
+	// A configured trigger makes the same event count.
+	w.SetTelemetryTriggers([]string{"SENSOR_MYSQLD"})
+	w.ProcessTelemetryEvent(payload)
+	if !w.HasTelemetry {
+		t.Fatalf("expected a configured trigger prefix to set HasTelemetry")
+	}
+}
+
+func TestParseTelemetryValue(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want float64
+		ok   bool
+	}{
+		{`42`, 42, true},
+		{`-1.5`, -1.5, true},
+		{`"161"`, 161, true},
+		{`"abc"`, 0, false},
+		{`null`, 0, false},
+		{``, 0, false},
+		{`{}`, 0, false},
+	}
+
+	for _, tc := range cases {
+		got, ok := parseTelemetryValue([]byte(tc.raw))
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("parseTelemetryValue(%s) = %v, %v; want %v, %v", tc.raw, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestTrackClockOffset(t *testing.T) {
+	var w Wallbox
+	received := time.Date(2025, 11, 23, 22, 50, 0, 0, time.UTC)
+
+	if _, known := w.TimeSyncOffset(); known {
+		t.Fatalf("expected no offset before any event")
+	}
+
+	w.trackClockOffset("not a timestamp", received)
+	if _, known := w.TimeSyncOffset(); known {
+		t.Fatalf("expected unparsable timestamp to be ignored")
+	}
+
+	w.trackClockOffset("2025-11-23T22:48:30Z", received)
+	if offset, known := w.TimeSyncOffset(); !known || offset != -90 {
+		t.Fatalf("expected charger 90s behind, got %v (known=%v)", offset, known)
+	}
+
+	w.trackClockOffset("2025-11-23T23:50:00.5+01:00", received)
+	if offset, _ := w.TimeSyncOffset(); offset != 0.5 {
+		t.Fatalf("expected zoned timestamp to give 0.5s offset, got %v", offset)
+	}
+}
+
+func TestHandleEvent_CountsParseFailuresPerChannel(t *testing.T) {
+	var w Wallbox
+
+	w.handleEvent("/wbx/telemetry/events", `{"header":{},"body":{"sensors":[]}}`)
+	w.handleEvent("/wbx/telemetry/events", `{not json`)
+	w.handleEvent("/wbx/charging_regulation/in/session", `[]`)
+	w.handleEvent("/wbx/unrelated", `{not json`)
+
+	if got := w.EventsProcessed(); got != 3 {
+		t.Fatalf("expected 3 processed events, got %d", got)
+	}
+	if got := w.EventParseFailures("telemetry"); got != 1 {
+		t.Fatalf("expected 1 telemetry parse failure, got %d", got)
+	}
+	if got := w.EventParseFailures("session"); got != 1 {
+		t.Fatalf("expected 1 session parse failure, got %d", got)
+	}
+	if got := w.EventParseFailures("state_machine"); got != 0 {
+		t.Fatalf("expected no state_machine parse failures, got %d", got)
+	}
+	if got := w.LastEventParseError(); !strings.HasPrefix(got, "session: ") {
+		t.Fatalf("expected last error to come from the session channel, got %q", got)
+	}
+}
+
+func TestRecordSubscription_CountsResubscribes(t *testing.T) {
+	var w Wallbox
+
+	// Initial subscription to three channels.
+	for i := 1; i <= 3; i++ {
+		w.recordSubscription(&redis.Subscription{Kind: "subscribe", Channel: "c", Count: i})
+	}
+	if got := w.RedisResubscribes(); got != 0 {
+		t.Fatalf("expected the initial subscription not to count, got %d", got)
+	}
+
+	// go-redis reconnects and subscribes again.
+	for i := 1; i <= 3; i++ {
+		w.recordSubscription(&redis.Subscription{Kind: "subscribe", Channel: "c", Count: i})
+	}
+	w.recordSubscription(&redis.Subscription{Kind: "unsubscribe", Channel: "c", Count: 1})
+	if got := w.RedisResubscribes(); got != 1 {
+		t.Fatalf("expected one resubscribe, got %d", got)
+	}
+}
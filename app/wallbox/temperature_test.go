@@ -0,0 +1,22 @@
+package wallbox
+
+import "testing"
+
+func TestTemperature_IgnoresUnusedPhases(t *testing.T) {
+	var w Wallbox
+	w.Data.RedisM2W.TempL1 = 40
+	w.Data.RedisM2W.TempL2 = 0
+	w.Data.RedisM2W.TempL3 = 46
+
+	if got := w.Temperature(); got != 46 {
+		t.Fatalf("expected max temperature 46, got %v", got)
+	}
+	if got := w.AverageTemperature(); got != 43 {
+		t.Fatalf("expected average over the two reporting phases to be 43, got %v", got)
+	}
+
+	var empty Wallbox
+	if got := empty.Temperature(); got != 0 {
+		t.Fatalf("expected 0 without any readings, got %v", got)
+	}
+}
@@ -0,0 +1,25 @@
+package wallbox
+
+import "testing"
+
+func TestUpdating(t *testing.T) {
+	var w Wallbox
+	w.HasTelemetry = true
+
+	w.Data.RedisTelemetry.StateMachine = 161 // Ready
+	w.Data.RedisTelemetry.SoftwareUpdateSimpleState = 3
+	if w.Updating() {
+		t.Fatalf("expected no update without configured busy states")
+	}
+
+	w.SetUpdateBusyStates([]int{2, 3})
+	if !w.Updating() {
+		t.Fatalf("expected software update busy state 3 to count as updating")
+	}
+
+	w.SetUpdateBusyStates(nil)
+	w.Data.RedisTelemetry.StateMachine = 166 // Updating
+	if !w.Updating() {
+		t.Fatalf("expected state machine Updating to count as updating")
+	}
+}
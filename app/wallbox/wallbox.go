@@ -4,17 +4,25 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net"
+	"os"
 	"os/exec"
 	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/redis/go-redis/v9"
 )
@@ -28,6 +36,7 @@ type DataCache struct {
 		CumulativeAddedEnergy    float64 `db:"cumulative_added_energy"`
 		AddedRange               float64 `db:"added_range"`
 		ActiveSessionEnergyTotal float64 `db:"active_session_energy_total"`
+		ActiveSessionID          int64   `db:"active_session_id"`
 	}
 
 	RedisState struct {
@@ -73,9 +82,12 @@ type DataCache struct {
 		ControlPilotHighVolts            float64 `redis:"telemetry.SENSOR_CONTROL_PILOT_HIGH_TENTHS_OF_VOLTS"`
 		ControlPilotLowVolts             float64 `redis:"telemetry.SENSOR_CONTROL_PILOT_LOW_TENTHS_OF_VOLTS"`
 
-		InternalMeterEnergy float64 `redis:"telemetry.SENSOR_INTERNAL_METER_ENERGY"`
-		EcosmartGreenEnergy float64 `redis:"telemetry.SENSOR_ECOSMART_GREEN_ENERGY"`
-		EcosmartEnergyTotal float64 `redis:"telemetry.SENSOR_ECOSMART_ENERGY_TOTAL"`
+		InternalMeterEnergy   float64 `redis:"telemetry.SENSOR_INTERNAL_METER_ENERGY"`
+		InternalMeterEnergyL1 float64 `redis:"telemetry.SENSOR_INTERNAL_METER_ENERGY_L1"`
+		InternalMeterEnergyL2 float64 `redis:"telemetry.SENSOR_INTERNAL_METER_ENERGY_L2"`
+		InternalMeterEnergyL3 float64 `redis:"telemetry.SENSOR_INTERNAL_METER_ENERGY_L3"`
+		EcosmartGreenEnergy   float64 `redis:"telemetry.SENSOR_ECOSMART_GREEN_ENERGY"`
+		EcosmartEnergyTotal   float64 `redis:"telemetry.SENSOR_ECOSMART_ENERGY_TOTAL"`
 
 		EcosmartMode            float64 `redis:"telemetry.SENSOR_ECOSMART_MODE"`
 		EcosmartStatus          float64 `redis:"telemetry.SENSOR_ECOSMART_STATUS"`
@@ -118,6 +130,7 @@ type DataCache struct {
 		PMSMetadata             float64 `redis:"telemetry.SENSOR_PMS_METADATA"`
 		PMSPhaseSwitch          float64 `redis:"telemetry.SENSOR_PMS_PHASE_SWITCH"`
 		GSMRecoTrigger          float64 `redis:"telemetry.SENSOR_GSM_RECO_TRIGGER"`
+		GSMSignalQuality        float64 `redis:"telemetry.SENSOR_GSM_SIGNAL_QUALITY"`
 		ConnectivityStatus      float64 `redis:"telemetry.SENSOR_CONNECTIVITY_STATUS"`
 		OnTime                  float64 `redis:"telemetry.SENSOR_ON_TIME"`
 		WifiSignalStrength      float64 `redis:"telemetry.SENSOR_WIFI_SIGNAL_STRENGTH"`
@@ -270,8 +283,84 @@ type DataCache struct {
 	}
 }
 
+// Queries holds the SQL statements run against the charger's `wallbox`
+// schema. Firmware revisions occasionally rename tables/columns, so each one
+// can be overridden; see ApplyQueryOverrides.
+type Queries struct {
+	Refresh            string
+	SerialNumber       string
+	FirmwareVersion    string
+	ChargerType        string
+	AvailableCurrent   string
+	Schedules          string
+	ConnectorType      string
+	LifetimeAddedRange string
+	AutoLock           string
+	PhaseCurrentLimits string
+	Timezone           string
+
+	// Writes; each is checked against the schema before it is used.
+	// SetEcosmartMode takes enabled (0/1) and the mode code, and
+	// SetSchedulesEnabled the enable flag (0/1) and id of one schedule.
+	// SetAutoLockEnabled takes the flag (0/1), SetAutoLockTime the seconds.
+	SetEcosmartMode     string
+	SetSchedulesEnabled string
+	SetAutoLockEnabled  string
+	SetAutoLockTime     string
+}
+
+var DefaultQueries = Queries{
+	Refresh: "SELECT " +
+		"  `wallbox_config`.`charging_enable`," +
+		"  `wallbox_config`.`lock`," +
+		"  `wallbox_config`.`max_charging_current`," +
+		"  `wallbox_config`.`halo_brightness`," +
+		"  `power_outage_values`.`charged_energy` AS cumulative_added_energy," +
+		"  IF(`active_session`.`unique_id` != 0," +
+		"    `active_session`.`charged_range`," +
+		"    `latest_session`.`charged_range`) AS added_range," +
+		"  IF(`active_session`.`unique_id` != 0," +
+		"    `active_session`.`energy_total`," +
+		"    0) AS active_session_energy_total," +
+		"  `active_session`.`unique_id` AS active_session_id " +
+		"FROM `wallbox_config`," +
+		"    `active_session`," +
+		"    `power_outage_values`," +
+		"    (SELECT * FROM `session` ORDER BY `id` DESC LIMIT 1) AS latest_session",
+	SerialNumber:       "SELECT `serial_num` FROM charger_info",
+	FirmwareVersion:    "SELECT `version` FROM `wallbox_version` ORDER BY `id` DESC LIMIT 1",
+	ChargerType:        "select SUBSTRING_INDEX(part_number, '-', 1) AS charger_type from charger_info;",
+	AvailableCurrent:   "SELECT `max_avbl_current` FROM `state_values` ORDER BY `id` DESC LIMIT 1",
+	Schedules:          "SELECT `id`, `start`, `stop`, `days`, `enable` AS enabled FROM `schedules`",
+	ConnectorType:      "SELECT `connector_type` FROM `charger_info` LIMIT 1",
+	LifetimeAddedRange: "SELECT COALESCE(SUM(`charged_range`), 0) FROM `session`",
+	AutoLock:           "SELECT `auto_lock`, `auto_lock_time` FROM `wallbox_config` LIMIT 1",
+	PhaseCurrentLimits: "SELECT `max_charging_current_l1`, `max_charging_current_l2`, `max_charging_current_l3` FROM `wallbox_config` LIMIT 1",
+	Timezone:           "SELECT `timezone` FROM `wallbox_config` LIMIT 1",
+
+	SetEcosmartMode:     "UPDATE `wallbox_config` SET `ecosmart_enabled`=?, `ecosmart_mode`=?",
+	SetSchedulesEnabled: "UPDATE `schedules` SET `enable`=? WHERE `id`=?",
+	SetAutoLockEnabled:  "UPDATE `wallbox_config` SET `auto_lock`=?",
+	SetAutoLockTime:     "UPDATE `wallbox_config` SET `auto_lock_time`=?",
+}
+
+// Schedule is one time-based charging schedule as returned by the schedules
+// query. Start and Stop are times of day ("HH:MM" or "HH:MM:SS"); a Stop at or
+// before Start means the window runs past midnight. Days is a bitmask with
+// bit 0 for Monday through bit 6 for Sunday.
+type Schedule struct {
+	ID      int64  `db:"id"`
+	Start   string `db:"start"`
+	Stop    string `db:"stop"`
+	Days    int    `db:"days"`
+	Enabled bool   `db:"enabled"`
+}
+
 type Wallbox struct {
-	redisClient          *redis.Client
+	redisClient *redis.Client
+	store       Store
+	// sqlClient is replaced by reconnectMySQL; use db() to read it.
+	sqlMux               sync.RWMutex
 	sqlClient            *sqlx.DB
 	Data                 DataCache
 	ChargerType          string `db:"charger_type"`
@@ -280,159 +369,845 @@ type Wallbox struct {
 	journalOCPPStatus    int
 	journalOCPPUpdated   time.Time
 	ocppStatusMux        sync.RWMutex
+	ocppPrecedence       string
 	// HasTelemetry becomes true once we have successfully processed at least
 	// one telemetry event and mapped it into RedisTelemetry. This lets higher
 	// layers prefer telemetry-based values on newer firmware while keeping a
 	// fallback to legacy Redis/M2W data for older firmware.
-	HasTelemetry          bool
-	pubsub                *redis.PubSub
-	eventHandler          func(channel string, message string)
+	HasTelemetry      bool
+	telemetryTriggers []string
+	pubsubMux         sync.Mutex
+	pubsub            *redis.PubSub
+	pubsubStopCh      chan struct{}
+	eventHandler      func(channel string, message string)
+	// sessionEnergyBaseline is reset from the pub/sub goroutine, read by
+	// the poll loop and re-synced from MQTT, hence baselineMux.
+	baselineMux           sync.Mutex
 	sessionEnergyBaseline float64
-	journalStopCh         chan struct{}
+	// efficiencyGridBaseline is the internal meter reading at the start of
+	// the current session, used to compute grid-side session energy.
+	efficiencyGridBaseline float64
+	autoLock               autoLockSettings
+	autoLockSupported      bool
+	phaseLimits            phaseCurrentLimits
+	phaseLimitsSupported   bool
+	// phaseLimitsMissing is set once the phase limits query failed on the
+	// schema, so it isn't sent again every poll.
+	phaseLimitsMissing bool
+	// activeSessionIDKnown is set once the refresh query, which reads
+	// active_session.unique_id, has succeeded.
+	activeSessionIDKnown bool
+	// location is the charger's configured timezone, nil until read.
+	location *time.Location
+	// carConnectedSince is when the pilot first reported a car (B or C)
+	// after being idle (A); zero while no car is connected.
+	carConnectedSince time.Time
+	journalStopCh     chan struct{}
+	journalPatterns   map[string][]*journalPatternState
+	onJournalMatch    func(JournalMatch)
+	// offDevice is set when MySQL/Redis are reached through something other
+	// than their on-device defaults (e.g. an SSH tunnel), in which case the
+	// posix-queue based controls cannot reach the charger.
+	offDevice bool
+	queries   Queries
+	// lastStateMachine is the previous SENSOR_STATE_MACHINE sample, used to
+	// spot charging-start transitions for contactorCycles.
+	lastStateMachine int
+	contactorCycles  int
+	// schedules is written by RefreshData and read by SetSchedulesEnabled
+	// from the MQTT goroutine, hence schedulesMux.
+	schedulesMux   sync.Mutex
+	schedules      []Schedule
+	schedulesKnown bool
+	connectorType  string
+
+	// Whether the Set* write statements fit the schema; see
+	// ApplyQueryOverrides.
+	ecosmartWritable        bool
+	schedulesWritable       bool
+	autoLockEnabledWritable bool
+	autoLockTimeWritable    bool
+
+	chargingMode           string
+	chargingPowerThreshold float64
+	idlePowerFloor         float64
+	updateBusyStates       []int
+
+	// lifetimeRange caches the summed session range; see LifetimeAddedRange.
+	lifetimeRange      float64
+	lifetimeRangeKnown bool
+	lifetimeRangeAt    time.Time
+
+	networkMux    sync.Mutex
+	networkInfo   NetworkInfo
+	networkInfoAt time.Time
+
+	// Lock audit: transitions of Data.SQL.Lock seen by the bridge.
+	lockMux        sync.Mutex
+	lockKnown      bool
+	lastLock       int
+	lockCount      int
+	unlockCount    int
+	lastUnlockedAt time.Time
+	lockHandler    func(locked bool, at time.Time)
+
+	// lastHealReboot is the last full reboot the bridge triggered to heal
+	// the charger; persisted, since the reboot restarts the bridge too.
+	healRebootMux  sync.Mutex
+	lastHealReboot time.Time
+
+	sessionMux           sync.RWMutex
+	inSession            bool
+	inSessionKnown       bool
+	sessionLastState     string
+	lastSessionEndReason string
+
+	// clockOffset is the charger event timestamp minus the bridge host clock
+	// at the time the event was received, in seconds.
+	clockMux         sync.RWMutex
+	clockOffset      float64
+	clockOffsetKnown bool
+
+	ocppTransaction ocppTransactionTracker
+	ocppHeartbeat   ocppHeartbeatTracker
+
+	eventStatsMux       sync.RWMutex
+	eventsProcessed     int
+	eventParseFailures  map[string]int
+	lastEventParseError string
+	subscribed          bool
+	redisResubscribes   int
+
+	// Backend health: errors talking to Redis/MySQL since start.
+	backendMux         sync.RWMutex
+	redisErrors        int
+	mysqlErrors        int
+	skippedCycles      int
+	lastBackendError   string
+	lastBackendErrorAt time.Time
+	// redisDownSince/mysqlDownSince are set while the backend is failing,
+	// so only the transitions get logged.
+	redisDownSince time.Time
+	mysqlDownSince time.Time
+
+	// MySQL reconnects after failed polls; only RefreshData touches these.
+	mysqlDSN        string
+	mysqlAddr       string
+	mysqlRetryAt    time.Time
+	mysqlRetryDelay time.Duration
+
+	// firmwareVersionKey/Field is the optional Redis fallback of
+	// FirmwareVersion, see RedisConfig.
+	firmwareVersionKey   string
+	firmwareVersionField string
+
+	// unmappedSensors remembers telemetry sensor ids without a field, so
+	// each is only logged once (see SetUnmappedSensorLogging).
+	unmappedMux     sync.Mutex
+	unmappedLogMode string
+	unmappedSensors map[string]bool
+
+	// fallbackCurrent stands in for an unreadable or zero available current.
+	availableCurrentMux     sync.Mutex
+	fallbackCurrent         int
+	availableCurrentUnknown bool
+
+	// Liveness: when the charger last answered a state read and when it
+	// last sent a telemetry event.
+	lastStateAt     time.Time
+	lastTelemetryAt time.Time
+}
+
+// Store keys; the Redis store prefixes them with DefaultStoreKeyPrefix.
+const (
+	contactorCyclesKey = "contactor_cycles"
+	lockCountKey       = "lock_count"
+	unlockCountKey     = "unlock_count"
+	lastUnlockedAtKey  = "last_unlocked_at"
+	lastOCPPStatusKey  = "last_ocpp_status"
+	lastHealRebootKey  = "last_heal_reboot"
+	// disarmedSchedulesKey lists the ids of the schedules the bridge
+	// disarmed, so arming them again restores exactly those.
+	disarmedSchedulesKey = "disarmed_schedules"
+)
+
+const defaultRedisAddr = "localhost:6379"
+
+// MySQLConfig says how to reach the charger's MySQL database. Empty fields
+// take their value from DefaultMySQLConfig.
+type MySQLConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Database string
+}
+
+// DefaultMySQLConfig is the database of a stock charger, seen from the
+// charger itself.
+var DefaultMySQLConfig = MySQLConfig{
+	Host:     "127.0.0.1",
+	Port:     3306,
+	Username: "root",
+	Password: "fJmExsJgmKV7cq8H",
+	Database: "wallbox",
+}
+
+func (c MySQLConfig) withDefaults() MySQLConfig {
+	if c.Host == "" {
+		c.Host = DefaultMySQLConfig.Host
+	}
+	if c.Port == 0 {
+		c.Port = DefaultMySQLConfig.Port
+	}
+	if c.Username == "" {
+		c.Username = DefaultMySQLConfig.Username
+	}
+	if c.Password == "" {
+		c.Password = DefaultMySQLConfig.Password
+	}
+	if c.Database == "" {
+		c.Database = DefaultMySQLConfig.Database
+	}
+	return c
+}
+
+// Addr returns host:port.
+func (c MySQLConfig) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// DSN returns the go-sql-driver data source name for c.
+func (c MySQLConfig) DSN() string {
+	dsn := mysql.NewConfig()
+	dsn.User = c.Username
+	dsn.Passwd = c.Password
+	dsn.Net = "tcp"
+	dsn.Addr = c.Addr()
+	dsn.DBName = c.Database
+	return dsn.FormatDSN()
+}
+
+// RedisConfig says how to reach the charger's Redis. An empty Addr selects
+// the on-device default.
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+
+	// FirmwareVersionKey and FirmwareVersionField name a hash field that
+	// holds the firmware version, for firmware without it in MySQL. No
+	// stock firmware is known to need it, so unset skips the lookup.
+	FirmwareVersionKey   string
+	FirmwareVersionField string
+}
+
+// Config holds the connection settings for NewWithConfig. The zero value
+// connects to the on-device defaults.
+type Config struct {
+	MySQL MySQLConfig
+	Redis RedisConfig
+	// Store persists the bridge's own state; nil keeps it in the charger's
+	// Redis under StoreKeyPrefix (DefaultStoreKeyPrefix if empty).
+	Store          Store
+	StoreKeyPrefix string
 }
 
-func New() *Wallbox {
+// New connects to the on-device MySQL and Redis with their default settings.
+func New() (*Wallbox, error) {
+	return NewWithConfig(Config{})
+}
+
+// NewWithConfig connects to the charger's MySQL and Redis as configured in
+// cfg, using the defaults for anything left empty. It fails if either can't
+// be reached yet, e.g. while they are still starting up during boot.
+func NewWithConfig(cfg Config) (*Wallbox, error) {
 	var w Wallbox
 
+	mysqlConfig := cfg.MySQL.withDefaults()
+	mysqlAddr := mysqlConfig.Addr()
+	redisAddr := cfg.Redis.Addr
+	if redisAddr == "" {
+		redisAddr = defaultRedisAddr
+	}
+
 	var err error
-	w.sqlClient, err = sqlx.Connect("mysql", "root:fJmExsJgmKV7cq8H@tcp(127.0.0.1:3306)/wallbox")
+	w.sqlClient, err = sqlx.Connect("mysql", mysqlConfig.DSN())
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("connecting to MySQL at %s: %w", mysqlAddr, err)
 	}
+	w.mysqlDSN = mysqlConfig.DSN()
+	w.mysqlAddr = mysqlAddr
+	w.firmwareVersionKey = cfg.Redis.FirmwareVersionKey
+	w.firmwareVersionField = cfg.Redis.FirmwareVersionField
 
-	query := "select SUBSTRING_INDEX(part_number, '-', 1) AS charger_type from charger_info;"
-	w.sqlClient.Get(&w, query)
+	w.queries = DefaultQueries
+	if err := w.db().Get(&w, w.queries.ChargerType); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		w.db().Close()
+		return nil, fmt.Errorf("reading charger type: %w", err)
+	}
 
 	w.redisClient = redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
+		Addr:     redisAddr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
 	})
+	if err := w.redisClient.Ping(context.Background()).Err(); err != nil {
+		w.db().Close()
+		w.redisClient.Close()
+		return nil, fmt.Errorf("connecting to Redis at %s: %w", redisAddr, err)
+	}
+	log.Printf("Using MySQL at %s (database %s) and Redis at %s (db %d)", mysqlAddr, mysqlConfig.Database, redisAddr, cfg.Redis.DB)
+
+	w.offDevice = !isOnDeviceEndpoint(mysqlAddr, "3306") || !isOnDeviceEndpoint(redisAddr, "6379")
+	if w.offDevice {
+		log.Printf("MySQL (%s) / Redis (%s) are not the on-device defaults; assuming the bridge runs off-device. "+
+			"Posix-queue controls (lock/unlock on non-CPB1, charging enable) are disabled.", mysqlAddr, redisAddr)
+	}
 
 	w.telemetryOCPPStatus = -1
 	w.journalOCPPStatus = -1
 
+	w.store = cfg.Store
+	if w.store == nil {
+		prefix := cfg.StoreKeyPrefix
+		if prefix == "" {
+			prefix = DefaultStoreKeyPrefix
+		}
+		w.store = NewRedisStore(w.redisClient, prefix)
+	}
+	w.loadPersistedState()
+
+	return &w, nil
+}
+
+// NewStub returns a Wallbox without any MySQL/Redis backing. It is only meant
+// for exercising the MQTT side (e.g. the --self-test mode) without a charger;
+// accessors that need a database must not be called on it.
+func NewStub() *Wallbox {
+	var w Wallbox
+	w.offDevice = true
+	w.queries = DefaultQueries
+	w.store = NewMemoryStore()
+	w.telemetryOCPPStatus = -1
+	w.journalOCPPStatus = -1
 	return &w
 }
 
+// isOnDeviceEndpoint reports whether addr points at a loopback host on the
+// service's standard port. Tunnels usually bind a loopback address on a
+// non-standard port, so both host and port have to match.
+func isOnDeviceEndpoint(addr, defaultPort string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || port != defaultPort {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// OffDevice reports whether the bridge is talking to a charger that is not
+// the host it runs on. Posix queues, journald and systemctl are all local to
+// the charger, so only SQL-backed controls work in that mode.
+func (w *Wallbox) OffDevice() bool {
+	return w.offDevice
+}
+
+// ApplyQueryOverrides replaces the default SQL statements with the non-empty
+// overrides. Each override is executed once and only accepted if it returns
+// the columns the bridge scans; otherwise the default is kept and the
+// problem is logged.
+func (w *Wallbox) ApplyQueryOverrides(overrides Queries) {
+	apply := func(name, query string, expected []string, target *string) {
+		if query == "" {
+			return
+		}
+		if err := w.validateQueryColumns(query, expected); err != nil {
+			log.Printf("Ignoring %s query override, using default: %v", name, err)
+			return
+		}
+		log.Printf("Using %s query override: %s", name, query)
+		*target = query
+	}
+
+	apply("refresh", overrides.Refresh, getDBFields(w.Data.SQL), &w.queries.Refresh)
+	apply("serial_number", overrides.SerialNumber, nil, &w.queries.SerialNumber)
+	apply("firmware_version", overrides.FirmwareVersion, nil, &w.queries.FirmwareVersion)
+	apply("available_current", overrides.AvailableCurrent, nil, &w.queries.AvailableCurrent)
+	apply("schedules", overrides.Schedules, getDBFields(Schedule{}), &w.queries.Schedules)
+	apply("connector_type", overrides.ConnectorType, nil, &w.queries.ConnectorType)
+	apply("lifetime_added_range", overrides.LifetimeAddedRange, nil, &w.queries.LifetimeAddedRange)
+	apply("auto_lock", overrides.AutoLock, getDBFields(autoLockSettings{}), &w.queries.AutoLock)
+	apply("phase_current_limits", overrides.PhaseCurrentLimits, getDBFields(phaseCurrentLimits{}), &w.queries.PhaseCurrentLimits)
+	apply("timezone", overrides.Timezone, nil, &w.queries.Timezone)
+
+	// Writes can't be tried out, but preparing them makes MySQL resolve
+	// their tables and columns. One that doesn't fit this schema stays
+	// disabled, together with the entities using it.
+	applyWrite := func(name, stmt string, target *string, writable *bool) {
+		if stmt != "" {
+			if err := w.validateWriteStatement(stmt); err != nil {
+				log.Printf("Ignoring %s statement override, using default: %v", name, err)
+			} else {
+				log.Printf("Using %s statement override: %s", name, stmt)
+				*target = stmt
+				*writable = true
+				return
+			}
+		}
+		if err := w.validateWriteStatement(*target); err != nil {
+			log.Printf("Disabling %s, the default statement doesn't fit this charger's database: %v", name, err)
+			return
+		}
+		*writable = true
+	}
+	applyWrite("set_ecosmart_mode", overrides.SetEcosmartMode, &w.queries.SetEcosmartMode, &w.ecosmartWritable)
+	applyWrite("set_schedules_enabled", overrides.SetSchedulesEnabled, &w.queries.SetSchedulesEnabled, &w.schedulesWritable)
+	applyWrite("set_auto_lock_enabled", overrides.SetAutoLockEnabled, &w.queries.SetAutoLockEnabled, &w.autoLockEnabledWritable)
+	applyWrite("set_auto_lock_time", overrides.SetAutoLockTime, &w.queries.SetAutoLockTime, &w.autoLockTimeWritable)
+
+	chargerType := w.queries.ChargerType
+	apply("charger_type", overrides.ChargerType, []string{"charger_type"}, &w.queries.ChargerType)
+	if w.queries.ChargerType != chargerType {
+		w.db().Get(w, w.queries.ChargerType)
+	}
+}
+
+// validateWriteStatement prepares stmt without running it, which fails for
+// unknown tables or columns.
+func (w *Wallbox) validateWriteStatement(stmt string) error {
+	db := w.db()
+	if db == nil {
+		return errors.New("no database connection")
+	}
+	prepared, err := db.Preparex(stmt)
+	if err != nil {
+		return err
+	}
+	return prepared.Close()
+}
+
+// validateQueryColumns runs query and checks its result columns. A nil
+// expected list means the query must return exactly one (scalar) column.
+func (w *Wallbox) validateQueryColumns(query string, expected []string) error {
+	rows, err := w.db().Queryx(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	if expected == nil {
+		if len(columns) != 1 {
+			return fmt.Errorf("expected a single column, got %v", columns)
+		}
+		return nil
+	}
+
+	for _, want := range expected {
+		found := false
+		for _, column := range columns {
+			if column == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("missing column %q (got %v)", want, columns)
+		}
+	}
+	return nil
+}
+
+func getDBFields(obj interface{}) []string {
+	var result []string
+	typ := reflect.TypeOf(obj)
+
+	for i := 0; i < typ.NumField(); i++ {
+		result = append(result, typ.Field(i).Tag.Get("db"))
+	}
+
+	return result
+}
+
+// getRedisFields returns the hash fields to HMGET for obj. Fields without a
+// redis tag, tagged "-", or unexported are skipped; an empty name would
+// otherwise be sent to Redis and shift the scanned results.
 func getRedisFields(obj interface{}) []string {
 	var result []string
-	val := reflect.ValueOf(obj)
-	typ := val.Type()
+	typ := reflect.TypeOf(obj)
 
-	for i := 0; i < val.NumField(); i++ {
+	for i := 0; i < typ.NumField(); i++ {
 		field := typ.Field(i)
-		result = append(result, field.Tag.Get("redis"))
+		tag := field.Tag.Get("redis")
+		if tag == "" || tag == "-" || !field.IsExported() {
+			continue
+		}
+		result = append(result, tag)
 	}
 
 	return result
 }
 
-func (w *Wallbox) RefreshData() {
-	ctx := context.Background()
+// hmgetInto reads the redis-tagged fields of dst (a pointer to struct) from the
+// given hash.
+func (w *Wallbox) hmgetInto(ctx context.Context, key string, dst interface{}) error {
+	fields := getRedisFields(reflect.ValueOf(dst).Elem().Interface())
 
-	stateRes := w.redisClient.HMGet(ctx, "state", getRedisFields(w.Data.RedisState)...)
-	if stateRes.Err() != nil {
-		panic(stateRes.Err())
+	res := w.redisClient.HMGet(ctx, key, fields...)
+	if res.Err() != nil {
+		return res.Err()
 	}
+	if got := len(res.Val()); got != len(fields) {
+		return fmt.Errorf("HMGET %s returned %d values for %d fields", key, got, len(fields))
+	}
+
+	return res.Scan(dst)
+}
+
+// RefreshData reloads the polled Redis and MySQL state. On error the
+// previous data is kept and the failure is counted for the backend health
+// sensors.
+func (w *Wallbox) RefreshData() error {
+	ctx := context.Background()
 
-	if err := stateRes.Scan(&w.Data.RedisState); err != nil {
-		panic(err)
+	if err := w.hmgetInto(ctx, "state", &w.Data.RedisState); err != nil {
+		return w.recordBackendError("redis", err)
 	}
 
-	m2wRes := w.redisClient.HMGet(ctx, "m2w", getRedisFields(w.Data.RedisM2W)...)
-	if m2wRes.Err() != nil {
-		panic(m2wRes.Err())
+	if err := w.hmgetInto(ctx, "m2w", &w.Data.RedisM2W); err != nil {
+		return w.recordBackendError("redis", err)
 	}
+	w.recordBackendOK("redis")
 
-	if err := m2wRes.Scan(&w.Data.RedisM2W); err != nil {
-		panic(err)
+	if err := w.db().Get(&w.Data.SQL, w.queries.Refresh); err != nil {
+		if isConnectionError(err) {
+			w.reconnectMySQL(time.Now())
+		}
+		return w.recordBackendError("mysql", err)
+	}
+	w.recordBackendOK("mysql")
+	w.markSeen(&w.lastStateAt, time.Now())
+	w.activeSessionIDKnown = true
+	w.trackLockTransition(w.Data.SQL.Lock, time.Now())
+	w.trackEfficiencyBaseline()
+	w.trackCarConnected(time.Now())
+
+	// Not every firmware has a schedules table; keep the last good list.
+	var schedules []Schedule
+	if err := w.db().Select(&schedules, w.queries.Schedules); err == nil {
+		w.schedulesMux.Lock()
+		w.schedules = schedules
+		w.schedulesMux.Unlock()
+		w.schedulesKnown = true
+	}
+
+	var timezone string
+	if err := w.db().Get(&timezone, w.queries.Timezone); err == nil {
+		w.setTimezone(timezone)
+	}
+
+	// Per-phase limits need firmware with one column per phase. No stock
+	// firmware is known to have them, so stop asking once the schema says no.
+	if !w.phaseLimitsMissing {
+		var phaseLimits phaseCurrentLimits
+		if err := w.db().Get(&phaseLimits, w.queries.PhaseCurrentLimits); err == nil {
+			w.phaseLimits = phaseLimits
+			w.phaseLimitsSupported = true
+		} else if isSchemaError(err) {
+			log.Printf("Per-phase current limits unavailable, no longer querying them: %v", err)
+			w.phaseLimitsMissing = true
+		}
 	}
 
-	query := "SELECT " +
-		"  `wallbox_config`.`charging_enable`," +
-		"  `wallbox_config`.`lock`," +
-		"  `wallbox_config`.`max_charging_current`," +
-		"  `wallbox_config`.`halo_brightness`," +
-		"  `power_outage_values`.`charged_energy` AS cumulative_added_energy," +
-		"  IF(`active_session`.`unique_id` != 0," +
-		"    `active_session`.`charged_range`," +
-		"    `latest_session`.`charged_range`) AS added_range," +
-		"  IF(`active_session`.`unique_id` != 0," +
-		"    `active_session`.`energy_total`," +
-		"    0) AS active_session_energy_total " +
-		"FROM `wallbox_config`," +
-		"    `active_session`," +
-		"    `power_outage_values`," +
-		"    (SELECT * FROM `session` ORDER BY `id` DESC LIMIT 1) AS latest_session"
-	w.sqlClient.Get(&w.Data.SQL, query)
+	// Auto-lock columns only exist on some models.
+	var autoLock autoLockSettings
+	if err := w.db().Get(&autoLock, w.queries.AutoLock); err == nil {
+		w.autoLock = autoLock
+		w.autoLockSupported = true
+	}
 
 	// We no longer need to refresh telemetry data from Redis
 	// The telemetry data comes directly from Redis subscriptions and is stored only in memory
+	return nil
+}
+
+// lifetimeRangeMaxAge limits how often the session history is summed.
+const lifetimeRangeMaxAge = 5 * time.Minute
+
+// LifetimeAddedRange returns the range added over all recorded sessions. An
+// empty history is 0; ok is false until the history could be read once, and
+// the last good value is kept if a later read fails.
+func (w *Wallbox) LifetimeAddedRange() (km float64, ok bool) {
+	if w.db() == nil {
+		return 0, false
+	}
+	if w.lifetimeRangeKnown && time.Since(w.lifetimeRangeAt) < lifetimeRangeMaxAge {
+		return w.lifetimeRange, true
+	}
+
+	var total float64
+	if err := w.db().Get(&total, w.queries.LifetimeAddedRange); err != nil {
+		if w.lifetimeRangeKnown {
+			return w.lifetimeRange, true
+		}
+		return 0, false
+	}
+	w.lifetimeRange = total
+	w.lifetimeRangeKnown = true
+	w.lifetimeRangeAt = time.Now()
+	return total, true
 }
 
 func (w *Wallbox) SerialNumber() string {
 	var serialNumber string
-	w.sqlClient.Get(&serialNumber, "SELECT `serial_num` FROM charger_info")
+	w.db().Get(&serialNumber, w.queries.SerialNumber)
 	return serialNumber
 }
 
-func (w *Wallbox) FirmwareVersion() string {
-	var firmware string
-	err := w.sqlClient.Get(&firmware, "SELECT `version` FROM `wallbox_version` ORDER BY `id` DESC LIMIT 1")
-	if err == nil && firmware != "" {
-		return firmware
+// ActiveSessionID returns active_session.unique_id, which is 0 while no
+// session is running, or "unknown" if it could not be read yet.
+func (w *Wallbox) ActiveSessionID() string {
+	if !w.activeSessionIDKnown {
+		return "unknown"
+	}
+	return strconv.FormatInt(w.Data.SQL.ActiveSessionID, 10)
+}
+
+// setTimezone switches to the named IANA zone (e.g. "Europe/Madrid"). An
+// unknown name is logged once and keeps the previous location.
+func (w *Wallbox) setTimezone(name string) {
+	name = strings.TrimSpace(name)
+	if name == "" || (w.location != nil && w.location.String() == name) {
+		return
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		if w.location == nil {
+			log.Printf("Ignoring charger timezone %q: %v", name, err)
+			w.location = time.Local
+		}
+		return
+	}
+	w.location = loc
+}
+
+// Location returns the charger's configured timezone, or the bridge host's
+// when the charger doesn't report one. Schedules are evaluated in it.
+func (w *Wallbox) Location() *time.Location {
+	if w.location == nil {
+		return time.Local
+	}
+	return w.location
+}
+
+// Timezone returns the name of Location, e.g. "Europe/Madrid". For the
+// bridge host's zone, which has no portable name, the current abbreviation
+// (e.g. "CET") is returned instead.
+func (w *Wallbox) Timezone() string {
+	loc := w.Location()
+	if loc == time.Local {
+		name, _ := time.Now().Zone()
+		return name
+	}
+	return loc.String()
+}
+
+// ConnectorType returns the charger's connector/socket type (e.g. tethered
+// cable vs Type 2 socket) from charger_info, or "unknown" if the firmware does
+// not record it. It never changes, so the first answer is cached.
+func (w *Wallbox) ConnectorType() string {
+	if w.connectorType != "" {
+		return w.connectorType
+	}
+	if w.db() == nil {
+		return "unknown"
 	}
 
-	var fallback string
-	err = w.sqlClient.Get(&fallback, "SELECT `software_version` FROM `charger_info` LIMIT 1")
-	if err == nil && fallback != "" {
-		return fallback
+	var connectorType string
+	if err := w.db().Get(&connectorType, w.queries.ConnectorType); err != nil || strings.TrimSpace(connectorType) == "" {
+		w.connectorType = "unknown"
+	} else {
+		w.connectorType = strings.TrimSpace(connectorType)
 	}
+	return w.connectorType
+}
 
+// FirmwareVersion returns the charger firmware version from wallbox_version,
+// then charger_info, then the configured Redis hash field, or "unknown" if
+// none of them has it.
+func (w *Wallbox) FirmwareVersion() string {
+	return firstFirmwareVersion(
+		func() (string, error) {
+			var firmware string
+			err := w.db().Get(&firmware, w.queries.FirmwareVersion)
+			return firmware, err
+		},
+		func() (string, error) {
+			var firmware string
+			err := w.db().Get(&firmware, "SELECT `software_version` FROM `charger_info` LIMIT 1")
+			return firmware, err
+		},
+		func() (string, error) {
+			if w.firmwareVersionKey == "" || w.firmwareVersionField == "" {
+				return "", nil
+			}
+			return w.redisClient.HGet(context.Background(), w.firmwareVersionKey, w.firmwareVersionField).Result()
+		},
+	)
+}
+
+// firstFirmwareVersion returns the first non-empty version from sources,
+// tried in order, or "unknown".
+func firstFirmwareVersion(sources ...func() (string, error)) string {
+	for _, source := range sources {
+		if version, err := source(); err == nil && strings.TrimSpace(version) != "" {
+			return strings.TrimSpace(version)
+		}
+	}
 	return "unknown"
 }
 
 func (w *Wallbox) UserId() string {
 	var userId string
-	w.sqlClient.QueryRow("SELECT `user_id` FROM `users` WHERE `user_id` != 1 ORDER BY `user_id` DESC LIMIT 1").Scan(&userId)
+	w.db().QueryRow("SELECT `user_id` FROM `users` WHERE `user_id` != 1 ORDER BY `user_id` DESC LIMIT 1").Scan(&userId)
 	return userId
 }
 
+// DefaultAvailableCurrent is the ceiling (A) used when the installation's
+// available current can't be read; see SetFallbackAvailableCurrent.
+const DefaultAvailableCurrent = 32
+
+// SetFallbackAvailableCurrent sets the ceiling AvailableCurrent reports when
+// the charger's own value is missing or 0. Values <= 0 select
+// DefaultAvailableCurrent.
+func (w *Wallbox) SetFallbackAvailableCurrent(current int) {
+	if current <= 0 {
+		current = DefaultAvailableCurrent
+	}
+	w.availableCurrentMux.Lock()
+	w.fallbackCurrent = current
+	w.availableCurrentMux.Unlock()
+}
+
+// AvailableCurrent returns the installation's available current. A fresh
+// database or a failed read yields 0, which would clamp every setter and the
+// discovery max to nothing, so the fallback ceiling is used instead.
 func (w *Wallbox) AvailableCurrent() int {
+	if w.db() == nil {
+		// Stubbed Wallbox (self-test): assume a typical 32 A installation.
+		return DefaultAvailableCurrent
+	}
 	var availableCurrent int
-	w.sqlClient.QueryRow("SELECT `max_avbl_current` FROM `state_values` ORDER BY `id` DESC LIMIT 1").Scan(&availableCurrent)
-	return availableCurrent
+	err := w.db().QueryRow(w.queries.AvailableCurrent).Scan(&availableCurrent)
+	return w.availableCurrentOrFallback(availableCurrent, err)
+}
+
+// availableCurrentOrFallback substitutes the fallback ceiling for an unknown
+// or zero available current, logging once per change between the two.
+func (w *Wallbox) availableCurrentOrFallback(current int, err error) int {
+	w.availableCurrentMux.Lock()
+	defer w.availableCurrentMux.Unlock()
+
+	fallback := w.fallbackCurrent
+	if fallback <= 0 {
+		fallback = DefaultAvailableCurrent
+	}
+
+	if err == nil && current > 0 {
+		if w.availableCurrentUnknown {
+			log.Printf("Available current is %d A again", current)
+			w.availableCurrentUnknown = false
+		}
+		return current
+	}
+
+	if !w.availableCurrentUnknown {
+		if err != nil {
+			log.Printf("Could not determine available current (%v); using %d A", err, fallback)
+		} else {
+			log.Printf("Charger reports 0 A available current; using %d A", fallback)
+		}
+		w.availableCurrentUnknown = true
+	}
+	return fallback
 }
 
-// ChargingCurrentL1 returns the phase 1 charging current. On newer firmware
+// rawChargingCurrentL1 returns the phase 1 charging current. On newer firmware
 // this is sourced from telemetry events; on older firmware it falls back to
 // the legacy m2w Redis hash.
-func (w *Wallbox) ChargingCurrentL1() float64 {
+func (w *Wallbox) rawChargingCurrentL1() float64 {
 	if w.HasTelemetry && w.Data.RedisTelemetry.InternalMeterCurrentL1 != 0 {
 		return w.Data.RedisTelemetry.InternalMeterCurrentL1
 	}
 	return w.Data.RedisM2W.Line1Current
 }
 
-// ChargingCurrentL2 returns the phase 2 charging current, using telemetry when
+// rawChargingCurrentL2 returns the phase 2 charging current, using telemetry when
 // available and falling back to the legacy m2w Redis hash otherwise.
-func (w *Wallbox) ChargingCurrentL2() float64 {
+func (w *Wallbox) rawChargingCurrentL2() float64 {
 	if w.HasTelemetry && w.Data.RedisTelemetry.InternalMeterCurrentL2 != 0 {
 		return w.Data.RedisTelemetry.InternalMeterCurrentL2
 	}
 	return w.Data.RedisM2W.Line2Current
 }
 
-// ChargingCurrentL3 returns the phase 3 charging current, using telemetry when
+// rawChargingCurrentL3 returns the phase 3 charging current, using telemetry when
 // available and falling back to the legacy m2w Redis hash otherwise.
-func (w *Wallbox) ChargingCurrentL3() float64 {
+func (w *Wallbox) rawChargingCurrentL3() float64 {
 	if w.HasTelemetry && w.Data.RedisTelemetry.InternalMeterCurrentL3 != 0 {
 		return w.Data.RedisTelemetry.InternalMeterCurrentL3
 	}
 	return w.Data.RedisM2W.Line3Current
 }
 
+// ChargingVoltageL1 returns the phase 1 voltage, using telemetry when
+// available. The legacy m2w hash has no voltage, so older firmware derives it
+// from line power and current, which only works while current flows.
+func (w *Wallbox) ChargingVoltageL1() float64 {
+	return w.chargingVoltage(w.Data.RedisTelemetry.InternalMeterVoltageL1, w.Data.RedisM2W.Line1Power, w.Data.RedisM2W.Line1Current)
+}
+
+// ChargingVoltageL2 returns the phase 2 voltage. See ChargingVoltageL1.
+func (w *Wallbox) ChargingVoltageL2() float64 {
+	return w.chargingVoltage(w.Data.RedisTelemetry.InternalMeterVoltageL2, w.Data.RedisM2W.Line2Power, w.Data.RedisM2W.Line2Current)
+}
+
+// ChargingVoltageL3 returns the phase 3 voltage. See ChargingVoltageL1.
+func (w *Wallbox) ChargingVoltageL3() float64 {
+	return w.chargingVoltage(w.Data.RedisTelemetry.InternalMeterVoltageL3, w.Data.RedisM2W.Line3Power, w.Data.RedisM2W.Line3Current)
+}
+
+func (w *Wallbox) chargingVoltage(telemetry, legacyPower, legacyCurrent float64) float64 {
+	if w.HasTelemetry && telemetry != 0 {
+		return telemetry
+	}
+	if legacyCurrent == 0 {
+		return 0
+	}
+	return legacyPower / legacyCurrent
+}
+
+// GridFrequency returns the mains frequency from the internal meter, or from
+// the DCA meter when the internal one reads nothing. Both read 0 on some
+// firmware while idle; 0 means unknown.
+func (w *Wallbox) GridFrequency() float64 {
+	if !w.HasTelemetry {
+		return 0
+	}
+	if f := w.Data.RedisTelemetry.InternalMeterFrequency; f != 0 {
+		return f
+	}
+	return w.Data.RedisTelemetry.DCAMeterFrequency
+}
+
 // linePowerFromTelemetry derives per‑phase power from internal meter voltage
 // and current telemetry values. This is primarily used on newer firmware where
 // legacy m2w per‑phase power may no longer be populated.
@@ -443,10 +1218,10 @@ func linePowerFromTelemetry(voltage, current float64) float64 {
 	return voltage * current
 }
 
-// ChargingPowerL1 returns per‑phase power for L1. On newer firmware we derive
+// rawChargingPowerL1 returns per‑phase power for L1. On newer firmware we derive
 // this from internal meter telemetry, otherwise we fall back to legacy m2w
 // power values.
-func (w *Wallbox) ChargingPowerL1() float64 {
+func (w *Wallbox) rawChargingPowerL1() float64 {
 	if w.HasTelemetry &&
 		(w.Data.RedisTelemetry.InternalMeterVoltageL1 != 0 ||
 			w.Data.RedisTelemetry.InternalMeterCurrentL1 != 0) {
@@ -458,9 +1233,9 @@ func (w *Wallbox) ChargingPowerL1() float64 {
 	return w.Data.RedisM2W.Line1Power
 }
 
-// ChargingPowerL2 returns per‑phase power for L2. See ChargingPowerL1 for
+// rawChargingPowerL2 returns per‑phase power for L2. See rawChargingPowerL1 for
 // details.
-func (w *Wallbox) ChargingPowerL2() float64 {
+func (w *Wallbox) rawChargingPowerL2() float64 {
 	if w.HasTelemetry &&
 		(w.Data.RedisTelemetry.InternalMeterVoltageL2 != 0 ||
 			w.Data.RedisTelemetry.InternalMeterCurrentL2 != 0) {
@@ -472,9 +1247,9 @@ func (w *Wallbox) ChargingPowerL2() float64 {
 	return w.Data.RedisM2W.Line2Power
 }
 
-// ChargingPowerL3 returns per‑phase power for L3. See ChargingPowerL1 for
+// rawChargingPowerL3 returns per‑phase power for L3. See rawChargingPowerL1 for
 // details.
-func (w *Wallbox) ChargingPowerL3() float64 {
+func (w *Wallbox) rawChargingPowerL3() float64 {
 	if w.HasTelemetry &&
 		(w.Data.RedisTelemetry.InternalMeterVoltageL3 != 0 ||
 			w.Data.RedisTelemetry.InternalMeterCurrentL3 != 0) {
@@ -486,9 +1261,85 @@ func (w *Wallbox) ChargingPowerL3() float64 {
 	return w.Data.RedisM2W.Line3Power
 }
 
-// ChargingPower returns total charging power across all phases.
+// RawChargingPower returns the total measured power across all phases,
+// without the idle power floor applied.
+func (w *Wallbox) RawChargingPower() float64 {
+	return w.rawChargingPowerL1() + w.rawChargingPowerL2() + w.rawChargingPowerL3()
+}
+
+// SetIdlePowerFloor sets the power (W) below which power and current readings
+// are reported as 0 while the pilot is not in a charging state. 0 disables it.
+func (w *Wallbox) SetIdlePowerFloor(floor float64) {
+	w.idlePowerFloor = floor
+}
+
+// phantomReading reports whether the current power/current readings are
+// meter noise: not charging according to the pilot and below the idle floor.
+func (w *Wallbox) phantomReading() bool {
+	return isPhantomPower(w.idlePowerFloor, w.IsChargingPilot(), w.RawChargingPower())
+}
+
+func isPhantomPower(floor float64, pilotCharging bool, power float64) bool {
+	return floor > 0 && !pilotCharging && math.Abs(power) < floor
+}
+
+func (w *Wallbox) floored(value float64) float64 {
+	if w.phantomReading() {
+		return 0
+	}
+	return value
+}
+
+// ChargingCurrentL1 returns the phase 1 charging current with the idle power
+// floor applied.
+func (w *Wallbox) ChargingCurrentL1() float64 { return w.floored(w.rawChargingCurrentL1()) }
+
+// ChargingCurrentL2 returns the phase 2 charging current. See ChargingCurrentL1.
+func (w *Wallbox) ChargingCurrentL2() float64 { return w.floored(w.rawChargingCurrentL2()) }
+
+// ChargingCurrentL3 returns the phase 3 charging current. See ChargingCurrentL1.
+func (w *Wallbox) ChargingCurrentL3() float64 { return w.floored(w.rawChargingCurrentL3()) }
+
+// ChargingPowerL1 returns the phase 1 charging power with the idle power floor
+// applied.
+func (w *Wallbox) ChargingPowerL1() float64 { return w.floored(w.rawChargingPowerL1()) }
+
+// ChargingPowerL2 returns the phase 2 charging power. See ChargingPowerL1.
+func (w *Wallbox) ChargingPowerL2() float64 { return w.floored(w.rawChargingPowerL2()) }
+
+// ChargingPowerL3 returns the phase 3 charging power. See ChargingPowerL1.
+func (w *Wallbox) ChargingPowerL3() float64 { return w.floored(w.rawChargingPowerL3()) }
+
+// ChargingPower returns total charging power across all phases, reported as 0
+// while idle readings stay below the idle power floor.
 func (w *Wallbox) ChargingPower() float64 {
-	return w.ChargingPowerL1() + w.ChargingPowerL2() + w.ChargingPowerL3()
+	return w.floored(w.RawChargingPower())
+}
+
+// EnergyL1 returns the lifetime phase 1 energy counter. Only firmware that
+// emits per-phase internal meter energy populates it; everything else reports
+// only the total SENSOR_INTERNAL_METER_ENERGY and this stays 0.
+func (w *Wallbox) EnergyL1() float64 {
+	return w.Data.RedisTelemetry.InternalMeterEnergyL1
+}
+
+// EnergyL2 returns the lifetime phase 2 energy counter. See EnergyL1.
+func (w *Wallbox) EnergyL2() float64 {
+	return w.Data.RedisTelemetry.InternalMeterEnergyL2
+}
+
+// EnergyL3 returns the lifetime phase 3 energy counter. See EnergyL1.
+func (w *Wallbox) EnergyL3() float64 {
+	return w.Data.RedisTelemetry.InternalMeterEnergyL3
+}
+
+// HasPhaseEnergy reports whether telemetry has delivered any per-phase
+// energy counter so far.
+func (w *Wallbox) HasPhaseEnergy() bool {
+	return w.HasTelemetry &&
+		(w.Data.RedisTelemetry.InternalMeterEnergyL1 != 0 ||
+			w.Data.RedisTelemetry.InternalMeterEnergyL2 != 0 ||
+			w.Data.RedisTelemetry.InternalMeterEnergyL3 != 0)
 }
 
 // TemperatureL1 returns the line 1 temperature, preferring telemetry values
@@ -518,50 +1369,221 @@ func (w *Wallbox) TemperatureL3() float64 {
 	return w.Data.RedisM2W.TempL3
 }
 
-func sendToPosixQueue(path, data string) {
+// phaseTemperatures returns the non-zero per-phase temperatures; single-phase
+// units report 0 on the unused lines.
+func (w *Wallbox) phaseTemperatures() []float64 {
+	var temps []float64
+	for _, t := range []float64{w.TemperatureL1(), w.TemperatureL2(), w.TemperatureL3()} {
+		if t != 0 {
+			temps = append(temps, t)
+		}
+	}
+	return temps
+}
+
+// Temperature returns the hottest of the per-phase temperatures, the most
+// conservative single figure for alerting.
+func (w *Wallbox) Temperature() float64 {
+	temps := w.phaseTemperatures()
+	if len(temps) == 0 {
+		return 0
+	}
+	max := temps[0]
+	for _, t := range temps[1:] {
+		if t > max {
+			max = t
+		}
+	}
+	return max
+}
+
+// AverageTemperature returns the mean of the per-phase temperatures.
+func (w *Wallbox) AverageTemperature() float64 {
+	temps := w.phaseTemperatures()
+	if len(temps) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, t := range temps {
+		sum += t
+	}
+	return sum / float64(len(temps))
+}
+
+// Posix queues the charger's own services listen on for remote control.
+const (
+	loginQueue        = "WALLBOX_MYWALLBOX_WALLBOX_LOGIN"
+	stateMachineQueue = "WALLBOX_MYWALLBOX_WALLBOX_STATEMACHINE"
+)
+
+func sendToPosixQueue(path, data string) error {
 	pathBytes := append([]byte(path), 0)
-	mq := mqOpen(pathBytes)
+	mq, err := mqOpen(pathBytes)
+	if err != nil {
+		return fmt.Errorf("opening posix queue %s: %w", path, err)
+	}
+	defer mqClose(mq)
 
 	event := []byte(data)
 	eventPaddedBytes := append(event, bytes.Repeat([]byte{0x00}, 1024-len(event))...)
 
-	mqTimedsend(mq, eventPaddedBytes)
+	if err := mqTimedsend(mq, eventPaddedBytes); err != nil {
+		return fmt.Errorf("sending to posix queue %s: %w", path, err)
+	}
+	return nil
+}
+
+// posixQueueExists reports whether the named queue can be opened.
+func posixQueueExists(path string) bool {
+	mq, err := mqOpen(append([]byte(path), 0))
+	if err != nil {
+		return false
+	}
 	mqClose(mq)
+	return true
+}
+
+// RemoteControlAvailable reports whether the posix queues used for lock and
+// charging control exist. Some firmware lacks them, in which case those
+// controls cannot work.
+func (w *Wallbox) RemoteControlAvailable() bool {
+	if w.offDevice {
+		return false
+	}
+	return posixQueueExists(loginQueue) && posixQueueExists(stateMachineQueue)
 }
 
 func (w *Wallbox) SetLocked(lock int) {
-	w.RefreshData()
+	if err := w.RefreshData(); err != nil {
+		log.Printf("Ignoring lock=%d: cannot refresh charger state: %v", lock, err)
+		return
+	}
 	if lock == w.Data.SQL.Lock {
 		return
 	}
 	if w.ChargerType == "CPB1" {
-		w.sqlClient.MustExec("UPDATE `wallbox_config` SET `lock`=?", lock)
+		w.db().MustExec("UPDATE `wallbox_config` SET `lock`=?", lock)
+	} else if w.offDevice {
+		log.Printf("Ignoring lock=%d: posix-queue lock control is unavailable off-device", lock)
 	} else if lock == 1 {
-		sendToPosixQueue("WALLBOX_MYWALLBOX_WALLBOX_LOGIN", "EVENT_REQUEST_LOCK")
+		if err := sendToPosixQueue(loginQueue, "EVENT_REQUEST_LOCK"); err != nil {
+			log.Printf("Cannot lock: remote control is unavailable on this firmware: %v", err)
+		}
 	} else {
 		userId := w.UserId()
-		sendToPosixQueue("WALLBOX_MYWALLBOX_WALLBOX_LOGIN", "EVENT_REQUEST_LOGIN#"+userId+".000000")
+		if err := sendToPosixQueue(loginQueue, "EVENT_REQUEST_LOGIN#"+userId+".000000"); err != nil {
+			log.Printf("Cannot unlock: remote control is unavailable on this firmware: %v", err)
+		}
 	}
 }
 
 func (w *Wallbox) SetChargingEnable(enable int) {
-	w.RefreshData()
+	if err := w.RefreshData(); err != nil {
+		log.Printf("Ignoring charging_enable=%d: cannot refresh charger state: %v", enable, err)
+		return
+	}
 	if enable == w.Data.SQL.ChargingEnable {
 		return
 	}
+	if w.offDevice {
+		log.Printf("Ignoring charging_enable=%d: posix-queue control is unavailable off-device", enable)
+		return
+	}
+	action := "EVENT_REQUEST_USER_ACTION#2.000000"
 	if enable == 1 {
-		sendToPosixQueue("WALLBOX_MYWALLBOX_WALLBOX_STATEMACHINE", "EVENT_REQUEST_USER_ACTION#1.000000")
-	} else {
-		sendToPosixQueue("WALLBOX_MYWALLBOX_WALLBOX_STATEMACHINE", "EVENT_REQUEST_USER_ACTION#2.000000")
+		action = "EVENT_REQUEST_USER_ACTION#1.000000"
+	}
+	if err := sendToPosixQueue(stateMachineQueue, action); err != nil {
+		log.Printf("Cannot set charging_enable=%d: remote control is unavailable on this firmware: %v", enable, err)
 	}
 }
 
+// MaxChargingCurrent returns the charger's configured maximum current in A
+// as of the last RefreshData.
+func (w *Wallbox) MaxChargingCurrent() int {
+	return w.Data.SQL.MaxChargingCurrent
+}
+
 func (w *Wallbox) SetMaxChargingCurrent(current int) {
-	w.sqlClient.MustExec("UPDATE `wallbox_config` SET `max_charging_current`=?", current)
+	w.db().MustExec("UPDATE `wallbox_config` SET `max_charging_current`=?", current)
+}
+
+// MinChargingCurrent is the lowest current (A) IEC 61851 allows a charger
+// to offer.
+const MinChargingCurrent = 6
+
+// phaseCurrentLimits are the per-phase current limits as returned by the
+// phase_current_limits query.
+type phaseCurrentLimits struct {
+	L1 int `db:"max_charging_current_l1"`
+	L2 int `db:"max_charging_current_l2"`
+	L3 int `db:"max_charging_current_l3"`
+}
+
+// phaseCurrentColumns are the wallbox_config columns of the phase limits.
+var phaseCurrentColumns = [...]string{"max_charging_current_l1", "max_charging_current_l2", "max_charging_current_l3"}
+
+// PhaseCurrentLimitSupported reports whether the firmware exposes a current
+// limit per phase.
+func (w *Wallbox) PhaseCurrentLimitSupported() bool {
+	return w.phaseLimitsSupported
+}
+
+// MaxChargingCurrentPhase returns the current limit of phase 1-3, or 0 if
+// unsupported.
+func (w *Wallbox) MaxChargingCurrentPhase(phase int) int {
+	switch phase {
+	case 1:
+		return w.phaseLimits.L1
+	case 2:
+		return w.phaseLimits.L2
+	case 3:
+		return w.phaseLimits.L3
+	}
+	return 0
+}
+
+// SetMaxChargingCurrentPhase limits phase 1-3 to current, clamped to
+// MinChargingCurrent..AvailableCurrent. It is a logged no-op on firmware
+// without per-phase limits.
+func (w *Wallbox) SetMaxChargingCurrentPhase(phase, current int) error {
+	column, err := phaseCurrentColumn(phase)
+	if err != nil {
+		return err
+	}
+	if !w.phaseLimitsSupported {
+		log.Printf("Ignoring L%d current limit of %d A: this firmware has no per-phase limits", phase, current)
+		return nil
+	}
+	clamped := clampChargingCurrent(current, w.AvailableCurrent())
+	if clamped != current {
+		log.Printf("Clamping L%d current limit of %d A to %d A", phase, current, clamped)
+	}
+	_, err = w.db().Exec("UPDATE `wallbox_config` SET `"+column+"`=?", clamped)
+	return err
+}
+
+func phaseCurrentColumn(phase int) (string, error) {
+	if phase < 1 || phase > len(phaseCurrentColumns) {
+		return "", fmt.Errorf("invalid phase %d, expected 1-3", phase)
+	}
+	return phaseCurrentColumns[phase-1], nil
+}
+
+// clampChargingCurrent keeps current within MinChargingCurrent..available.
+// An unknown (0) available current only applies the lower bound.
+func clampChargingCurrent(current, available int) int {
+	if available > 0 && current > available {
+		current = available
+	}
+	if current < MinChargingCurrent {
+		current = MinChargingCurrent
+	}
+	return current
 }
 
 func (w *Wallbox) SetHaloBrightness(brightness int) {
-	w.sqlClient.MustExec("UPDATE `wallbox_config` SET `halo_brightness`=?", brightness)
+	w.db().MustExec("UPDATE `wallbox_config` SET `halo_brightness`=?", brightness)
 }
 
 func (w *Wallbox) CableConnected() int {
@@ -598,6 +1620,32 @@ func (w *Wallbox) EffectiveStatus() string {
 	return "Unknown"
 }
 
+// Updating reports whether the charger is installing a firmware update,
+// either according to its state machine or because the software update
+// service reports one of the configured busy states.
+func (w *Wallbox) Updating() bool {
+	if w.EffectiveStatus() == "Updating" {
+		return true
+	}
+	if !w.HasTelemetry {
+		return false
+	}
+	state := int(w.Data.RedisTelemetry.SoftwareUpdateSimpleState)
+	for _, busy := range w.updateBusyStates {
+		if state == busy {
+			return true
+		}
+	}
+	return false
+}
+
+// SetUpdateBusyStates sets the SENSOR_SOFTWARE_UPDATE_SIMPLE_STATE values
+// that mean an update is in progress. Their meaning differs between firmware
+// versions, so none are assumed by default.
+func (w *Wallbox) SetUpdateBusyStates(states []int) {
+	w.updateBusyStates = states
+}
+
 func (w *Wallbox) ControlPilotStatus() string {
 	if w.HasTelemetry && w.Data.RedisTelemetry.ControlPilotStatus != 0 {
 		status := int(w.Data.RedisTelemetry.ControlPilotStatus)
@@ -632,16 +1680,124 @@ func (w *Wallbox) IsChargingPilot() bool {
 	return isTelemetryCharging(w.ControlPilotCode())
 }
 
-func (w *Wallbox) OCPPStatusCode() int {
-	if code, ok := w.getJournalOCPPStatus(); ok {
-		return code
+// Charging detection modes for IsCharging.
+const (
+	ChargingModePilot         = "pilot"
+	ChargingModePower         = "power"
+	ChargingModePilotAndPower = "pilot_and_power"
+	ChargingModePilotOrPower  = "pilot_or_power"
+)
+
+// SetChargingDetection configures how IsCharging decides the charger is
+// charging. Unknown modes fall back to pilot-based detection.
+func (w *Wallbox) SetChargingDetection(mode string, powerThreshold float64) {
+	switch mode {
+	case ChargingModePilot, ChargingModePower, ChargingModePilotAndPower, ChargingModePilotOrPower:
+	default:
+		log.Printf("Unknown charging mode %q, using %q", mode, ChargingModePilot)
+		mode = ChargingModePilot
+	}
+	w.chargingMode = mode
+	w.chargingPowerThreshold = powerThreshold
+}
+
+// IsCharging reports whether the charger is charging according to the
+// configured detection mode. A car can sit in pilot state C at 0 A for a
+// while, so power-based modes require a real draw above the threshold.
+func (w *Wallbox) IsCharging() bool {
+	return chargingDetected(w.chargingMode, w.IsChargingPilot(), w.ChargingPower(), w.chargingPowerThreshold)
+}
+
+func chargingDetected(mode string, pilotCharging bool, power, threshold float64) bool {
+	drawing := power > threshold
+	switch mode {
+	case ChargingModePower:
+		return drawing
+	case ChargingModePilotAndPower:
+		return pilotCharging && drawing
+	case ChargingModePilotOrPower:
+		return pilotCharging || drawing
+	default:
+		return pilotCharging
+	}
+}
+
+// Precedence modes for OCPPStatusCode when the journal and the session
+// events both have a recent OCPP status.
+const (
+	OCPPPrecedenceJournal = "journal" // journal StatusNotification first (default)
+	OCPPPrecedenceSession = "session" // session events first
+	OCPPPrecedenceNewest  = "newest"  // whichever was updated last
+)
+
+// SetOCPPPrecedence picks how OCPPStatusCode resolves the journal and
+// session sources; unknown modes fall back to OCPPPrecedenceJournal.
+func (w *Wallbox) SetOCPPPrecedence(mode string) {
+	switch mode {
+	case OCPPPrecedenceSession, OCPPPrecedenceNewest:
+		w.ocppPrecedence = mode
+	default:
+		w.ocppPrecedence = OCPPPrecedenceJournal
 	}
-	if code, ok := w.getTelemetryOCPPStatus(); ok {
+}
+
+func (w *Wallbox) OCPPStatusCode() int {
+	w.ocppStatusMux.RLock()
+	journal := ocppSample{w.journalOCPPStatus, w.journalOCPPUpdated}
+	session := ocppSample{w.telemetryOCPPStatus, w.telemetryOCPPUpdated}
+	w.ocppStatusMux.RUnlock()
+
+	if code, ok := pickOCPPStatus(w.ocppPrecedence, journal, session, time.Now()); ok {
 		return code
 	}
 	return int(w.Data.RedisTelemetry.OCPPStatus)
 }
 
+// JournalOCPPStatusCode returns the OCPP status last seen in the journal,
+// ok is false if there is none from the last ocppStatusMaxAge.
+func (w *Wallbox) JournalOCPPStatusCode() (int, bool) {
+	return w.getJournalOCPPStatus()
+}
+
+// SessionOCPPStatusCode returns the OCPP status from the last session
+// event, ok is false if there is none from the last ocppStatusMaxAge.
+func (w *Wallbox) SessionOCPPStatusCode() (int, bool) {
+	return w.getTelemetryOCPPStatus()
+}
+
+// ocppStatusMaxAge is how long a journal or session OCPP status is trusted.
+const ocppStatusMaxAge = 10 * time.Minute
+
+type ocppSample struct {
+	code int
+	at   time.Time
+}
+
+func (s ocppSample) fresh(now time.Time) bool {
+	return s.code >= 0 && now.Sub(s.at) < ocppStatusMaxAge
+}
+
+// pickOCPPStatus resolves the journal and session OCPP status by
+// precedence, ignoring stale samples. ok is false if neither is fresh.
+func pickOCPPStatus(precedence string, journal, session ocppSample, now time.Time) (int, bool) {
+	first, second := journal, session
+	switch precedence {
+	case OCPPPrecedenceSession:
+		first, second = session, journal
+	case OCPPPrecedenceNewest:
+		if session.at.After(journal.at) {
+			first, second = session, journal
+		}
+	}
+	if first.fresh(now) {
+		return first.code, true
+	}
+	if second.fresh(now) {
+		return second.code, true
+	}
+	return 0, false
+}
+
 func (w *Wallbox) OCPPStatusDescription() string {
 	return describeOCPPStatus(w.OCPPStatusCode())
 }
@@ -691,6 +1847,29 @@ func (w *Wallbox) ConnectionType() string {
 	return describeConnectionType(code)
 }
 
+// WifiSignalStrength returns the Wi-Fi RSSI in dBm, or 0 when telemetry
+// doesn't report one, e.g. on Ethernet or GSM.
+func (w *Wallbox) WifiSignalStrength() float64 {
+	if !w.HasTelemetry {
+		return 0
+	}
+	return w.Data.RedisTelemetry.WifiSignalStrength
+}
+
+// IsGSM reports whether telemetry says the charger is connected over cellular.
+func (w *Wallbox) IsGSM() bool {
+	return w.ConnectionType() == describeConnectionType(3)
+}
+
+// GSMSignalQuality returns the cellular signal quality as reported by
+// telemetry, or 0 when the firmware does not report it.
+func (w *Wallbox) GSMSignalQuality() float64 {
+	if !w.HasTelemetry {
+		return 0
+	}
+	return w.Data.RedisTelemetry.GSMSignalQuality
+}
+
 func (w *Wallbox) ConnectivityStatus() string {
 	if !w.HasTelemetry {
 		return "Unknown"
@@ -707,6 +1886,8 @@ func (w *Wallbox) ControlMode() string {
 	return describeControlMode(code)
 }
 
+// ScheduleStatus describes whether the charger's internal schedule is
+// gating the current right now.
 func (w *Wallbox) ScheduleStatus() string {
 	if !w.HasTelemetry {
 		return "Unknown"
@@ -714,6 +1895,101 @@ func (w *Wallbox) ScheduleStatus() string {
 	return describeScheduleStatus(int(w.Data.RedisTelemetry.ScheduleStatus))
 }
 
+// ScheduleCurrentProposal returns the current in A the charger's schedule
+// proposes.
+func (w *Wallbox) ScheduleCurrentProposal() float64 {
+	return w.Data.RedisTelemetry.ScheduleCurrentProposal
+}
+
+var weekdayNames = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// parseTimeOfDay parses "HH:MM" or "HH:MM:SS" into an offset from midnight.
+func parseTimeOfDay(value string) (time.Duration, bool) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// nextScheduleWindow returns the enabled schedule window that is active at
+// now or, failing that, the one that starts soonest.
+func nextScheduleWindow(schedules []Schedule, now time.Time) (Schedule, time.Time, time.Time, bool) {
+	var best Schedule
+	var bestStart, bestEnd time.Time
+	found := false
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, s := range schedules {
+		startTOD, okStart := parseTimeOfDay(s.Start)
+		stopTOD, okStop := parseTimeOfDay(s.Stop)
+		if !s.Enabled || !okStart || !okStop {
+			continue
+		}
+
+		// Start from yesterday to catch windows that run past midnight.
+		for offset := -1; offset <= 7; offset++ {
+			day := midnight.AddDate(0, 0, offset)
+			weekday := (int(day.Weekday()) + 6) % 7
+			if s.Days&(1<<weekday) == 0 {
+				continue
+			}
+
+			start := day.Add(startTOD)
+			end := day.Add(stopTOD)
+			if !end.After(start) {
+				end = end.Add(24 * time.Hour)
+			}
+			if !end.After(now) {
+				continue
+			}
+			if !found || start.Before(bestStart) {
+				best, bestStart, bestEnd, found = s, start, end, true
+			}
+			break
+		}
+	}
+
+	return best, bestStart, bestEnd, found
+}
+
+// ScheduleWindow returns the active or next charging schedule window as
+// "HH:MM-HH:MM", or "None" if no schedule is enabled.
+func (w *Wallbox) ScheduleWindow() string {
+	_, start, end, ok := nextScheduleWindow(w.schedules, time.Now().In(w.Location()))
+	if !ok {
+		return "None"
+	}
+	return start.Format("15:04") + "-" + end.Format("15:04")
+}
+
+// ScheduleDays returns the weekdays of the schedule shown by ScheduleWindow.
+func (w *Wallbox) ScheduleDays() string {
+	s, _, _, ok := nextScheduleWindow(w.schedules, time.Now().In(w.Location()))
+	if !ok {
+		return "None"
+	}
+
+	var days []string
+	for i, name := range weekdayNames {
+		if s.Days&(1<<i) != 0 {
+			days = append(days, name)
+		}
+	}
+	return strings.Join(days, ", ")
+}
+
+// ScheduleNextStart returns when the window shown by ScheduleWindow starts
+// (in the past if it is active now) as RFC 3339, or "" if there is none.
+func (w *Wallbox) ScheduleNextStart() string {
+	_, start, _, ok := nextScheduleWindow(w.schedules, time.Now().In(w.Location()))
+	if !ok {
+		return ""
+	}
+	return start.Format(time.RFC3339)
+}
+
 func (w *Wallbox) EcosmartStatus() string {
 	if !w.HasTelemetry {
 		return "Unknown"
@@ -721,6 +1997,263 @@ func (w *Wallbox) EcosmartStatus() string {
 	return describeEcosmartStatus(int(w.Data.RedisTelemetry.EcosmartStatus))
 }
 
+// EcosmartGreenEnergy returns the lifetime energy EcoSmart charged from
+// solar surplus, in Wh like CumulativeAddedEnergy.
+func (w *Wallbox) EcosmartGreenEnergy() float64 {
+	return w.Data.RedisTelemetry.EcosmartGreenEnergy
+}
+
+// EcosmartEnergyTotal returns the lifetime energy charged while EcoSmart
+// was on, solar and grid together, in Wh.
+func (w *Wallbox) EcosmartEnergyTotal() float64 {
+	return w.Data.RedisTelemetry.EcosmartEnergyTotal
+}
+
+// EcoSmart modes as used by EcosmartModeName and SetEcosmartMode.
+const (
+	EcosmartOff       = "off"
+	EcosmartEco       = "eco"        // solar first, topped up from the grid
+	EcosmartFullSolar = "full_solar" // solar surplus only
+)
+
+// ecosmartModeCodes maps EcoSmart modes to SENSOR_ECOSMART_MODE values.
+var ecosmartModeCodes = map[string]int{
+	EcosmartEco:       0,
+	EcosmartFullSolar: 1,
+}
+
+// EcosmartModeName returns the active EcoSmart mode (EcosmartOff,
+// EcosmartEco or EcosmartFullSolar) from telemetry, or "unknown".
+func (w *Wallbox) EcosmartModeName() string {
+	if !w.HasTelemetry {
+		return "unknown"
+	}
+	if w.Data.RedisTelemetry.EcosmartStatus == 0 {
+		return EcosmartOff
+	}
+	for name, code := range ecosmartModeCodes {
+		if int(w.Data.RedisTelemetry.EcosmartMode) == code {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// EcosmartWritable reports whether the set_ecosmart_mode statement fits this
+// charger's database, i.e. whether SetEcosmartMode can work.
+func (w *Wallbox) EcosmartWritable() bool {
+	return w.ecosmartWritable
+}
+
+// SetEcosmartMode switches EcoSmart off or to the given mode.
+func (w *Wallbox) SetEcosmartMode(mode string) error {
+	if !w.ecosmartWritable {
+		return errors.New("EcoSmart can't be set on this charger, see [queries] set_ecosmart_mode")
+	}
+	enabled, code := 0, 0
+	if mode != EcosmartOff {
+		var ok bool
+		if code, ok = ecosmartModeCodes[mode]; !ok {
+			return fmt.Errorf("unknown EcoSmart mode %q", mode)
+		}
+		enabled = 1
+	}
+	_, err := w.db().Exec(w.queries.SetEcosmartMode, enabled, code)
+	return err
+}
+
+// SchedulesKnown reports whether the schedules query has worked at least
+// once, i.e. whether the schedule entities show real data.
+func (w *Wallbox) SchedulesKnown() bool {
+	return w.schedulesKnown
+}
+
+// SchedulesEnabled reports whether any charging schedule is enabled.
+func (w *Wallbox) SchedulesEnabled() bool {
+	for _, s := range w.schedules {
+		if s.Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// SchedulesWritable reports whether the set_schedules_enabled statement fits
+// this charger's database, i.e. whether SetSchedulesEnabled can work.
+func (w *Wallbox) SchedulesWritable() bool {
+	return w.schedulesWritable
+}
+
+// SetSchedulesEnabled arms or disarms the charging schedules with the
+// set_schedules_enabled statement. Disarming only turns off the schedules
+// that are enabled and remembers which they were; arming turns exactly those
+// back on, so schedules the user had switched off stay off. SchedulesEnabled
+// follows on the next RefreshData.
+func (w *Wallbox) SetSchedulesEnabled(enabled bool) error {
+	if !w.schedulesWritable {
+		return errors.New("schedules can't be set on this charger, see [queries] set_schedules_enabled")
+	}
+
+	w.schedulesMux.Lock()
+	schedules := w.schedules
+	w.schedulesMux.Unlock()
+
+	saved, _, err := w.store.Get(disarmedSchedulesKey)
+	if err != nil {
+		return fmt.Errorf("loading the disarmed schedules: %w", err)
+	}
+	writes, disarmed, err := planScheduleWrites(schedules, parseScheduleIDs(saved), enabled)
+	if err != nil {
+		return err
+	}
+
+	// Remember what to restore before touching the schedules, so a failed
+	// write can't lose it.
+	if disarmed != nil {
+		if err := w.store.Set(disarmedSchedulesKey, formatScheduleIDs(disarmed)); err != nil {
+			return fmt.Errorf("saving the disarmed schedules: %w", err)
+		}
+	}
+	for _, id := range writes {
+		value := 0
+		if enabled {
+			value = 1
+		}
+		if _, err := w.db().Exec(w.queries.SetSchedulesEnabled, value, id); err != nil {
+			return err
+		}
+	}
+	if enabled {
+		return w.store.Set(disarmedSchedulesKey, "")
+	}
+	return nil
+}
+
+// planScheduleWrites returns the ids of the schedules to switch for arming
+// (enabled) or disarming, given the schedules as read and the ids saved by
+// the last disarm. When disarming it also returns the ids to save; nil means
+// the saved ids stay as they are, e.g. when everything is already off.
+func planScheduleWrites(schedules []Schedule, saved []int64, enabled bool) (writes, disarmed []int64, err error) {
+	if !enabled {
+		for _, s := range schedules {
+			if s.Enabled {
+				writes = append(writes, s.ID)
+			}
+		}
+		return writes, writes, nil
+	}
+
+	if len(saved) == 0 {
+		return nil, nil, errors.New("no schedules were disarmed by the bridge, enable them in the Wallbox app")
+	}
+	known := make(map[int64]bool, len(schedules))
+	for _, s := range schedules {
+		known[s.ID] = true
+	}
+	for _, id := range saved {
+		// Schedules deleted in the meantime are skipped.
+		if known[id] {
+			writes = append(writes, id)
+		}
+	}
+	return writes, nil, nil
+}
+
+func parseScheduleIDs(value string) []int64 {
+	var ids []int64
+	for _, field := range strings.Split(value, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func formatScheduleIDs(ids []int64) string {
+	fields := make([]string, len(ids))
+	for i, id := range ids {
+		fields[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(fields, ",")
+}
+
+// autoLockSettings is the charger's auto-lock configuration as returned by
+// the auto_lock query.
+type autoLockSettings struct {
+	Enabled bool `db:"auto_lock"`
+	Time    int  `db:"auto_lock_time"`
+}
+
+// Auto-lock timeouts the charger accepts, in seconds.
+const (
+	MinAutoLockTime = 60
+	MaxAutoLockTime = 3600
+)
+
+// AutoLockSupported reports whether the charger exposes the auto-lock
+// settings; the accessors and setters do nothing until it does.
+func (w *Wallbox) AutoLockSupported() bool {
+	return w.autoLockSupported
+}
+
+// AutoLockEnabled reports whether the charger locks itself when idle.
+func (w *Wallbox) AutoLockEnabled() bool {
+	return w.autoLock.Enabled
+}
+
+// AutoLockTime returns how long the charger stays idle before locking
+// itself, in seconds.
+func (w *Wallbox) AutoLockTime() int {
+	return w.autoLock.Time
+}
+
+// AutoLockEnabledWritable reports whether the set_auto_lock_enabled
+// statement fits this charger's database.
+func (w *Wallbox) AutoLockEnabledWritable() bool {
+	return w.autoLockEnabledWritable
+}
+
+// AutoLockTimeWritable reports whether the set_auto_lock_time statement fits
+// this charger's database.
+func (w *Wallbox) AutoLockTimeWritable() bool {
+	return w.autoLockTimeWritable
+}
+
+// SetAutoLockEnabled turns auto-lock on or off with the
+// set_auto_lock_enabled statement. It is a no-op on chargers without
+// auto-lock.
+func (w *Wallbox) SetAutoLockEnabled(enabled bool) error {
+	if !w.autoLockSupported {
+		return nil
+	}
+	if !w.autoLockEnabledWritable {
+		return errors.New("auto-lock can't be set on this charger, see [queries] set_auto_lock_enabled")
+	}
+	value := 0
+	if enabled {
+		value = 1
+	}
+	_, err := w.db().Exec(w.queries.SetAutoLockEnabled, value)
+	return err
+}
+
+// SetAutoLockTime sets the auto-lock timeout in seconds with the
+// set_auto_lock_time statement. Values outside MinAutoLockTime..
+// MaxAutoLockTime are rejected; it is a no-op on chargers without auto-lock.
+func (w *Wallbox) SetAutoLockTime(seconds int) error {
+	if seconds < MinAutoLockTime || seconds > MaxAutoLockTime {
+		return fmt.Errorf("auto-lock time %ds outside %d-%ds", seconds, MinAutoLockTime, MaxAutoLockTime)
+	}
+	if !w.autoLockSupported {
+		return nil
+	}
+	if !w.autoLockTimeWritable {
+		return errors.New("auto-lock time can't be set on this charger, see [queries] set_auto_lock_time")
+	}
+	_, err := w.db().Exec(w.queries.SetAutoLockTime, seconds)
+	return err
+}
+
 func (w *Wallbox) PowerBoostStatus() string {
 	if !w.HasTelemetry {
 		return "Unknown"
@@ -728,6 +2261,13 @@ func (w *Wallbox) PowerBoostStatus() string {
 	return describePowerBoostStatus(int(w.Data.RedisTelemetry.PowerboostStatus))
 }
 
+// PMSDominantFeature is the raw SENSOR_PMS_DOMINANT_FEATURE code for the
+// power-management feature limiting the charging current (0 when none).
+// The other codes are not documented, so they are passed through as is.
+func (w *Wallbox) PMSDominantFeature() int {
+	return int(w.Data.RedisTelemetry.PMSDominantFeature)
+}
+
 func (w *Wallbox) PowerSharingStatus() string {
 	if !w.HasTelemetry {
 		return "Unknown"
@@ -749,13 +2289,62 @@ func (w *Wallbox) PowerRelayCommand() string {
 	return describePowerRelayCommand(int(w.Data.RedisTelemetry.PowerRelayManagementCommand))
 }
 
+// DataMode reports which code path the accessors currently use: "telemetry"
+// on firmware that emits telemetry events, "legacy" when only the m2w/SQL
+// sources are available.
+func (w *Wallbox) DataMode() string {
+	if w.HasTelemetry {
+		return "telemetry"
+	}
+	return "legacy"
+}
+
+// CurrentLimitSource reports which constraint is currently binding the
+// charging current by comparing the user setpoint against the telemetry
+// proposals. The lowest non-zero limit wins; on ties the user setpoint is
+// preferred so that "User setpoint" means the charger is doing what was asked.
+func (w *Wallbox) CurrentLimitSource() string {
+	if !w.HasTelemetry {
+		return "Unknown"
+	}
+
+	userSetpoint := w.Data.RedisTelemetry.UserCurrentProposal
+	if userSetpoint == 0 {
+		userSetpoint = float64(w.Data.SQL.MaxChargingCurrent)
+	}
+
+	limits := []struct {
+		source string
+		value  float64
+	}{
+		{"User setpoint", userSetpoint},
+		{"Available current", w.Data.RedisTelemetry.MaxAvailableCurrent},
+		{"PowerBoost", w.Data.RedisTelemetry.PowerboostProposalCurrent},
+		{"Schedule", w.Data.RedisTelemetry.ScheduleCurrentProposal},
+		{"ICP", w.Data.RedisTelemetry.ICPMaxCurrent},
+	}
+
+	source := "Unknown"
+	lowest := 0.0
+	for _, limit := range limits {
+		if limit.value <= 0 {
+			continue
+		}
+		if source == "Unknown" || limit.value < lowest {
+			source = limit.source
+			lowest = limit.value
+		}
+	}
+	return source
+}
+
 func (w *Wallbox) getTelemetryOCPPStatus() (int, bool) {
 	w.ocppStatusMux.RLock()
 	code := w.telemetryOCPPStatus
 	ts := w.telemetryOCPPUpdated
 	w.ocppStatusMux.RUnlock()
 
-	if code >= 0 && time.Since(ts) < 10*time.Minute {
+	if (ocppSample{code, ts}).fresh(time.Now()) {
 		return code, true
 	}
 	return 0, false
@@ -774,7 +2363,7 @@ func (w *Wallbox) getJournalOCPPStatus() (int, bool) {
 	ts := w.journalOCPPUpdated
 	w.ocppStatusMux.RUnlock()
 
-	if code >= 0 && time.Since(ts) < 10*time.Minute {
+	if (ocppSample{code, ts}).fresh(time.Now()) {
 		return code, true
 	}
 	return 0, false
@@ -823,6 +2412,8 @@ func (w *Wallbox) AddedEnergy() float64 {
 		status := int(w.Data.RedisTelemetry.StateMachine)
 		current := w.Data.RedisTelemetry.InternalMeterEnergy
 
+		w.baselineMux.Lock()
+		defer w.baselineMux.Unlock()
 		if !isChargingTelemetryStatus(status) && current > 0 {
 			w.sessionEnergyBaseline = current
 			return 0
@@ -841,117 +2432,361 @@ func (w *Wallbox) AddedEnergy() float64 {
 	return w.Data.RedisState.ScheduleEnergy
 }
 
+// resetSessionEnergyBaseline starts the telemetry-based session energy over
+// at the current internal meter reading, or at the next one AddedEnergy
+// sees when there is none yet. This way a late meter sample from the
+// previous plug-in can't carry over into the new session.
+func (w *Wallbox) resetSessionEnergyBaseline() {
+	current := 0.0
+	if w.HasTelemetry {
+		current = w.Data.RedisTelemetry.InternalMeterEnergy
+	}
+	w.baselineMux.Lock()
+	defer w.baselineMux.Unlock()
+	log.Printf("New session: session energy baseline reset from %.1f Wh to %.1f Wh", w.sessionEnergyBaseline, current)
+	w.sessionEnergyBaseline = current
+}
+
+// ResyncSessionEnergyBaseline re-zeros the telemetry-based session energy at
+// the current internal meter reading without ending the session, e.g. after
+// a firmware update changed the meter. AddedEnergy counts from here on. It
+// reports false when there is no meter reading to re-sync to.
+func (w *Wallbox) ResyncSessionEnergyBaseline() bool {
+	current := w.Data.RedisTelemetry.InternalMeterEnergy
+	if !w.HasTelemetry || current == 0 {
+		log.Println("Not re-syncing session energy baseline: no internal meter reading")
+		return false
+	}
+	w.baselineMux.Lock()
+	defer w.baselineMux.Unlock()
+	log.Printf("Re-syncing session energy baseline from %.1f Wh to %.1f Wh (session energy was %.1f Wh)",
+		w.sessionEnergyBaseline, current, current-w.sessionEnergyBaseline)
+	w.sessionEnergyBaseline = current
+	if w.Data.SQL.ActiveSessionEnergyTotal > 0 {
+		log.Println("The charger reports this session's energy itself; added_energy keeps using that value")
+	}
+	return true
+}
+
 func (w *Wallbox) SetEventHandler(handler func(channel string, message string)) {
 	w.eventHandler = handler
 }
 
-func (w *Wallbox) StartRedisSubscriptions() {
-	channels := []string{
-		"/wbx/telemetry/events",
-		"/wbx/charger_state_machine/events",
-		"/wbx/charging_regulation/in/session",
-		"/wbx/domain_bus/event/CHARGER_STATUS_CHANGED",
-	}
-
-	w.pubsub = w.redisClient.Subscribe(context.Background(), channels...)
-
-	// Start goroutine to handle messages
-	go func() {
-		ch := w.pubsub.Channel()
-		for msg := range ch {
-			switch msg.Channel {
-			case "/wbx/telemetry/events":
-				w.ProcessTelemetryEvent(msg.Payload)
-			case "/wbx/charger_state_machine/events", "/wbx/charging_regulation/in/session":
-				w.ProcessSessionUpdateEvent(msg.Payload)
-			case "/wbx/domain_bus/event/CHARGER_STATUS_CHANGED":
-				w.ProcessChargerStatusEvent(msg.Payload)
-			}
-
-			if w.eventHandler != nil {
-				w.eventHandler(msg.Channel, msg.Payload)
-			}
-		}
-	}()
+// Redis pub/sub channels the bridge listens on, keyed to the short name used
+// for their parse failure counters.
+var eventChannels = map[string]string{
+	"/wbx/telemetry/events":                        "telemetry",
+	"/wbx/charger_state_machine/events":            "state_machine",
+	"/wbx/charging_regulation/in/session":          "session",
+	"/wbx/domain_bus/event/CHARGER_STATUS_CHANGED": "charger_status",
 }
 
-func (w *Wallbox) StopRedisSubscriptions() {
-	if w.pubsub != nil {
-		w.pubsub.Close()
-	}
+// EventChannelNames returns the short names of the subscribed channels.
+func EventChannelNames() []string {
+	return []string{"telemetry", "state_machine", "session", "charger_status"}
 }
 
-// StartOCPPJournalWatcher spawns a background goroutine that tails the
-// ocppwallbox journald stream and extracts OCPP StatusNotification "status"
-// values (Available, Charging, SuspendedEV, etc). These are mapped to
-// numeric OCPP status codes and fed into SetJournalOCPPStatus, which is
-// preferred by OCPPStatusCode over session/telemetry-based fallbacks.
-func (w *Wallbox) StartOCPPJournalWatcher() {
-	// Avoid starting multiple watchers if called more than once.
-	if w.journalStopCh != nil {
+func (w *Wallbox) StartRedisSubscriptions() {
+	w.pubsubMux.Lock()
+	defer w.pubsubMux.Unlock()
+	if w.pubsubStopCh != nil {
 		return
 	}
 
+	channels := make([]string, 0, len(eventChannels))
+	for channel := range eventChannels {
+		channels = append(channels, channel)
+	}
+
 	stopCh := make(chan struct{})
-	w.journalStopCh = stopCh
+	w.pubsubStopCh = stopCh
+	go w.superviseSubscriptions(channels, stopCh)
+}
 
-	go func() {
-		defer func() {
-			// Best-effort cleanup of the child process if we managed to start it.
-			w.journalStopCh = nil
-		}()
-
-		cmd := exec.Command("journalctl",
-			"-u", "ocppwallbox.service",
-			"-f",      // follow new entries
-			"-n", "0", // do not replay historical logs
-			"-o", "cat", // message only, no metadata
-			"-q", // quiet
-		)
+// Backoff between attempts to subscribe anew should the pub/sub channel
+// ever close while the bridge is running.
+const (
+	resubscribeMinDelay = time.Second
+	resubscribeMaxDelay = 30 * time.Second
+)
 
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			log.Printf("OCPP journal: failed to open stdout: %v", err)
+// superviseSubscriptions receives pub/sub messages until stopCh closes.
+// go-redis v9 handles a dropped connection (e.g. Redis restarted by a
+// firmware self-heal) internally: it pings the connection after 3 s without
+// messages, reconnects and re-sends SUBSCRIBE, which recordSubscription
+// counts. The channel itself is only closed by PubSub.Close, so the loop
+// below normally ends with Stop; subscribing anew with a growing delay is
+// only a safety net. Cached telemetry and HasTelemetry are left untouched.
+func (w *Wallbox) superviseSubscriptions(channels []string, stopCh chan struct{}) {
+	delay := resubscribeMinDelay
+	for {
+		w.pubsubMux.Lock()
+		select {
+		case <-stopCh:
+			w.pubsubMux.Unlock()
 			return
+		default:
 		}
+		pubsub := w.redisClient.Subscribe(context.Background(), channels...)
+		w.pubsub = pubsub
+		w.pubsubMux.Unlock()
+
+		for item := range pubsub.ChannelWithSubscriptions() {
+			switch msg := item.(type) {
+			case *redis.Subscription:
+				w.recordSubscription(msg)
+				delay = resubscribeMinDelay
+			case *redis.Message:
+				w.handleEvent(msg.Channel, msg.Payload)
+
+				if w.eventHandler != nil {
+					w.eventHandler(msg.Channel, msg.Payload)
+				}
+			}
+		}
+		pubsub.Close()
 
-		if err := cmd.Start(); err != nil {
-			log.Printf("OCPP journal: failed to start journalctl: %v", err)
+		select {
+		case <-stopCh:
 			return
+		default:
 		}
-		defer func() {
-			_ = cmd.Process.Kill()
-			_, _ = cmd.Process.Wait()
-		}()
-
-		scanner := bufio.NewScanner(stdout)
-		for {
-			select {
-			case <-stopCh:
-				return
-			default:
-			}
+		log.Printf("Redis pub/sub channel closed; resubscribing in %s", delay)
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > resubscribeMaxDelay {
+			delay = resubscribeMaxDelay
+		}
+	}
+}
 
-			if !scanner.Scan() {
-				if err := scanner.Err(); err != nil {
-					log.Printf("OCPP journal: scanner error: %v", err)
-				}
-				return
-			}
+// recordSubscription counts re-subscriptions. go-redis reconnects on its own
+// and re-sends SUBSCRIBE; the confirmation for the first channel of every
+// round carries Count 1, so every such round after the first is a resubscribe.
+func (w *Wallbox) recordSubscription(sub *redis.Subscription) {
+	if sub.Kind != "subscribe" || sub.Count != 1 {
+		return
+	}
+	w.eventStatsMux.Lock()
+	defer w.eventStatsMux.Unlock()
+	if w.subscribed {
+		w.redisResubscribes++
+	}
+	w.subscribed = true
+}
 
-			line := scanner.Text()
-			status, ok := parseOCPPStatusFromLogLine(line)
-			if !ok {
-				continue
-			}
+// RedisResubscribes returns how often the pub/sub connection had to
+// re-subscribe since the bridge started.
+func (w *Wallbox) RedisResubscribes() int {
+	w.eventStatsMux.RLock()
+	defer w.eventStatsMux.RUnlock()
+	return w.redisResubscribes
+}
 
-			if code, found := LookupOCPPStatusCode(status); found {
-				w.SetJournalOCPPStatus(code)
-			} else {
-				log.Printf("OCPP journal: unknown StatusNotification status %q in line: %s", status, line)
-			}
+// handleEvent dispatches one pub/sub message and records whether it parsed.
+func (w *Wallbox) handleEvent(channel string, payload string) {
+	var err error
+	switch channel {
+	case "/wbx/telemetry/events":
+		err = w.ProcessTelemetryEvent(payload)
+	case "/wbx/charger_state_machine/events", "/wbx/charging_regulation/in/session":
+		err = w.ProcessSessionUpdateEvent(payload)
+	case "/wbx/domain_bus/event/CHARGER_STATUS_CHANGED":
+		err = w.ProcessChargerStatusEvent(payload)
+	default:
+		return
+	}
+	w.recordEvent(eventChannels[channel], err)
+}
+
+func (w *Wallbox) recordEvent(name string, err error) {
+	w.eventStatsMux.Lock()
+	defer w.eventStatsMux.Unlock()
+
+	w.eventsProcessed++
+	if err == nil {
+		return
+	}
+	if w.eventParseFailures == nil {
+		w.eventParseFailures = make(map[string]int)
+	}
+	w.eventParseFailures[name]++
+	w.lastEventParseError = fmt.Sprintf("%s: %v", name, err)
+}
+
+// EventsProcessed returns how many pub/sub events were received in total.
+func (w *Wallbox) EventsProcessed() int {
+	w.eventStatsMux.RLock()
+	defer w.eventStatsMux.RUnlock()
+	return w.eventsProcessed
+}
+
+// EventParseFailures returns how many events on the named channel (see
+// EventChannelNames) could not be parsed.
+func (w *Wallbox) EventParseFailures(name string) int {
+	w.eventStatsMux.RLock()
+	defer w.eventStatsMux.RUnlock()
+	return w.eventParseFailures[name]
+}
+
+// LastEventParseError returns the most recent parse error, prefixed with the
+// channel name, or "None".
+func (w *Wallbox) LastEventParseError() string {
+	w.eventStatsMux.RLock()
+	defer w.eventStatsMux.RUnlock()
+	if w.lastEventParseError == "" {
+		return "None"
+	}
+	return w.lastEventParseError
+}
+
+func (w *Wallbox) StopRedisSubscriptions() {
+	w.pubsubMux.Lock()
+	defer w.pubsubMux.Unlock()
+	if w.pubsubStopCh != nil {
+		close(w.pubsubStopCh)
+		w.pubsubStopCh = nil
+	}
+	if w.pubsub != nil {
+		w.pubsub.Close()
+		w.pubsub = nil
+	}
+}
+
+// StartOCPPJournalWatcher spawns a background goroutine that tails the
+// ocppwallbox journald stream and extracts OCPP StatusNotification "status"
+// values (Available, Charging, SuspendedEV, etc). These are mapped to
+// numeric OCPP status codes and fed into SetJournalOCPPStatus, which is
+// preferred by OCPPStatusCode over session/telemetry-based fallbacks.
+// Configured journal patterns (see SetJournalPatterns) are matched against
+// the same stream, and units they name get a watcher of their own.
+func (w *Wallbox) StartOCPPJournalWatcher() {
+	// Avoid starting multiple watchers if called more than once.
+	if w.journalStopCh != nil {
+		return
+	}
+
+	stopCh := make(chan struct{})
+	w.journalStopCh = stopCh
+
+	ocppPatterns := w.journalPatterns[ocppJournalUnit]
+	go tailJournal(ocppJournalUnit, stopCh, func(line string) {
+		w.ocppTransaction.processLine(line)
+		w.ocppHeartbeat.processLine(line, time.Now())
+		w.matchJournalPatterns(ocppPatterns, line, time.Now())
+
+		status, ok := parseOCPPStatusFromLogLine(line)
+		if !ok {
+			return
+		}
+
+		if code, found := LookupOCPPStatusCode(status); found {
+			w.SetJournalOCPPStatus(code)
+		} else {
+			log.Printf("OCPP journal: unknown StatusNotification status %q in line: %s", status, line)
+		}
+	})
+
+	for unit, patterns := range w.journalPatterns {
+		if unit == ocppJournalUnit {
+			continue
 		}
+		patterns := patterns
+		go tailJournal(unit, stopCh, func(line string) {
+			w.matchJournalPatterns(patterns, line, time.Now())
+		})
+	}
+}
+
+// tailJournal follows new journal entries of unit and passes each message to
+// handle until stopCh is closed or journalctl exits.
+func tailJournal(unit string, stopCh chan struct{}, handle func(line string)) {
+	cmd := exec.Command("journalctl", journalArgs(unit, "-f", "-n", "0")...) // follow new entries only
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Printf("Journal %s: failed to open stdout: %v", unit, err)
+		return
+	}
+
+	if err := cmd.Start(); err != nil {
+		log.Printf("Journal %s: failed to start journalctl: %v", unit, err)
+		return
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_, _ = cmd.Process.Wait()
 	}()
+
+	scanner := bufio.NewScanner(stdout)
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				log.Printf("Journal %s: scanner error: %v", unit, err)
+			}
+			return
+		}
+
+		handle(scanner.Text())
+	}
+}
+
+const ocppJournalUnit = "ocppwallbox.service"
+
+// ocppJournalArgs returns the journalctl arguments for the ocppwallbox unit,
+// message only (no metadata) and quiet, followed by extra.
+func ocppJournalArgs(extra ...string) []string {
+	return journalArgs(ocppJournalUnit, extra...)
+}
+
+func journalArgs(unit string, extra ...string) []string {
+	return append([]string{"-u", unit, "-o", "cat", "-q"}, extra...)
+}
+
+var journalSecretRes = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)("(?:password|passwd|pass|token|secret|api_?key|authorization(?:key)?|idtag)"\s*:\s*)"[^"]*"`),
+	regexp.MustCompile(`(?i)((?:password|passwd|token|secret|api_?key)\s*[=:]\s*)[^\s,;"]+`),
+	regexp.MustCompile(`(?i)((?:basic|bearer)\s+)[a-z0-9+/=._-]{8,}`),
+	regexp.MustCompile(`(?i)(wss?://[^:/\s]+:)[^@\s]+@`),
+}
+
+// redactJournalLine masks credentials and RFID tags that ocppwallbox may log.
+func redactJournalLine(line string) string {
+	for i, re := range journalSecretRes {
+		replacement := "${1}<redacted>"
+		switch i {
+		case 0:
+			replacement = `${1}"<redacted>"`
+		case 3:
+			replacement = "${1}<redacted>@"
+		}
+		line = re.ReplaceAllString(line, replacement)
+	}
+	return line
+}
+
+// OCPPJournalSnapshot returns the last n ocppwallbox journal lines with
+// obvious secrets redacted, for attaching to bug reports.
+func OCPPJournalSnapshot(n int) ([]string, error) {
+	out, err := exec.Command("journalctl", ocppJournalArgs("--no-pager", "-n", strconv.Itoa(n))...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = redactJournalLine(line)
+	}
+	return lines, nil
 }
 
 // StopOCPPJournalWatcher signals the background journal watcher (if any) to
@@ -982,7 +2817,9 @@ type TelemetryEvent struct {
 			ID        string   `json:"id"`
 			Metadata  []string `json:"metadata"`
 			Timestamp string   `json:"timestamp"`
-			Value     float64  `json:"value"`
+			// Value is kept raw because some firmware sends enum sensors
+			// as JSON strings; see parseTelemetryValue.
+			Value json.RawMessage `json:"value"`
 		} `json:"sensors"`
 	} `json:"body"`
 	Header struct {
@@ -1021,87 +2858,438 @@ type ChargerStatusEvent struct {
 }
 
 // ProcessTelemetryEvent processes telemetry events and updates the RedisTelemetry struct
-func (w *Wallbox) ProcessTelemetryEvent(payload string) {
+func (w *Wallbox) ProcessTelemetryEvent(payload string) error {
 	var event TelemetryEvent
 	err := json.Unmarshal([]byte(payload), &event)
 	if err != nil {
 		log.Printf("Error unmarshalling telemetry event: %v", err)
-		return
+		return err
 	}
 
+	w.trackClockOffset(event.Header.Timestamp, time.Now())
+	w.markSeen(&w.lastTelemetryAt, time.Now())
+
 	// Process each sensor in the event
 	for _, sensor := range event.Body.Sensors {
+		value, ok := parseTelemetryValue(sensor.Value)
+		if !ok {
+			log.Printf("Skipping telemetry sensor %s with non-numeric value %s", sensor.ID, string(sensor.Value))
+			continue
+		}
+
 		// Directly update the RedisTelemetry struct based on the sensor ID
-		w.updateTelemetryField(sensor.ID, sensor.Value)
+		w.updateTelemetryField(sensor.ID, value)
+		if sensor.ID == "SENSOR_STATE_MACHINE" {
+			w.trackContactorCycle(int(value))
+		}
 	}
+
+	return nil
 }
 
-// updateTelemetryField updates a specific field in the RedisTelemetry struct by sensor ID
-func (w *Wallbox) updateTelemetryField(sensorID string, value float64) {
-	// Use reflection to update the appropriate field in the RedisTelemetry struct
-	v := reflect.ValueOf(&w.Data.RedisTelemetry).Elem()
-	t := v.Type()
+// parseTelemetryValue accepts a telemetry sensor value encoded either as a
+// JSON number or as a numeric JSON string (e.g. "193").
+func parseTelemetryValue(raw json.RawMessage) (float64, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, false
+	}
 
-	// Iterate through struct fields to find the matching one
-	for i := 0; i < v.NumField(); i++ {
-		field := t.Field(i)
-		redisTag := field.Tag.Get("redis")
-
-		// Check if this field's redis tag matches our telemetry key
-		if redisTag == "telemetry."+sensorID {
-			// Mark that we have seen at least one mapped telemetry sample so
-			// higher‑level code can choose telemetry-backed values.
-			w.HasTelemetry = true
-			// Make sure the field is settable
-			if v.Field(i).CanSet() {
-				v.Field(i).SetFloat(value)
+	var number float64
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return number, true
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
+
+// trackContactorCycle counts every transition of the state machine from a
+// non-charging state into a charging state, since that is when the contactor
+// closes. The first sample after startup is never counted because the prior
+// state is unknown. The lifetime count is persisted in the store.
+func (w *Wallbox) trackContactorCycle(state int) {
+	prev := w.lastStateMachine
+	w.lastStateMachine = state
+	if prev == 0 || isTelemetryCharging(prev) || !isTelemetryCharging(state) {
+		return
+	}
+
+	w.contactorCycles++
+	if w.store == nil {
+		return
+	}
+	cycles, err := w.store.Incr(contactorCyclesKey)
+	if err != nil {
+		log.Printf("Failed to persist contactor cycle count: %v", err)
+		return
+	}
+	w.contactorCycles = cycles
+}
+
+// trackClockOffset compares an event header timestamp with the time the event
+// was received. Events are delivered within milliseconds on-device, so any
+// sizeable difference is the charger clock being off. Missing or unparsable
+// timestamps are ignored.
+func (w *Wallbox) trackClockOffset(timestamp string, received time.Time) {
+	if timestamp == "" {
+		return
+	}
+	ts, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return
+	}
+
+	w.clockMux.Lock()
+	w.clockOffset = ts.Sub(received).Seconds()
+	w.clockOffsetKnown = true
+	w.clockMux.Unlock()
+}
+
+// TimeSyncOffset returns how far the charger clock is ahead (positive) or
+// behind (negative) the bridge host, in seconds, and whether any timestamped
+// event has been seen yet.
+func (w *Wallbox) TimeSyncOffset() (float64, bool) {
+	w.clockMux.RLock()
+	defer w.clockMux.RUnlock()
+	return w.clockOffset, w.clockOffsetKnown
+}
+
+// loadPersistedState restores the counters kept in the store.
+func (w *Wallbox) loadPersistedState() {
+	w.contactorCycles = w.storedInt(contactorCyclesKey)
+	w.lockCount = w.storedInt(lockCountKey)
+	w.unlockCount = w.storedInt(unlockCountKey)
+	if ts, ok, err := w.store.Get(lastUnlockedAtKey); err == nil && ok {
+		if at, err := time.Parse(time.RFC3339, ts); err == nil {
+			w.lastUnlockedAt = at
+		}
+	}
+	if ts, ok, err := w.store.Get(lastHealRebootKey); err == nil && ok {
+		if at, err := time.Parse(time.RFC3339, ts); err == nil {
+			w.lastHealReboot = at
+		}
+	}
+}
+
+func (w *Wallbox) storedInt(key string) int {
+	value, ok, err := w.store.Get(key)
+	if err != nil {
+		log.Printf("Failed to load %s: %v", key, err)
+		return 0
+	}
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.Atoi(value)
+	return n
+}
+
+// SetLockTransitionHandler registers fn to be called whenever the charger is
+// locked or unlocked.
+func (w *Wallbox) SetLockTransitionHandler(fn func(locked bool, at time.Time)) {
+	w.lockMux.Lock()
+	w.lockHandler = fn
+	w.lockMux.Unlock()
+}
+
+// trackLockTransition counts lock/unlock transitions for the audit sensors
+// and persists them in the store. The first sample after startup only
+// records the current state.
+func (w *Wallbox) trackLockTransition(lock int, now time.Time) {
+	w.lockMux.Lock()
+	if !w.lockKnown || lock == w.lastLock {
+		w.lockKnown = true
+		w.lastLock = lock
+		w.lockMux.Unlock()
+		return
+	}
+	w.lastLock = lock
+
+	locked := lock != 0
+	key := lockCountKey
+	if locked {
+		w.lockCount++
+	} else {
+		w.unlockCount++
+		w.lastUnlockedAt = now
+		key = unlockCountKey
+	}
+	handler := w.lockHandler
+	w.lockMux.Unlock()
+
+	if w.store != nil {
+		if _, err := w.store.Incr(key); err != nil {
+			log.Printf("Failed to persist %s: %v", key, err)
+		}
+		if !locked {
+			if err := w.store.Set(lastUnlockedAtKey, now.Format(time.RFC3339)); err != nil {
+				log.Printf("Failed to persist %s: %v", lastUnlockedAtKey, err)
 			}
-			return
 		}
 	}
 
-	// If we get here, we didn't find a matching field (might be a new sensor we're not tracking yet)
-	// We could log this for debugging purposes
+	if handler != nil {
+		handler(locked, now)
+	}
+}
+
+// LockCount returns how many times the charger has been locked.
+func (w *Wallbox) LockCount() int {
+	w.lockMux.Lock()
+	defer w.lockMux.Unlock()
+	return w.lockCount
+}
+
+// UnlockCount returns how many times the charger has been unlocked.
+func (w *Wallbox) UnlockCount() int {
+	w.lockMux.Lock()
+	defer w.lockMux.Unlock()
+	return w.unlockCount
+}
+
+// LastUnlockedAt returns when the charger was last unlocked as RFC 3339, or
+// "" if no unlock has been seen.
+func (w *Wallbox) LastUnlockedAt() string {
+	w.lockMux.Lock()
+	defer w.lockMux.Unlock()
+	if w.lastUnlockedAt.IsZero() {
+		return ""
+	}
+	return w.lastUnlockedAt.Format(time.RFC3339)
+}
+
+// RecordHealReboot notes that the bridge is about to reboot the charger to
+// heal it. Call it before rebooting so the time survives the reboot.
+func (w *Wallbox) RecordHealReboot(now time.Time) {
+	w.healRebootMux.Lock()
+	w.lastHealReboot = now
+	w.healRebootMux.Unlock()
+
+	if w.store != nil {
+		if err := w.store.Set(lastHealRebootKey, now.Format(time.RFC3339)); err != nil {
+			log.Printf("Failed to persist %s: %v", lastHealRebootKey, err)
+		}
+	}
+}
+
+// LastHealReboot returns when the bridge last rebooted the charger to heal
+// it as RFC 3339, or "" if it never did.
+func (w *Wallbox) LastHealReboot() string {
+	w.healRebootMux.Lock()
+	defer w.healRebootMux.Unlock()
+	if w.lastHealReboot.IsZero() {
+		return ""
+	}
+	return w.lastHealReboot.Format(time.RFC3339)
+}
+
+// ContactorCycles returns the lifetime number of charging starts seen by the
+// bridge, an estimate of contactor wear.
+func (w *Wallbox) ContactorCycles() int {
+	return w.contactorCycles
+}
+
+// Modes of SetUnmappedSensorLogging.
+const (
+	UnmappedSensorLogOnce = "once"
+	UnmappedSensorLogAll  = "all"
+	UnmappedSensorLogNone = "none"
+)
+
+// SetUnmappedSensorLogging sets how telemetry sensors without a
+// RedisTelemetry field are logged: each id once (the default), every sample,
+// or not at all. Firmware emits dozens of them while charging.
+func (w *Wallbox) SetUnmappedSensorLogging(mode string) {
+	switch mode {
+	case UnmappedSensorLogOnce, UnmappedSensorLogAll, UnmappedSensorLogNone:
+	default:
+		log.Printf("Unknown log_unmapped_sensors mode %q, using %q", mode, UnmappedSensorLogOnce)
+		mode = UnmappedSensorLogOnce
+	}
+	w.unmappedMux.Lock()
+	defer w.unmappedMux.Unlock()
+	w.unmappedLogMode = mode
+}
+
+func (w *Wallbox) logUnmappedSensor(sensorID string) {
+	w.unmappedMux.Lock()
+	defer w.unmappedMux.Unlock()
+	switch w.unmappedLogMode {
+	case UnmappedSensorLogNone:
+		return
+	case UnmappedSensorLogAll:
+	default:
+		if w.unmappedSensors[sensorID] {
+			return
+		}
+		if w.unmappedSensors == nil {
+			w.unmappedSensors = make(map[string]bool)
+		}
+		w.unmappedSensors[sensorID] = true
+	}
+	// Might be a new sensor worth mapping.
 	log.Printf("No matching struct field found for sensor ID: %s", sensorID)
 }
 
-func (w *Wallbox) ProcessSessionUpdateEvent(payload string) {
+// DefaultTelemetryTriggers are the telemetry sensor id prefixes whose samples
+// switch accessors to the telemetry path.
+var DefaultTelemetryTriggers = []string{
+	"SENSOR_STATE_MACHINE",
+	"SENSOR_CONTROL_PILOT",
+	"SENSOR_INTERNAL_METER",
+}
+
+// SetTelemetryTriggers replaces the sensor id prefixes that set HasTelemetry.
+// An empty list restores DefaultTelemetryTriggers.
+func (w *Wallbox) SetTelemetryTriggers(prefixes []string) {
+	w.telemetryTriggers = prefixes
+}
+
+func (w *Wallbox) isTelemetryTrigger(sensorID string) bool {
+	triggers := w.telemetryTriggers
+	if len(triggers) == 0 {
+		triggers = DefaultTelemetryTriggers
+	}
+	for _, prefix := range triggers {
+		if strings.HasPrefix(sensorID, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// telemetryFieldIndex maps telemetry sensor ids to the index of their float
+// field in RedisTelemetry. It is built once from the redis tags so events
+// don't scan the whole struct for every sensor.
+var telemetryFieldIndex = buildTelemetryFieldIndex()
+
+func buildTelemetryFieldIndex() map[string]int {
+	t := reflect.TypeOf(Wallbox{}.Data.RedisTelemetry)
+	index := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("redis")
+		if !strings.HasPrefix(tag, "telemetry.") || !field.IsExported() || field.Type.Kind() != reflect.Float64 {
+			continue
+		}
+		index[strings.TrimPrefix(tag, "telemetry.")] = i
+	}
+	return index
+}
+
+// updateTelemetryField updates a specific field in the RedisTelemetry struct by sensor ID
+func (w *Wallbox) updateTelemetryField(sensorID string, value float64) {
+	i, ok := telemetryFieldIndex[sensorID]
+	if !ok {
+		w.logUnmappedSensor(sensorID)
+		return
+	}
+
+	// Mark that we have seen charging-relevant telemetry so higher‑level
+	// code can choose telemetry-backed values. Service resource metrics
+	// alone say nothing about the charging data.
+	if w.isTelemetryTrigger(sensorID) {
+		w.HasTelemetry = true
+	}
+	reflect.ValueOf(&w.Data.RedisTelemetry).Elem().Field(i).SetFloat(value)
+}
+
+func (w *Wallbox) ProcessSessionUpdateEvent(payload string) error {
 	var event SessionUpdateEvent
 	if err := json.Unmarshal([]byte(payload), &event); err != nil {
 		log.Printf("Error unmarshalling session event: %v", err)
-		return
+		return err
 	}
 
 	if event.Header.MessageID != "EVENT_SESSION_UPDATE" {
-		return
+		return nil
 	}
 
 	state := event.Body.Session.State
 	if state == "" {
-		return
+		return nil
 	}
 
+	w.trackClockOffset(event.Header.Timestamp, time.Now())
+	w.trackSessionEnd(state, event.Body.Session.InSession, event.Body.Session.ControlAction)
+
 	if code, ok := ocppCodeFromSessionState(state); ok {
 		w.SetTelemetryOCPPStatus(code)
 	} else {
 		log.Printf("Unmapped session state for OCPP status: %s", state)
 	}
+
+	return nil
 }
 
-func (w *Wallbox) ProcessChargerStatusEvent(payload string) {
+// trackSessionEnd remembers the last state seen while a session was running
+// and, when in_session drops to false, derives why the session ended. When
+// in_session turns true it starts the session energy from zero.
+func (w *Wallbox) trackSessionEnd(state string, inSession bool, controlAction string) {
+	w.sessionMux.Lock()
+	defer w.sessionMux.Unlock()
+
+	// The first event after startup may be mid-session, so only a seen
+	// false->true edge counts as a new plug-in.
+	if inSession && w.inSessionKnown && !w.inSession {
+		w.resetSessionEnergyBaseline()
+	}
+	w.inSessionKnown = true
+
+	if inSession {
+		w.inSession = true
+		w.sessionLastState = state
+		return
+	}
+
+	if w.inSession {
+		w.lastSessionEndReason = sessionEndReason(w.sessionLastState, state, controlAction)
+		log.Printf("Session ended: %s (last state %q, end state %q, control action %q)",
+			w.lastSessionEndReason, w.sessionLastState, state, controlAction)
+	}
+	w.inSession = false
+	w.sessionLastState = ""
+}
+
+// LastSessionEndReason returns why the most recent session ended, or "None"
+// if no session has ended since the bridge started.
+func (w *Wallbox) LastSessionEndReason() string {
+	w.sessionMux.RLock()
+	defer w.sessionMux.RUnlock()
+
+	if w.lastSessionEndReason == "" {
+		return "None"
+	}
+	return w.lastSessionEndReason
+}
+
+func (w *Wallbox) ProcessChargerStatusEvent(payload string) error {
 	var event ChargerStatusEvent
 	if err := json.Unmarshal([]byte(payload), &event); err != nil {
 		log.Printf("Error unmarshalling charger status event: %v", err)
-		return
+		return err
 	}
 
-	if err := w.redisClient.Set(context.Background(), "bridge:last_ocpp_status", payload, 0).Err(); err != nil {
-		log.Printf("Failed to cache last OCPP status event: %v", err)
+	w.trackClockOffset(event.Header.Timestamp, time.Now())
+
+	if w.store != nil {
+		if err := w.store.Set(lastOCPPStatusKey, payload); err != nil {
+			log.Printf("Failed to cache last OCPP status event: %v", err)
+		}
 	}
 
 	// We still consume the event for other telemetry fields and to cache the payload,
 	// but we no longer override the OCPP status from this channel because the Wallbox
 	// session events provide a fresher, more accurate view of the connector state.
+
+	return nil
 }
 
 var statusNotificationStatusRe = regexp.MustCompile(`status"\s*:\s*"([^"]+)"`)
@@ -1125,6 +3313,123 @@ func parseOCPPStatusFromLogLine(line string) (string, bool) {
 	return status, true
 }
 
+var (
+	ocppCallRe          = regexp.MustCompile(`\[\s*2\s*,\s*"([^"]+)"\s*,\s*"(StartTransaction|StopTransaction)"`)
+	ocppCallResultRe    = regexp.MustCompile(`\[\s*3\s*,\s*"([^"]+)"\s*,`)
+	ocppTransactionIDRe = regexp.MustCompile(`"transactionId"\s*:\s*"?(\d+)`)
+)
+
+// ocppTransactionTracker follows StartTransaction/StopTransaction exchanges in
+// the ocppwallbox journal. The central system assigns the transaction id in
+// its StartTransaction CALLRESULT, which only echoes the request's message id,
+// so the id of the pending StartTransaction request is remembered.
+type ocppTransactionTracker struct {
+	mu            sync.RWMutex
+	pendingStart  string
+	transactionID string
+}
+
+func (t *ocppTransactionTracker) processLine(line string) {
+	if m := ocppCallRe.FindStringSubmatch(line); m != nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		switch m[2] {
+		case "StartTransaction":
+			t.pendingStart = m[1]
+		case "StopTransaction":
+			t.pendingStart = ""
+			t.transactionID = ""
+		}
+		return
+	}
+
+	m := ocppCallResultRe.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pendingStart == "" || m[1] != t.pendingStart {
+		return
+	}
+	t.pendingStart = ""
+	if id := ocppTransactionIDRe.FindStringSubmatch(line); id != nil {
+		t.transactionID = id[1]
+	}
+}
+
+func (t *ocppTransactionTracker) current() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.transactionID
+}
+
+// OCPPTransactionID returns the OCPP transaction id of the running session as
+// seen in the ocppwallbox journal, or "None" outside a transaction.
+func (w *Wallbox) OCPPTransactionID() string {
+	if id := w.ocppTransaction.current(); id != "" {
+		return id
+	}
+	return "None"
+}
+
+var ocppHeartbeatCallRe = regexp.MustCompile(`\[\s*2\s*,\s*"([^"]+)"\s*,\s*"Heartbeat"`)
+
+// ocppHeartbeatTracker remembers when the central system last answered a
+// Heartbeat. Only the CALLRESULT counts: a charger keeps sending heartbeats
+// into a dead websocket, so the request alone says nothing about the backend.
+type ocppHeartbeatTracker struct {
+	mu      sync.RWMutex
+	pending string
+	last    time.Time
+}
+
+func (t *ocppHeartbeatTracker) processLine(line string, now time.Time) {
+	if m := ocppHeartbeatCallRe.FindStringSubmatch(line); m != nil {
+		t.mu.Lock()
+		t.pending = m[1]
+		t.mu.Unlock()
+		return
+	}
+
+	m := ocppCallResultRe.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending == "" || m[1] != t.pending {
+		return
+	}
+	t.pending = ""
+	t.last = now
+}
+
+func (t *ocppHeartbeatTracker) lastSeen() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.last
+}
+
+// OCPPLastHeartbeat returns when the central system last acknowledged a
+// Heartbeat as RFC3339, or "" if none was seen since the bridge started.
+func (w *Wallbox) OCPPLastHeartbeat() string {
+	last := w.ocppHeartbeat.lastSeen()
+	if last.IsZero() {
+		return ""
+	}
+	return last.Format(time.RFC3339)
+}
+
+// OCPPBackendConnected reports whether a Heartbeat was acknowledged within
+// maxAge.
+func (w *Wallbox) OCPPBackendConnected(maxAge time.Duration) bool {
+	last := w.ocppHeartbeat.lastSeen()
+	return !last.IsZero() && time.Since(last) <= maxAge
+}
+
 func ocppCodeFromSessionState(state string) (int, bool) {
 	normalized := normalizeSessionState(state)
 	switch normalized {
@@ -1163,9 +3468,447 @@ func ocppCodeFromSessionState(state string) (int, bool) {
 	return 0, false
 }
 
+// sessionEndReason maps the last in-session state, the state the session
+// ended in and the control action that accompanied it to an end reason.
+func sessionEndReason(lastState, endState, controlAction string) string {
+	last := normalizeSessionState(lastState)
+	end := normalizeSessionState(endState)
+	action := normalizeSessionState(controlAction)
+
+	switch {
+	case strings.Contains(action, "stop"):
+		return "Stopped remotely"
+	case strings.HasPrefix(last, "error") || strings.HasPrefix(last, "unviable") ||
+		strings.HasPrefix(end, "error") || strings.HasPrefix(end, "unviable"):
+		return "Error"
+	case strings.HasPrefix(last, "schedule") || strings.HasPrefix(end, "schedule"):
+		return "Schedule end"
+	case strings.HasPrefix(last, "charging") || strings.HasPrefix(last, "discharging"):
+		// Energy was still flowing when the session went away.
+		return "Unplugged"
+	case strings.HasPrefix(last, "connected") || strings.HasPrefix(last, "paused") ||
+		strings.HasPrefix(last, "waiting") || last == "finish":
+		// The car had already stopped drawing power.
+		return "Completed"
+	}
+	return "Unknown"
+}
+
 func normalizeSessionState(state string) string {
 	normalized := strings.ToLower(strings.TrimSpace(state))
 	normalized = strings.ReplaceAll(normalized, " ", "")
 	normalized = strings.ReplaceAll(normalized, "_", "")
 	return normalized
 }
+
+// trackCarConnected starts the connected timer on the first B/C pilot reading
+// and clears it on A. Unknown pilot codes leave the timer as it is.
+func (w *Wallbox) trackCarConnected(now time.Time) {
+	switch w.ControlPilotLetter() {
+	case "A":
+		w.carConnectedSince = time.Time{}
+	case "B", "C":
+		if w.carConnectedSince.IsZero() {
+			w.carConnectedSince = now
+		}
+	}
+}
+
+// CarConnectedDuration returns how long the car has been plugged in, whether
+// or not it is charging, or 0 while no car is connected.
+func (w *Wallbox) CarConnectedDuration() time.Duration {
+	return w.carConnectedDuration(time.Now())
+}
+
+func (w *Wallbox) carConnectedDuration(now time.Time) time.Duration {
+	if w.carConnectedSince.IsZero() {
+		return 0
+	}
+	return now.Sub(w.carConnectedSince)
+}
+
+// minEfficiencyEnergy is the delivered energy (Wh) a session needs before the
+// efficiency ratio is reported; early in a session both counters are too
+// coarse for the ratio to mean anything.
+const minEfficiencyEnergy = 500
+
+// HasEfficiencySources reports whether both the grid-side internal meter and
+// the delivered session energy are available, which Efficiency needs.
+func (w *Wallbox) HasEfficiencySources() bool {
+	return w.HasTelemetry && w.Data.RedisTelemetry.InternalMeterEnergy != 0 &&
+		w.Data.SQL.ActiveSessionEnergyTotal > 0
+}
+
+// trackEfficiencyBaseline keeps the grid meter baseline pinned to the current
+// reading until the session starts delivering energy.
+func (w *Wallbox) trackEfficiencyBaseline() {
+	if w.Data.SQL.ActiveSessionEnergyTotal <= 0 || w.efficiencyGridBaseline == 0 {
+		w.efficiencyGridBaseline = w.Data.RedisTelemetry.InternalMeterEnergy
+	}
+}
+
+// Efficiency returns the energy delivered to the car as a percentage of the
+// energy drawn through the internal meter during the current session. ok is
+// false while either source is missing or the session is still warming up.
+func (w *Wallbox) Efficiency() (percent float64, ok bool) {
+	if !w.HasEfficiencySources() {
+		return 0, false
+	}
+	grid := w.Data.RedisTelemetry.InternalMeterEnergy - w.efficiencyGridBaseline
+	return sessionEfficiency(w.Data.SQL.ActiveSessionEnergyTotal, grid, minEfficiencyEnergy)
+}
+
+func sessionEfficiency(delivered, grid, minDelivered float64) (float64, bool) {
+	if delivered < minDelivered || grid <= 0 {
+		return 0, false
+	}
+	return delivered / grid * 100, true
+}
+
+// recordBackendError counts err against the given backend ("redis" or
+// "mysql") and returns it wrapped with the backend name.
+func (w *Wallbox) recordBackendError(backend string, err error) error {
+	err = fmt.Errorf("%s: %w", backend, err)
+
+	now := time.Now()
+	w.backendMux.Lock()
+	defer w.backendMux.Unlock()
+	var downSince *time.Time
+	switch backend {
+	case "redis":
+		w.redisErrors++
+		downSince = &w.redisDownSince
+	case "mysql":
+		w.mysqlErrors++
+		downSince = &w.mysqlDownSince
+	}
+	if downSince != nil && downSince.IsZero() {
+		*downSince = now
+		log.Printf("%s unhealthy: %v", backend, err)
+	}
+	w.lastBackendError = err.Error()
+	w.lastBackendErrorAt = now
+	return err
+}
+
+// recordBackendOK notes a successful read from backend and logs when it
+// recovers from earlier errors.
+func (w *Wallbox) recordBackendOK(backend string) {
+	w.backendMux.Lock()
+	defer w.backendMux.Unlock()
+	var downSince *time.Time
+	switch backend {
+	case "redis":
+		downSince = &w.redisDownSince
+	case "mysql":
+		downSince = &w.mysqlDownSince
+		w.mysqlRetryDelay = 0
+		w.mysqlRetryAt = time.Time{}
+	default:
+		return
+	}
+	if !downSince.IsZero() {
+		log.Printf("%s healthy again after %s", backend, time.Since(*downSince).Round(time.Second))
+		*downSince = time.Time{}
+	}
+}
+
+// Backoff between MySQL reconnects while polls keep failing. A replaced
+// pool is closed after mysqlPoolCloseDelay, once whoever still holds it
+// (e.g. a setter) is done with it.
+const (
+	mysqlReconnectDelay    = 5 * time.Second
+	maxMySQLReconnectDelay = time.Minute
+	mysqlPoolCloseDelay    = time.Minute
+)
+
+// db returns the current MySQL client, which reconnectMySQL may replace.
+func (w *Wallbox) db() *sqlx.DB {
+	w.sqlMux.RLock()
+	defer w.sqlMux.RUnlock()
+	return w.sqlClient
+}
+
+// isConnectionError reports whether err means the MySQL connection is
+// broken, as opposed to a failing statement that a new pool wouldn't fix.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.As(err, &netErr)
+}
+
+// isSchemaError reports whether err is MySQL rejecting a statement because
+// a table or column doesn't exist, which won't change until the firmware does.
+func isSchemaError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	switch mysqlErr.Number {
+	case 1054, 1146: // ER_BAD_FIELD_ERROR, ER_NO_SUCH_TABLE
+		return true
+	}
+	return false
+}
+
+// reconnectMySQL replaces the MySQL client with a fresh connection, at most
+// once per backoff delay. A restarted mysqld (e.g. during a heal) can leave
+// the pool with stale connections, so polls would keep failing otherwise.
+// The backoff is only reset by a successful poll, so a reconnect that
+// succeeds but doesn't help is not repeated every cycle.
+func (w *Wallbox) reconnectMySQL(now time.Time) {
+	if w.mysqlDSN == "" || now.Before(w.mysqlRetryAt) {
+		return
+	}
+	if w.mysqlRetryDelay == 0 {
+		w.mysqlRetryDelay = mysqlReconnectDelay
+	}
+	w.mysqlRetryAt = now.Add(w.mysqlRetryDelay)
+	delay := w.mysqlRetryDelay
+	if w.mysqlRetryDelay *= 2; w.mysqlRetryDelay > maxMySQLReconnectDelay {
+		w.mysqlRetryDelay = maxMySQLReconnectDelay
+	}
+
+	db, err := sqlx.Connect("mysql", w.mysqlDSN)
+	if err != nil {
+		log.Printf("Reconnecting to MySQL at %s failed: %v; retrying in %s", w.mysqlAddr, err, delay)
+		return
+	}
+
+	w.sqlMux.Lock()
+	old := w.sqlClient
+	w.sqlClient = db
+	w.sqlMux.Unlock()
+	if old != nil {
+		// Setters may still be running on the old pool; only drop its idle
+		// connections now and close it once they are done.
+		old.SetMaxIdleConns(0)
+		time.AfterFunc(mysqlPoolCloseDelay, func() { old.Close() })
+	}
+	log.Printf("Reconnected to MySQL at %s", w.mysqlAddr)
+}
+
+// RecordSkippedCycle counts a poll cycle the bridge skipped because
+// RefreshData failed.
+func (w *Wallbox) RecordSkippedCycle() {
+	w.backendMux.Lock()
+	w.skippedCycles++
+	w.backendMux.Unlock()
+}
+
+// RedisErrors returns the number of failed Redis reads since start.
+func (w *Wallbox) RedisErrors() int {
+	w.backendMux.RLock()
+	defer w.backendMux.RUnlock()
+	return w.redisErrors
+}
+
+// MySQLErrors returns the number of failed MySQL reads since start.
+func (w *Wallbox) MySQLErrors() int {
+	w.backendMux.RLock()
+	defer w.backendMux.RUnlock()
+	return w.mysqlErrors
+}
+
+// SkippedCycles returns the number of poll cycles skipped since start.
+func (w *Wallbox) SkippedCycles() int {
+	w.backendMux.RLock()
+	defer w.backendMux.RUnlock()
+	return w.skippedCycles
+}
+
+func (w *Wallbox) markSeen(at *time.Time, now time.Time) {
+	w.backendMux.Lock()
+	*at = now
+	w.backendMux.Unlock()
+}
+
+// LastTelemetry returns when the last telemetry event arrived, or the zero
+// time if none did.
+func (w *Wallbox) LastTelemetry() time.Time {
+	w.backendMux.RLock()
+	defer w.backendMux.RUnlock()
+	return w.lastTelemetryAt
+}
+
+// LastSeen returns when the charger last answered a state read or sent a
+// telemetry event, whichever is newer, or the zero time if it never did.
+// An idle charger keeps answering, so only a hung or powered-off one (or a
+// broken link to it) lets this go stale.
+func (w *Wallbox) LastSeen() time.Time {
+	w.backendMux.RLock()
+	defer w.backendMux.RUnlock()
+	if w.lastTelemetryAt.After(w.lastStateAt) {
+		return w.lastTelemetryAt
+	}
+	return w.lastStateAt
+}
+
+// LastBackendError returns the most recent Redis/MySQL error, or "None".
+func (w *Wallbox) LastBackendError() string {
+	w.backendMux.RLock()
+	defer w.backendMux.RUnlock()
+	if w.lastBackendError == "" {
+		return "None"
+	}
+	return w.lastBackendError
+}
+
+// LastBackendErrorAt returns when the most recent Redis/MySQL error happened
+// as RFC3339, or "" if there has been none.
+func (w *Wallbox) LastBackendErrorAt() string {
+	w.backendMux.RLock()
+	defer w.backendMux.RUnlock()
+	if w.lastBackendErrorAt.IsZero() {
+		return ""
+	}
+	return w.lastBackendErrorAt.Format(time.RFC3339)
+}
+
+// NetworkInfo describes how the charger is connected to the local network.
+// Unknown fields are "unknown".
+type NetworkInfo struct {
+	Interface string
+	IP        string
+	SSID      string
+}
+
+// networkInfoMaxAge limits how often interfaces are enumerated and iwgetid
+// is run; NetworkInfo is read on every poll.
+const networkInfoMaxAge = time.Minute
+
+// SystemResources are the charger's free memory and storage in kB, as
+// reported by telemetry. The field names follow /proc/meminfo (MemAvailable,
+// CmaFree), which counts in kB; a value is 0 until telemetry reports it.
+type SystemResources struct {
+	AvailableMemory      float64
+	CMAFreeMemory        float64
+	AvailableStorageRoot float64
+}
+
+// SystemResources returns the latest memory and storage telemetry.
+func (w *Wallbox) SystemResources() SystemResources {
+	if !w.HasTelemetry {
+		return SystemResources{}
+	}
+	t := w.Data.RedisTelemetry
+	return SystemResources{
+		AvailableMemory:      t.AvailableMemory,
+		CMAFreeMemory:        t.CMAFreeMemory,
+		AvailableStorageRoot: t.AvailableStorageRoot,
+	}
+}
+
+// Uptime returns how long the charger has been running. On-device it comes
+// from /proc/uptime; off-device from the SENSOR_SYSTEM_UPTIME telemetry
+// (seconds), so ok is false until telemetry has reported it.
+func (w *Wallbox) Uptime() (time.Duration, bool) {
+	if w.offDevice {
+		if !w.HasTelemetry || w.Data.RedisTelemetry.SystemUptime <= 0 {
+			return 0, false
+		}
+		return time.Duration(w.Data.RedisTelemetry.SystemUptime * float64(time.Second)), true
+	}
+
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, false
+	}
+	return parseProcUptime(string(data))
+}
+
+// parseProcUptime parses the first field of /proc/uptime ("12345.67 ...").
+func parseProcUptime(data string) (time.Duration, bool) {
+	fields := strings.Fields(data)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// preferredInterfaces are checked first, in order, when picking the
+// charger's address.
+var preferredInterfaces = []string{"wlan0", "eth0", "mlan0"}
+
+// NetworkInfo returns the charger's interface, IPv4 address and Wi-Fi SSID.
+// Off-device the bridge host's network says nothing about the charger, so
+// everything is "unknown" there.
+func (w *Wallbox) NetworkInfo() NetworkInfo {
+	unknown := NetworkInfo{Interface: "unknown", IP: "unknown", SSID: "unknown"}
+	if w.offDevice {
+		return unknown
+	}
+
+	w.networkMux.Lock()
+	defer w.networkMux.Unlock()
+	if !w.networkInfoAt.IsZero() && time.Since(w.networkInfoAt) < networkInfoMaxAge {
+		return w.networkInfo
+	}
+
+	info := unknown
+	if name, ip, ok := pickInterfaceAddr(localInterfaceAddrs()); ok {
+		info.Interface = name
+		info.IP = ip
+	}
+	if out, err := exec.Command("iwgetid", "-r").Output(); err == nil {
+		if ssid := strings.TrimSpace(string(out)); ssid != "" {
+			info.SSID = ssid
+		}
+	}
+
+	w.networkInfo = info
+	w.networkInfoAt = time.Now()
+	return info
+}
+
+// localInterfaceAddrs returns the IPv4 addresses of every interface that is
+// up, keyed by interface name.
+func localInterfaceAddrs() map[string][]string {
+	result := make(map[string][]string)
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return result
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				result[iface.Name] = append(result[iface.Name], ipNet.IP.String())
+			}
+		}
+	}
+	return result
+}
+
+// pickInterfaceAddr picks the address of the first preferred interface that
+// has one, falling back to the alphabetically first other interface.
+func pickInterfaceAddr(addrs map[string][]string) (name, ip string, ok bool) {
+	for _, name := range preferredInterfaces {
+		if ips := addrs[name]; len(ips) > 0 {
+			return name, ips[0], true
+		}
+	}
+
+	var names []string
+	for name, ips := range addrs {
+		if len(ips) > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", "", false
+	}
+	sort.Strings(names)
+	return names[0], addrs[names[0]][0], true
+}
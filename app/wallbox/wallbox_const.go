@@ -1,6 +1,10 @@
 package wallbox
 
-import "fmt"
+import (
+	"fmt"
+	"log"
+	"sort"
+)
 
 var wallboxStatusCodes = []string{
 	"Ready",
@@ -122,6 +126,49 @@ var telemetryControlPilotConnected = map[int]bool{
 	195: true,
 }
 
+// ControlPilotOverrides corrects the telemetry control-pilot maps for
+// firmware that uses SENSOR_CONTROL_PILOT codes differently. Codes that are
+// not listed keep their built-in meaning.
+type ControlPilotOverrides struct {
+	States    map[int]string
+	Letters   map[int]string
+	Connected map[int]bool
+}
+
+// ApplyControlPilotOverrides merges overrides into the control-pilot maps and
+// logs the effective mapping. It must be called before the first refresh.
+func ApplyControlPilotOverrides(overrides ControlPilotOverrides) {
+	for code, desc := range overrides.States {
+		telemetryControlPilotStates[code] = desc
+	}
+	for code, letter := range overrides.Letters {
+		telemetryControlPilotLetters[code] = letter
+	}
+	for code, connected := range overrides.Connected {
+		telemetryControlPilotConnected[code] = connected
+	}
+
+	codes := make(map[int]bool)
+	for code := range telemetryControlPilotStates {
+		codes[code] = true
+	}
+	for code := range telemetryControlPilotLetters {
+		codes[code] = true
+	}
+	for code := range telemetryControlPilotConnected {
+		codes[code] = true
+	}
+	sorted := make([]int, 0, len(codes))
+	for code := range codes {
+		sorted = append(sorted, code)
+	}
+	sort.Ints(sorted)
+	for _, code := range sorted {
+		log.Printf("Control pilot %d: %q, letter %q, connected %v", code,
+			telemetryControlPilotStates[code], telemetryControlPilotLetters[code], telemetryControlPilotConnected[code])
+	}
+}
+
 var telemetryStatusDescriptions = map[int]string{
 	0:   "Disconnected",
 	14:  "Error",
@@ -287,6 +334,7 @@ func describeControlMode(code int) string {
 
 var scheduleStatusDescriptions = map[int]string{
 	0: "Inactive",
+	1: "Active",
 }
 
 func describeScheduleStatus(code int) string {
@@ -7,8 +7,12 @@ import (
 )
 
 func main() {
+	if len(os.Args) == 3 && os.Args[1] == "--self-test" {
+		bridge.RunSelfTest(os.Args[2])
+		return
+	}
 	if len(os.Args) != 2 {
-		panic("Usage: ./bridge --config or ./bridge bridge.ini")
+		panic("Usage: ./bridge --config, ./bridge --self-test bridge.ini or ./bridge bridge.ini")
 	}
 	firstArgument := os.Args[1]
 	if firstArgument == "--config" {